@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/grpc"
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/websocket"
+)
+
+type AdminOpts struct {
+	AdminToken string `help:"Admin token of the server."                            env:"GOCHAT_ADMIN_TOKEN" required:""`
+	Tls        bool   `help:"Use TLS."`
+	TlsCa      string `help:"TLS CA file to verify the server with (implies --tls)." type:"path"`
+	TlsCert    string `help:"TLS client certificate file."                           type:"path"`
+	TlsKey     string `help:"TLS client key file."                                   type:"path"`
+	Json       bool   `help:"Print JSON."`
+}
+
+type AdminCommands struct {
+	Rooms struct {
+	} `help:"List rooms"                          cmd:""`
+	Users struct {
+	} `help:"List connected users, per session"   cmd:""`
+	Kick struct {
+		Username string `help:"Username."                       arg:""`
+		Reason   string `help:"Reason, shown to the kicked user."`
+	} `help:"Disconnect all sessions of a user"   cmd:""`
+	Notice struct {
+		Message string `help:"Message." arg:""`
+	} `help:"Send a server notice to all users"   cmd:""`
+	Members struct {
+	} `help:"List members and their roles"       cmd:""`
+	Role struct {
+		Username string `help:"Username of an authenticated user." arg:""`
+		Role     string `help:"Role."                              arg:"" enum:"member,admin"`
+	} `help:"Set the role of a member"           cmd:""`
+	Ban struct {
+		Username string        `help:"Username of an authenticated user." arg:""`
+		Reason   string        `help:"Reason, shown to the banned user."`
+		For      time.Duration `help:"Duration of the ban, forever when not set."`
+	} `help:"Ban a user and disconnect them"     cmd:""`
+	Unban struct {
+		Username string `help:"Username." arg:""`
+	} `help:"Lift the ban of a user"             cmd:""`
+	Bans struct {
+	} `help:"List active bans"                    cmd:""`
+	Stats struct {
+	} `help:"Show hub stats"                      cmd:""`
+	Retention struct {
+	} `help:"Report what retention would purge"   cmd:""`
+}
+
+// runAdmin runs the admin subcommand.
+func runAdmin(command string, cli *Commands, logger log.Logger) error {
+	opts := cli.Admin
+	addr := fmt.Sprintf("%s:%d", opts.Host, opts.Port)
+	tlsConfig, err := clientTLSConfig(opts.Tls, opts.TlsCa, opts.TlsCert, opts.TlsKey)
+	if err != nil {
+		return err
+	}
+
+	var admin chat.Admin
+	if opts.Grpc {
+		admin, err = grpc.NewAdminClient(addr, grpc.AdminOpts{
+			Token:     opts.AdminToken,
+			TLSConfig: tlsConfig,
+		})
+	} else {
+		admin, err = websocket.NewAdminClient(addr, websocket.AdminOpts{
+			Token:     opts.AdminToken,
+			TLSConfig: tlsConfig,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	logger.Infow("admin request", "addr", addr, "command", command)
+	out := os.Stdout
+	switch command {
+	case "admin rooms":
+		rooms, err := admin.Rooms()
+		if err != nil {
+			return err
+		}
+		if opts.Json {
+			return printJSON(out, rooms)
+		}
+		return printTable(out, []string{"ROOM", "USERS", "SESSIONS"}, len(rooms), func(i int) []any {
+			return []any{rooms[i].Name, rooms[i].Users, rooms[i].Sessions}
+		})
+
+	case "admin users":
+		sessions, err := admin.Sessions()
+		if err != nil {
+			return err
+		}
+		if opts.Json {
+			return printJSON(out, sessions)
+		}
+		return printTable(
+			out,
+			[]string{"SESSION", "ROOM", "USERNAME", "USER ID", "TRANSPORT", "REMOTE ADDR", "PROTOCOL"},
+			len(sessions),
+			func(i int) []any {
+				s := sessions[i]
+				return []any{s.ID, s.Room, s.Username, s.UserID, s.Transport, s.RemoteAddr, s.Protocol}
+			},
+		)
+
+	case "admin kick <username>":
+		n, err := admin.Kick(opts.Kick.Username, opts.Kick.Reason)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "kicked %s (%d sessions)\n", opts.Kick.Username, n)
+
+	case "admin notice <message>":
+		return admin.Notice(opts.Notice.Message)
+
+	case "admin members":
+		members, err := admin.Members()
+		if err != nil {
+			return err
+		}
+		if opts.Json {
+			return printJSON(out, members)
+		}
+		return printTable(out, []string{"NAME", "USER ID", "ROLE", "JOINED"}, len(members), func(i int) []any {
+			m := members[i]
+			return []any{m.Name, m.UserID, m.Role, m.Joined.Local().Format(time.RFC3339)}
+		})
+
+	case "admin role <username> <role>":
+		if err := admin.SetRole(opts.Role.Username, chat.Role(opts.Role.Role)); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s is %s\n", opts.Role.Username, opts.Role.Role)
+
+	case "admin ban <username>":
+		var until time.Time
+		if opts.Ban.For > 0 {
+			until = time.Now().Add(opts.Ban.For)
+		}
+		if err := admin.Ban(opts.Ban.Username, opts.Ban.Reason, until); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "banned %s until %s\n", opts.Ban.Username, banUntil(until))
+
+	case "admin unban <username>":
+		if err := admin.Unban(opts.Unban.Username); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "unbanned %s\n", opts.Unban.Username)
+
+	case "admin bans":
+		bans, err := admin.Bans()
+		if err != nil {
+			return err
+		}
+		if opts.Json {
+			return printJSON(out, bans)
+		}
+		return printTable(out, []string{"NAME", "USER ID", "UNTIL", "REASON"}, len(bans), func(i int) []any {
+			b := bans[i]
+			return []any{b.Name, b.UserID, banUntil(b.Until), b.Reason}
+		})
+
+	case "admin stats":
+		stats, err := admin.Stats()
+		if err != nil {
+			return err
+		}
+		if opts.Json {
+			return printJSON(out, stats)
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "started\t%s (%s ago)\n",
+			stats.StartedAt.Local().Format(time.RFC3339),
+			time.Since(stats.StartedAt).Round(time.Second))
+		fmt.Fprintf(w, "users\t%d\n", stats.Users)
+		fmt.Fprintf(w, "sessions\t%d\n", stats.Sessions)
+		fmt.Fprintf(w, "messages\t%d\n", stats.Messages)
+		fmt.Fprintf(w, "events in\t%d\n", stats.EventsIn)
+		fmt.Fprintf(w, "events out\t%d\n", stats.EventsOut)
+		fmt.Fprintf(w, "history events\t%d\n", stats.HistoryEvents)
+		return w.Flush()
+
+	case "admin retention":
+		report, err := admin.Retention()
+		if err != nil {
+			return err
+		}
+		if opts.Json {
+			return printJSON(out, report)
+		}
+		return printTable(
+			out,
+			[]string{"ROOM", "DAYS", "KEEP MESSAGES", "MESSAGES", "PURGE", "HELD", "OTHER EVENTS"},
+			len(report.Rooms),
+			func(i int) []any {
+				r := report.Rooms[i]
+				return []any{
+					r.Room,
+					unlimited(r.Policy.Days),
+					unlimited(r.Policy.Messages),
+					r.Messages,
+					len(r.Purged),
+					len(r.Held),
+					r.Events,
+				}
+			},
+		)
+	}
+	return nil
+}
+
+// banUntil returns the end of a ban, "forever" for zero.
+func banUntil(until time.Time) string {
+	if until.IsZero() {
+		return "forever"
+	}
+	return until.Local().Format(time.RFC3339)
+}
+
+// unlimited returns "-" for zero (unlimited) policy values.
+func unlimited(n int) any {
+	if n == 0 {
+		return "-"
+	}
+	return n
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printTable prints n rows with the header as aligned columns.
+func printTable(w io.Writer, header []string, n int, row func(i int) []any) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for i := 0; i < n; i++ {
+		cols := []string{}
+		for _, v := range row(i) {
+			cols = append(cols, fmt.Sprint(v))
+		}
+		fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/bench"
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/grpc"
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/websocket"
+)
+
+type BenchOpts struct {
+	Clients  int           `help:"Number of simulated clients."                          short:"c" default:"10"`
+	Rate     float64       `help:"Messages per second per client."                                 default:"1"`
+	Duration time.Duration `help:"How long clients send messages."                       short:"d" default:"10s"`
+	Drain    time.Duration `help:"How long to wait for deliveries after sending stopped."          default:"2s"`
+	Codec    string        `help:"Websocket codec (json, protobuf)." enum:"json,protobuf"          default:"json"`
+	Token    string        `help:"Authentication token."                                           env:"GOCHAT_TOKEN"`
+	Tls      bool          `help:"Use TLS."`
+	TlsCa    string        `help:"TLS CA file to verify the server with (implies --tls)." type:"path"`
+	TlsCert  string        `help:"TLS client certificate file."                           type:"path"`
+	TlsKey   string        `help:"TLS client key file."                                   type:"path"`
+	JsonOut  string        `help:"Write the report as JSON to this file."                 type:"path"`
+}
+
+// runBench runs the bench command, printing the report to stdout.
+func runBench(cli *Commands, logger log.Logger) error {
+	opts := cli.Bench
+	addr := fmt.Sprintf("%s:%d", opts.Host, opts.Port)
+	tlsConfig, err := clientTLSConfig(opts.Tls, opts.TlsCa, opts.TlsCert, opts.TlsKey)
+	if err != nil {
+		return err
+	}
+
+	// Clients log to a noop logger, there would be too many of them.
+	clientLogger := &log.NoopLoggerAdapter{}
+	dial := func(username string) (chat.Connection, error) {
+		if opts.Grpc {
+			return grpc.NewClientConnection(
+				addr,
+				username,
+				clientLogger,
+				grpc.ClientOpts{Token: opts.Token, TLSConfig: tlsConfig},
+			)
+		}
+		return websocket.NewClientConnection(
+			addr,
+			username,
+			clientLogger,
+			websocket.ClientOpts{
+				Token:     opts.Token,
+				TLSConfig: tlsConfig,
+				Codec:     opts.Codec,
+			},
+		)
+	}
+
+	report, err := bench.Run(dial, bench.Opts{
+		Clients:  opts.Clients,
+		Rate:     opts.Rate,
+		Duration: opts.Duration,
+		Drain:    opts.Drain,
+	}, logger)
+	if report != nil {
+		if err := report.WriteText(os.Stdout); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.JsonOut != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(opts.JsonOut, append(data, '\n'), 0o644)
+	}
+	return nil
+}
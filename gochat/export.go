@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/grpc"
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/websocket"
+)
+
+type ExportOpts struct {
+	AdminToken string `help:"Admin token of the server."                                         env:"GOCHAT_ADMIN_TOKEN" required:""`
+	From       string `help:"Start of the time range: RFC 3339, date, \"date hh:mm\" or duration ago (24h)."`
+	To         string `help:"End of the time range (exclusive), same formats as --from."`
+	Format     string `help:"Format (markdown, text, jsonl, html), default by output extension." enum:",markdown,text,jsonl,html" default:""`
+	Output     string `help:"Output file (default: stdout)."                                      short:"o" type:"path"`
+	Tls        bool   `help:"Use TLS."`
+	TlsCa      string `help:"TLS CA file to verify the server with (implies --tls)."              type:"path"`
+	TlsCert    string `help:"TLS client certificate file."                                        type:"path"`
+	TlsKey     string `help:"TLS client key file."                                                type:"path"`
+}
+
+// runExport runs the export command.
+func runExport(cli *Commands, logger log.Logger) error {
+	opts := cli.Export
+	addr := fmt.Sprintf("%s:%d", opts.Host, opts.Port)
+	now := time.Now()
+	from, err := chat.ParseExportTime(opts.From, now)
+	if err != nil {
+		return err
+	}
+	to, err := chat.ParseExportTime(opts.To, now)
+	if err != nil {
+		return err
+	}
+	format := opts.Format
+	if format == "" {
+		format = chat.ExportFormatFromPath(opts.Output)
+	}
+	tlsConfig, err := clientTLSConfig(opts.Tls, opts.TlsCa, opts.TlsCert, opts.TlsKey)
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("exporting history", "addr", addr, "from", from, "to", to)
+	var events []chat.Event
+	if opts.Grpc {
+		events, err = grpc.Export(addr, from, to, grpc.AdminOpts{
+			Token:     opts.AdminToken,
+			TLSConfig: tlsConfig,
+		})
+	} else {
+		events, err = websocket.Export(addr, from, to, websocket.AdminOpts{
+			Token:     opts.AdminToken,
+			TLSConfig: tlsConfig,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "" {
+		return chat.Export(os.Stdout, events, format)
+	}
+	f, err := os.Create(opts.Output)
+	if err != nil {
+		return err
+	}
+	if err := chat.Export(f, events, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+)
+
+// frontendOpts are the options for startFrontend.
+type frontendOpts struct {
+	stdout    bool
+	username  string
+	guiConfig string
+	notifier  *chat.Notifier
+	// plain strips message formatting in the stdout frontend.
+	plain bool
+	// format is the stdout frontend format, the JSON format implies
+	// the stdout frontend.
+	format string
+	// noInput disables reading stdin in the stdout frontend.
+	noInput bool
+}
+
+// startFrontend starts the stdout or GUI frontend on conn, blocking
+// until it stops. The GUI config is reloaded on SIGHUP.
+func startFrontend(conn chat.Connection, opts frontendOpts, logger log.Logger) error {
+	if opts.stdout || opts.format == chat.StdoutFormatJSON {
+		fe := chat.NewStdoutFrontend(conn, logger)
+		fe.Plain = opts.plain
+		if opts.format != "" {
+			fe.Format = opts.format
+		}
+		if opts.noInput {
+			fe.Input = nil
+		}
+		return fe.Start()
+	}
+
+	configPath := opts.guiConfig
+	if configPath == "" {
+		var err error
+		configPath, err = chat.DefaultGUIConfigPath()
+		if err != nil {
+			return err
+		}
+	}
+	config, err := chat.LoadGUIConfig(configPath)
+	if err != nil {
+		return err
+	}
+	fe, err := chat.NewGUIFrontend(conn, logger, chat.GUIFrontendOpts{
+		Username: opts.username,
+		Notifier: opts.notifier,
+		Config:   config,
+	})
+	if err != nil {
+		return err
+	}
+	reloadConfig := make(chan os.Signal, 1)
+	signal.Notify(reloadConfig, syscall.SIGHUP)
+	go func() {
+		for range reloadConfig {
+			config, err := chat.LoadGUIConfig(configPath)
+			if err != nil {
+				logger.Warnw("could not reload gui config", log.Error(err))
+				continue
+			}
+			logger.Infow("reloaded gui config", "path", configPath)
+			fe.SetConfig(config)
+		}
+	}()
+	return fe.Start()
+}
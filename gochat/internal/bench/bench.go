@@ -0,0 +1,347 @@
+// Package bench implements load testing a chat server with simulated
+// clients, measuring message delivery latency and loss.
+package bench
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+)
+
+// maxConcurrentDials limits the number of clients connecting at once.
+const maxConcurrentDials = 32
+
+// MaxRate is the highest rate, a message per nanosecond per client.
+const MaxRate = float64(time.Second)
+
+// Dialer connects a client with the username.
+type Dialer func(username string) (chat.Connection, error)
+
+// Opts are the options for Run.
+type Opts struct {
+	// Clients is the number of simulated clients.
+	Clients int
+	// Rate is the number of messages per second per client.
+	Rate float64
+	// Duration is how long clients send messages.
+	Duration time.Duration
+	// Drain is how long to wait for deliveries after sending stopped.
+	Drain time.Duration
+}
+
+// Report is the result of a bench run.
+type Report struct {
+	Clients         int     `json:"clients"`
+	Rate            float64 `json:"rate"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Connected is the number of clients that connected.
+	Connected int `json:"connected"`
+	// ConnectFailures is the number of clients that could not connect.
+	ConnectFailures int `json:"connectFailures"`
+	// Disconnects is the number of clients that lost their connection.
+	Disconnects int `json:"disconnects"`
+	// Sent is the number of messages sent.
+	Sent int64 `json:"sent"`
+	// Expected is the number of deliveries expected: every message sent
+	// to every connected client, until the client disconnected.
+	Expected int64 `json:"expected"`
+	// Delivered is the number of messages delivered.
+	Delivered int64 `json:"delivered"`
+	// Lost is Expected minus Delivered.
+	Lost     int64   `json:"lost"`
+	LossRate float64 `json:"lossRate"`
+	// Throughput is the number of deliveries per second.
+	Throughput float64 `json:"throughput"`
+	// Latency is the end-to-end delivery latency in milliseconds.
+	Latency Latency `json:"latencyMs"`
+}
+
+// Latency are delivery latency statistics in milliseconds.
+type Latency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// WriteText writes the report in human readable form.
+func (r *Report) WriteText(w io.Writer) error {
+	_, err := fmt.Fprintf(w, `clients:          %d (%d connected, %d failed, %d disconnected)
+rate:             %.2f msg/s per client for %.1fs
+messages sent:    %d
+deliveries:       %d of %d (%d lost, %.2f%%)
+throughput:       %.1f deliveries/s
+latency (ms):     min %.2f  mean %.2f  p50 %.2f  p95 %.2f  p99 %.2f  max %.2f
+`,
+		r.Clients, r.Connected, r.ConnectFailures, r.Disconnects,
+		r.Rate, r.DurationSeconds,
+		r.Sent,
+		r.Delivered, r.Expected, r.Lost, r.LossRate*100,
+		r.Throughput,
+		r.Latency.Min, r.Latency.Mean, r.Latency.P50,
+		r.Latency.P95, r.Latency.P99, r.Latency.Max,
+	)
+	return err
+}
+
+// run is the state of a bench run.
+type run struct {
+	id          string
+	logger      log.Logger
+	sent        int64
+	delivered   int64
+	disconnects int64
+	// expected is the sum of the messages sent by the time each
+	// reader stopped.
+	expected int64
+	// sending is 1 while sending, disconnects after are expected.
+	sending     int32
+	latenciesMu sync.Mutex
+	latencies   []time.Duration
+}
+
+// message returns the message text for the client and sequence number,
+// carrying the send time.
+func (r *run) message(client int, seq int) string {
+	return fmt.Sprintf(
+		"bench:%s:%d:%d:%d",
+		r.id, client, seq, time.Now().UnixNano(),
+	)
+}
+
+// parseSentAt returns the send time of a message of this run,
+// false for other messages.
+func (r *run) parseSentAt(message string) (time.Time, bool) {
+	parts := strings.Split(message, ":")
+	if len(parts) != 5 || parts[0] != "bench" || parts[1] != r.id {
+		return time.Time{}, false
+	}
+	ns, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns), true
+}
+
+// read reads events from conn until closed, recording deliveries.
+// Messages sent after the connection was lost are not expected.
+func (r *run) read(conn chat.Connection) {
+	for {
+		e, err := conn.ReadEvent()
+		if err != nil {
+			if !errors.Is(err, chat.ErrConnectionClosed) ||
+				atomic.LoadInt32(&r.sending) == 1 {
+				atomic.AddInt64(&r.disconnects, 1)
+			}
+			atomic.AddInt64(&r.expected, atomic.LoadInt64(&r.sent))
+			return
+		}
+		m, ok := e.(*chat.EventNewMessage)
+		if !ok {
+			continue
+		}
+		sentAt, ok := r.parseSentAt(m.Message)
+		if !ok {
+			continue
+		}
+		latency := time.Since(sentAt)
+		atomic.AddInt64(&r.delivered, 1)
+		r.latenciesMu.Lock()
+		r.latencies = append(r.latencies, latency)
+		r.latenciesMu.Unlock()
+	}
+}
+
+// send sends messages at the rate until the deadline.
+func (r *run) send(conn chat.Connection, client int, rate float64, until time.Time) {
+	interval := time.Duration(float64(time.Second) / rate)
+	// Spread clients over the interval to avoid bursts.
+	time.Sleep(time.Duration(mrand.Int63n(int64(interval))))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for seq := 0; time.Now().Before(until); seq++ {
+		e := &chat.EventSendMessage{
+			EventMeta: *chat.NewEventMetaNow(clock.Real),
+			Message:   r.message(client, seq),
+		}
+		if err := conn.SendEvent(e); err != nil {
+			r.logger.Warnw("could not send", "client", client, log.Error(err))
+			return
+		}
+		atomic.AddInt64(&r.sent, 1)
+		<-ticker.C
+	}
+}
+
+// awaitConnected waits for the hub to accept the connection, closing
+// it otherwise. Servers can reject connections after dialing, for
+// example for taken usernames.
+func awaitConnected(conn chat.Connection) error {
+	e, err := conn.ReadEvent()
+	if err == nil {
+		if _, ok := e.(*chat.EventConnected); ok {
+			return nil
+		}
+		err = fmt.Errorf("expected connected event, got %T", e)
+	}
+	if connErr := conn.Err(); connErr != nil {
+		err = connErr
+	}
+	_ = conn.Close(err)
+	return err
+}
+
+// Run connects the clients, sends messages and returns the report.
+// Returns error when no client could connect.
+func Run(dial Dialer, opts Opts, logger log.Logger) (*Report, error) {
+	if opts.Clients < 1 {
+		return nil, errors.New("need at least one client")
+	}
+	if opts.Rate <= 0 || opts.Rate > MaxRate {
+		return nil, fmt.Errorf("rate must be positive and at most %g", MaxRate)
+	}
+
+	idBytes := make([]byte, 4)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, err
+	}
+	r := &run{id: hex.EncodeToString(idBytes), logger: logger}
+	report := &Report{
+		Clients:         opts.Clients,
+		Rate:            opts.Rate,
+		DurationSeconds: opts.Duration.Seconds(),
+	}
+
+	logger.Infow("connecting clients", "clients", opts.Clients, "run", r.id)
+	conns := make([]chat.Connection, opts.Clients)
+	dials := make(chan struct{}, maxConcurrentDials)
+	var wg sync.WaitGroup
+	for i := range conns {
+		i := i
+		wg.Add(1)
+		dials <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-dials }()
+			conn, err := dial(fmt.Sprintf("bench-%s-%d", r.id, i))
+			if err == nil {
+				err = awaitConnected(conn)
+			}
+			if err != nil {
+				logger.Warnw("could not connect", "client", i, log.Error(err))
+				return
+			}
+			conns[i] = conn
+		}()
+	}
+	wg.Wait()
+
+	connected := []int{}
+	for i, conn := range conns {
+		if conn != nil {
+			connected = append(connected, i)
+		}
+	}
+	report.Connected = len(connected)
+	report.ConnectFailures = opts.Clients - len(connected)
+	if len(connected) == 0 {
+		return report, errors.New("no client could connect")
+	}
+
+	atomic.StoreInt32(&r.sending, 1)
+	var readers sync.WaitGroup
+	for _, i := range connected {
+		conn := conns[i]
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			r.read(conn)
+		}()
+	}
+
+	logger.Infow("sending messages", "connected", len(connected))
+	start := time.Now()
+	until := start.Add(opts.Duration)
+	var senders sync.WaitGroup
+	for _, i := range connected {
+		i := i
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			r.send(conns[i], i, opts.Rate, until)
+		}()
+	}
+	senders.Wait()
+
+	logger.Infow("draining", "drain", opts.Drain)
+	time.Sleep(opts.Drain)
+	elapsed := time.Since(start)
+	atomic.StoreInt32(&r.sending, 0)
+	for _, i := range connected {
+		_ = conns[i].Close(nil)
+	}
+	readers.Wait()
+
+	report.Disconnects = int(atomic.LoadInt64(&r.disconnects))
+	report.Sent = atomic.LoadInt64(&r.sent)
+	report.Expected = atomic.LoadInt64(&r.expected)
+	report.Delivered = atomic.LoadInt64(&r.delivered)
+	report.Lost = report.Expected - report.Delivered
+	if report.Expected > 0 {
+		report.LossRate = float64(report.Lost) / float64(report.Expected)
+	}
+	report.Throughput = float64(report.Delivered) / elapsed.Seconds()
+	report.Latency = latencyStats(r.latencies)
+	return report, nil
+}
+
+// latencyStats returns the statistics of the latencies.
+func latencyStats(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	percentile := func(p float64) float64 {
+		i := int(p*float64(len(sorted))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(sorted) {
+			i = len(sorted) - 1
+		}
+		return ms(sorted[i])
+	}
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	return Latency{
+		Min:  ms(sorted[0]),
+		Mean: ms(sum / time.Duration(len(sorted))),
+		P50:  percentile(0.50),
+		P95:  percentile(0.95),
+		P99:  percentile(0.99),
+		Max:  ms(sorted[len(sorted)-1]),
+	}
+}
@@ -0,0 +1,141 @@
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hubDialer returns a dialer connecting to a new hub.
+func hubDialer() Dialer {
+	hub := chat.NewHub(test.NewTestLogger(true), chat.HubOpts{})
+	return func(username string) (chat.Connection, error) {
+		toHub := make(chan chat.Event)
+		fromHub := make(chan chat.Event)
+		if _, err := hub.Connect(username, chat.NewTestConnection(toHub, fromHub)); err != nil {
+			return nil, err
+		}
+		return chat.NewTestConnection(fromHub, toHub), nil
+	}
+}
+
+// lostConnection loses the connection after the connected event.
+type lostConnection struct {
+	chat.Connection
+	connected bool
+}
+
+func (c *lostConnection) ReadEvent() (chat.Event, error) {
+	if !c.connected {
+		c.connected = true
+		return c.Connection.ReadEvent()
+	}
+	_ = c.Connection.Close(nil)
+	return nil, errors.New("connection lost")
+}
+
+func TestRun(t *testing.T) {
+	report, err := Run(hubDialer(), Opts{
+		Clients:  3,
+		Rate:     50,
+		Duration: 200 * time.Millisecond,
+		Drain:    200 * time.Millisecond,
+	}, test.NewTestLogger(true))
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, report.Connected)
+	assert.Equal(t, 0, report.ConnectFailures)
+	assert.Equal(t, 0, report.Disconnects)
+	assert.Greater(t, report.Sent, int64(0))
+	assert.Equal(t, report.Sent*3, report.Expected)
+	assert.Equal(t, report.Expected, report.Delivered)
+	assert.Equal(t, int64(0), report.Lost)
+	assert.LessOrEqual(t, report.Latency.P50, report.Latency.P99)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteText(&buf))
+	assert.Contains(t, buf.String(), "(0 lost, 0.00%)")
+}
+
+func TestRunDisconnects(t *testing.T) {
+	hubDial := hubDialer()
+	dial := func(username string) (chat.Connection, error) {
+		conn, err := hubDial(username)
+		if err == nil && strings.HasSuffix(username, "-0") {
+			conn = &lostConnection{Connection: conn}
+		}
+		return conn, err
+	}
+
+	report, err := Run(dial, Opts{
+		Clients:  2,
+		Rate:     50,
+		Duration: 200 * time.Millisecond,
+		Drain:    200 * time.Millisecond,
+	}, test.NewTestLogger(true))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, report.Disconnects)
+	assert.Greater(t, report.Sent, int64(1))
+	// Only the client that stayed expects the messages sent after the
+	// other one was lost.
+	assert.Less(t, report.Expected, report.Sent*2)
+	assert.Equal(t, report.Sent, report.Delivered)
+}
+
+func TestRunRate(t *testing.T) {
+	for _, rate := range []float64{0, -1, MaxRate * 2} {
+		_, err := Run(hubDialer(), Opts{Clients: 1, Rate: rate}, test.NewTestLogger(true))
+		assert.ErrorContains(t, err, "rate must be positive")
+	}
+}
+
+func TestRunConnectFailures(t *testing.T) {
+	dial := func(username string) (chat.Connection, error) {
+		return nil, assert.AnError
+	}
+	report, err := Run(dial, Opts{Clients: 2, Rate: 1}, test.NewTestLogger(true))
+	assert.Error(t, err)
+	assert.Equal(t, 2, report.ConnectFailures)
+
+	// Connections the hub rejects after dialing fail as well.
+	hubDial := hubDialer()
+	dial = func(username string) (chat.Connection, error) {
+		conn, err := hubDial(username)
+		if err == nil && strings.HasSuffix(username, "-0") {
+			_ = conn.Close(assert.AnError)
+		}
+		return conn, err
+	}
+	report, err = Run(dial, Opts{
+		Clients:  2,
+		Rate:     50,
+		Duration: 50 * time.Millisecond,
+	}, test.NewTestLogger(true))
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Connected)
+	assert.Equal(t, 1, report.ConnectFailures)
+}
+
+func TestLatencyStats(t *testing.T) {
+	latencies := []time.Duration{}
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, Latency{
+		Min:  1,
+		Mean: 50.5,
+		P50:  50,
+		P95:  95,
+		P99:  99,
+		Max:  100,
+	}, latencyStats(latencies))
+	assert.Equal(t, Latency{}, latencyStats(nil))
+}
@@ -0,0 +1,492 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultRoom is the name of the room of the hub. The hub is a single
+// room, the admin API lists rooms for forward compatibility.
+const DefaultRoom = "main"
+
+// ConnectionInfo is implemented by connections that know their remote
+// address and transport, shown by the admin API.
+type ConnectionInfo interface {
+	// RemoteAddr returns the address of the other side, empty when
+	// unknown.
+	RemoteAddr() string
+	// Transport returns the transport name, like websocket or grpc.
+	Transport() string
+}
+
+// Admin is the admin API of a server, implemented by the admin clients
+// of the transports.
+type Admin interface {
+	// Rooms lists the rooms.
+	Rooms() ([]RoomInfo, error)
+	// Sessions lists the connected sessions.
+	Sessions() ([]SessionInfo, error)
+	// Kick disconnects all sessions of the user, returning the number of
+	// sessions.
+	Kick(username string, reason string) (int, error)
+	// Notice sends a server notice to all sessions.
+	Notice(message string) error
+	// Members lists the members of the room with their roles.
+	Members() ([]Membership, error)
+	// SetRole sets the role of an authenticated user.
+	SetRole(username string, role Role) error
+	// Ban keeps an authenticated user out until the time (never ends
+	// when zero), disconnecting their sessions.
+	Ban(username string, reason string, until time.Time) error
+	// Unban lifts the ban of the user.
+	Unban(username string) error
+	// Bans lists the active bans.
+	Bans() ([]Ban, error)
+	// Stats returns the hub counters.
+	Stats() (HubStats, error)
+	// Retention reports what the retention policies would purge now,
+	// without purging.
+	Retention() (RetentionReport, error)
+	// Close closes the client.
+	Close() error
+}
+
+// RoomInfo describes a room.
+type RoomInfo struct {
+	Name     string `json:"name"`
+	Users    int    `json:"users"`
+	Sessions int    `json:"sessions"`
+}
+
+// SessionInfo describes a connected session.
+type SessionInfo struct {
+	ID         int    `json:"id"`
+	Room       string `json:"room"`
+	UserID     string `json:"userId"`
+	Username   string `json:"username"`
+	RemoteAddr string `json:"remoteAddr"`
+	Transport  string `json:"transport"`
+	Protocol   int    `json:"protocol"`
+}
+
+// HubStats are the counters of the hub since it started.
+type HubStats struct {
+	StartedAt     time.Time `json:"startedAt"`
+	Users         int       `json:"users"`
+	Sessions      int       `json:"sessions"`
+	Messages      int64     `json:"messages"`
+	EventsIn      int64     `json:"eventsIn"`
+	EventsOut     int64     `json:"eventsOut"`
+	HistoryEvents int       `json:"historyEvents"`
+}
+
+// Rooms returns the rooms of the store, all users and sessions being in
+// DefaultRoom.
+func (h *Hub) Rooms() []RoomInfo {
+	rooms, err := h.store.Rooms()
+	if err != nil {
+		h.logger.Errorw("could not read rooms", log.Error(err))
+		rooms = []string{DefaultRoom}
+	}
+	h.usersMu.RLock()
+	defer h.usersMu.RUnlock()
+	infos := []RoomInfo{}
+	for _, room := range rooms {
+		info := RoomInfo{Name: room}
+		if room == DefaultRoom {
+			info.Users = len(h.userList())
+			info.Sessions = len(h.sessionIds())
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Sessions returns the connected sessions ordered by id.
+func (h *Hub) Sessions() []SessionInfo {
+	h.usersMu.RLock()
+	defer h.usersMu.RUnlock()
+	infos := []SessionInfo{}
+	for _, sessionId := range h.sessionIds() {
+		session, err := h.findSession(sessionId)
+		if err != nil {
+			continue
+		}
+		profile := session.user.getProfile()
+		info := SessionInfo{
+			ID:       sessionId,
+			Room:     DefaultRoom,
+			UserID:   profile.UserID,
+			Username: profile.Name,
+			Protocol: session.getProtocol().Version,
+		}
+		if c, ok := session.conn.(ConnectionInfo); ok {
+			info.RemoteAddr = c.RemoteAddr()
+			info.Transport = c.Transport()
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Kick disconnects all sessions of the user after sending them a
+// notice with the reason. Returns the number of disconnected sessions.
+func (h *Hub) Kick(username string, reason string) (int, error) {
+	h.usersMu.RLock()
+	user, ok := h.findUserByName(username)
+	var sessionIds []hubId
+	if ok {
+		sessionIds = h.userSessionIds(user)
+	}
+	h.usersMu.RUnlock()
+	if !ok {
+		return 0, &ErrUserNotFound{username: username}
+	}
+
+	h.kickSessions(sessionIds, "you were kicked", reason)
+	h.logger.Infow("kicked user", "username", username, "sessions", len(sessionIds))
+	return len(sessionIds), nil
+}
+
+// kickSessions disconnects the sessions after sending them a notice
+// with the message and reason.
+func (h *Hub) kickSessions(sessionIds []hubId, message string, reason string) {
+	if reason != "" {
+		message += ": " + reason
+	}
+	_ = h.sendNotice(message, sessionIds...)
+	var wg sync.WaitGroup
+	for _, sessionId := range sessionIds {
+		sessionId := sessionId
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = h.disconnectSession(sessionId, nil, true)
+		}()
+	}
+	wg.Wait()
+}
+
+// Members returns the members of the room ordered by user ID.
+func (h *Hub) Members() ([]Membership, error) {
+	if err := h.syncStore(); err != nil {
+		return nil, err
+	}
+	return h.store.Members(DefaultRoom)
+}
+
+// SetRole sets the role of the authenticated user, connected or a
+// member of the room.
+func (h *Hub) SetRole(username string, role Role) error {
+	if role != RoleMember && role != RoleAdmin {
+		return &ErrInvalidRole{role: role}
+	}
+	member, _, err := h.findMember(username)
+	if err != nil {
+		return err
+	}
+	if err := h.storeRole(member.UserID, role); err != nil {
+		return err
+	}
+	h.logger.Infow("set role", "username", member.Name, "role", role)
+	return nil
+}
+
+// Ban keeps the authenticated user, connected or a member of the room,
+// out until the time (never ends when zero). Connected sessions of the
+// user get a notice with the reason and are disconnected.
+func (h *Hub) Ban(username string, reason string, until time.Time) error {
+	h.bansMu.Lock()
+	member, sessionIds, err := h.findMember(username)
+	if err == nil {
+		err = h.store.AddBan(Ban{
+			Room:   DefaultRoom,
+			UserID: member.UserID,
+			Name:   member.Name,
+			Reason: reason,
+			Until:  until,
+		})
+	}
+	h.bansMu.Unlock()
+	if err != nil {
+		return err
+	}
+	h.kickSessions(sessionIds, "you were banned", reason)
+	h.logger.Infow("banned user", "username", member.Name, "until", until,
+		"sessions", len(sessionIds))
+	return nil
+}
+
+// Unban lifts the active ban of the user.
+func (h *Hub) Unban(username string) error {
+	bans, err := h.Bans()
+	if err != nil {
+		return err
+	}
+	for _, b := range bans {
+		if SameUsername(b.Name, username) {
+			h.logger.Infow("unbanned user", "username", b.Name)
+			return h.store.RemoveBan(DefaultRoom, b.UserID)
+		}
+	}
+	return &ErrNotBanned{username: username}
+}
+
+// Bans returns the active bans of the room ordered by user ID.
+func (h *Hub) Bans() ([]Ban, error) {
+	bans, err := h.store.Bans(DefaultRoom)
+	if err != nil {
+		return nil, err
+	}
+	now := h.clock.Now()
+	active := []Ban{}
+	for _, b := range bans {
+		if b.Active(now) {
+			active = append(active, b)
+		}
+	}
+	return active, nil
+}
+
+// findMember returns the membership of the authenticated user by name,
+// with the sessions when connected. Only users that connected with a
+// token have a membership.
+func (h *Hub) findMember(username string) (Membership, []hubId, error) {
+	h.usersMu.RLock()
+	user, ok := h.findUserByName(username)
+	var sessionIds []hubId
+	var profile UserProfile
+	if ok {
+		sessionIds = h.userSessionIds(user)
+		profile = user.getProfile()
+	}
+	h.usersMu.RUnlock()
+	if ok {
+		if user.identity == "" {
+			return Membership{}, nil, &ErrNotAuthenticated{username: profile.Name}
+		}
+		return Membership{
+			Room:   DefaultRoom,
+			UserID: profile.UserID,
+			Name:   profile.Name,
+		}, sessionIds, nil
+	}
+
+	members, err := h.Members()
+	if err != nil {
+		return Membership{}, nil, err
+	}
+	for _, m := range members {
+		if SameUsername(m.Name, username) {
+			return m, nil, nil
+		}
+	}
+	return Membership{}, nil, &ErrUserNotFound{username: username}
+}
+
+// Notice sends a server notice to all sessions.
+func (h *Hub) Notice(message string) {
+	h.Broadcast(&EventNotice{
+		EventMeta: *NewEventMetaNow(h.clock),
+		Message:   message,
+	})
+}
+
+// Stats returns the counters of the hub.
+func (h *Hub) Stats() HubStats {
+	h.usersMu.RLock()
+	users := len(h.userList())
+	sessions := len(h.sessionIds())
+	h.usersMu.RUnlock()
+	return HubStats{
+		StartedAt:     h.startedAt,
+		Users:         users,
+		Sessions:      sessions,
+		Messages:      atomic.LoadInt64(&h.msgInc),
+		EventsIn:      atomic.LoadInt64(&h.eventsIn),
+		EventsOut:     atomic.LoadInt64(&h.eventsOut),
+		HistoryEvents: h.history.Len(),
+	}
+}
+
+// RoomsToProto returns the protobuf message for rooms.
+func RoomsToProto(rooms []RoomInfo) *pb.Rooms {
+	p := &pb.Rooms{}
+	for _, r := range rooms {
+		p.Rooms = append(p.Rooms, &pb.Room{
+			Name:     r.Name,
+			Users:    int32(r.Users),
+			Sessions: int32(r.Sessions),
+		})
+	}
+	return p
+}
+
+// RoomsFromProto returns the rooms of the protobuf message.
+func RoomsFromProto(p *pb.Rooms) []RoomInfo {
+	rooms := []RoomInfo{}
+	for _, r := range p.Rooms {
+		rooms = append(rooms, RoomInfo{
+			Name:     r.Name,
+			Users:    int(r.Users),
+			Sessions: int(r.Sessions),
+		})
+	}
+	return rooms
+}
+
+// SessionsToProto returns the protobuf message for sessions.
+func SessionsToProto(sessions []SessionInfo) *pb.Sessions {
+	p := &pb.Sessions{}
+	for _, s := range sessions {
+		p.Sessions = append(p.Sessions, &pb.Session{
+			Id:         int64(s.ID),
+			Room:       s.Room,
+			UserId:     s.UserID,
+			Username:   s.Username,
+			RemoteAddr: s.RemoteAddr,
+			Transport:  s.Transport,
+			Protocol:   int32(s.Protocol),
+		})
+	}
+	return p
+}
+
+// SessionsFromProto returns the sessions of the protobuf message.
+func SessionsFromProto(p *pb.Sessions) []SessionInfo {
+	sessions := []SessionInfo{}
+	for _, s := range p.Sessions {
+		sessions = append(sessions, SessionInfo{
+			ID:         int(s.Id),
+			Room:       s.Room,
+			UserID:     s.UserId,
+			Username:   s.Username,
+			RemoteAddr: s.RemoteAddr,
+			Transport:  s.Transport,
+			Protocol:   int(s.Protocol),
+		})
+	}
+	return sessions
+}
+
+// MembersToProto returns the protobuf message for members.
+func MembersToProto(members []Membership) *pb.Members {
+	p := &pb.Members{}
+	for _, m := range members {
+		p.Members = append(p.Members, &pb.Member{
+			Room:   m.Room,
+			UserId: m.UserID,
+			Name:   m.Name,
+			Role:   string(m.Role),
+			Joined: timestamppb.New(m.Joined),
+		})
+	}
+	return p
+}
+
+// MembersFromProto returns the members of the protobuf message.
+func MembersFromProto(p *pb.Members) []Membership {
+	members := []Membership{}
+	for _, m := range p.Members {
+		members = append(members, Membership{
+			Room:   m.Room,
+			UserID: m.UserId,
+			Name:   m.Name,
+			Role:   Role(m.Role),
+			Joined: m.Joined.AsTime(),
+		})
+	}
+	return members
+}
+
+// BansToProto returns the protobuf message for bans.
+func BansToProto(bans []Ban) *pb.Bans {
+	p := &pb.Bans{}
+	for _, b := range bans {
+		p.Bans = append(p.Bans, &pb.Ban{
+			Room:   b.Room,
+			UserId: b.UserID,
+			Name:   b.Name,
+			Reason: b.Reason,
+			Until:  protoTimeOrNil(b.Until),
+		})
+	}
+	return p
+}
+
+// BansFromProto returns the bans of the protobuf message.
+func BansFromProto(p *pb.Bans) []Ban {
+	bans := []Ban{}
+	for _, b := range p.Bans {
+		bans = append(bans, Ban{
+			Room:   b.Room,
+			UserID: b.UserId,
+			Name:   b.Name,
+			Reason: b.Reason,
+			Until:  timeOrZero(b.Until),
+		})
+	}
+	return bans
+}
+
+// StatsToProto returns the protobuf message for stats.
+func StatsToProto(s HubStats) *pb.Stats {
+	return &pb.Stats{
+		StartedAt:     timestamppb.New(s.StartedAt),
+		Users:         int32(s.Users),
+		Sessions:      int32(s.Sessions),
+		Messages:      s.Messages,
+		EventsIn:      s.EventsIn,
+		EventsOut:     s.EventsOut,
+		HistoryEvents: int32(s.HistoryEvents),
+	}
+}
+
+// StatsFromProto returns the stats of the protobuf message.
+func StatsFromProto(p *pb.Stats) HubStats {
+	return HubStats{
+		StartedAt:     p.StartedAt.AsTime(),
+		Users:         int(p.Users),
+		Sessions:      int(p.Sessions),
+		Messages:      p.Messages,
+		EventsIn:      p.EventsIn,
+		EventsOut:     p.EventsOut,
+		HistoryEvents: int(p.HistoryEvents),
+	}
+}
+
+// ErrNotAuthenticated for when the user connected without token and
+// can not have a role or be banned.
+type ErrNotAuthenticated struct {
+	username string
+}
+
+func (e *ErrNotAuthenticated) Error() string {
+	return fmt.Sprintf(`user "%s" is not authenticated`, e.username)
+}
+
+// ErrNotBanned for when the user has no active ban.
+type ErrNotBanned struct {
+	username string
+}
+
+func (e *ErrNotBanned) Error() string {
+	return fmt.Sprintf(`user "%s" is not banned`, e.username)
+}
+
+// ErrInvalidRole for roles other than RoleMember and RoleAdmin.
+type ErrInvalidRole struct {
+	role Role
+}
+
+func (e *ErrInvalidRole) Error() string {
+	return fmt.Sprintf(`invalid role "%s", expected %s or %s`, e.role, RoleMember, RoleAdmin)
+}
@@ -0,0 +1,38 @@
+package chat
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// AdminAuthorized returns true when the authorization header value is
+// the bearer token for the admin token. Always false without admin token.
+func AdminAuthorized(authorization string, adminToken string) bool {
+	if adminToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	if token == authorization {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// IdentityFromAuthorization returns the identity for the authorization
+// header value, empty without bearer token. Sessions with the same
+// identity belong to the same user.
+func IdentityFromAuthorization(authorization string) string {
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	if token == authorization || token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// identityUserID returns the user ID of the identity.
+func identityUserID(identity string) string {
+	return "u" + identity[:12]
+}
@@ -0,0 +1,385 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltOpenTimeout is how long to wait for the file lock, held by
+// another process using the store.
+const boltOpenTimeout = time.Second
+
+// Buckets of the BoltStore. Members, bans and history have a nested
+// bucket per room.
+var (
+	boltMeta    = []byte("meta")
+	boltRooms   = []byte("rooms")
+	boltMembers = []byte("members")
+	boltBans    = []byte("bans")
+	boltHistory = []byte("history")
+	// boltVersion is the key of the schema version in boltMeta.
+	boltVersion = []byte("version")
+)
+
+// boltMigrations migrate the schema, boltMigrations[i] from version i
+// to i+1. Append new migrations, never change released ones.
+var boltMigrations = []func(tx *bolt.Tx) error{
+	// 1: buckets for members and history.
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltMembers, boltHistory} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 2: buckets for rooms and bans, members get the member role.
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltRooms, boltBans} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		// Values are collected first, like deleteKeys.
+		members := map[string]map[string][]byte{}
+		err := tx.Bucket(boltMembers).ForEach(func(room, _ []byte) error {
+			values := map[string][]byte{}
+			members[string(room)] = values
+			return tx.Bucket(boltMembers).Bucket(room).ForEach(func(k, v []byte) error {
+				var m Membership
+				if err := json.Unmarshal(v, &m); err != nil {
+					return err
+				}
+				m.Role = RoleMember
+				p, err := json.Marshal(&m)
+				values[string(k)] = p
+				return err
+			})
+		})
+		if err != nil {
+			return err
+		}
+		for room, values := range members {
+			b := tx.Bucket(boltMembers).Bucket([]byte(room))
+			for k, p := range values {
+				if err := b.Put([]byte(k), p); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
+
+// boltSchemaVersion is the schema version of new and migrated stores.
+var boltSchemaVersion = len(boltMigrations)
+
+// BoltStore is a Store in a BoltDB file. History events are kept as
+// recording lines (see RecordingConnection) by sequence number, the
+// other values as JSON.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens the store at path, creating it (and its directory)
+// when needed and migrating older schemas. Fails for schemas newer than
+// boltSchemaVersion.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := db.Update(migrateBolt); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// migrateBolt runs the migrations from the version of the store.
+func migrateBolt(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(boltMeta)
+	if err != nil {
+		return err
+	}
+	version := 0
+	if p := meta.Get(boltVersion); p != nil {
+		if version, err = strconv.Atoi(string(p)); err != nil {
+			return fmt.Errorf("invalid schema version: %w", err)
+		}
+	}
+	if version > boltSchemaVersion {
+		return fmt.Errorf(
+			"schema version %d is newer than supported version %d",
+			version, boltSchemaVersion,
+		)
+	}
+	for ; version < boltSchemaVersion; version++ {
+		if err := boltMigrations[version](tx); err != nil {
+			return fmt.Errorf("migrate to schema version %d: %w", version+1, err)
+		}
+	}
+	return meta.Put(boltVersion, []byte(strconv.Itoa(version)))
+}
+
+// Version returns the schema version of the store.
+func (s *BoltStore) Version() (int, error) {
+	version := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = strconv.Atoi(string(tx.Bucket(boltMeta).Get(boltVersion)))
+		return err
+	})
+	return version, err
+}
+
+func (s *BoltStore) AddRoom(room string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltRooms).Put([]byte(room), []byte{})
+	})
+}
+
+func (s *BoltStore) Rooms() ([]string, error) {
+	rooms := []string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltRooms).ForEach(func(k, _ []byte) error {
+			rooms = append(rooms, string(k))
+			return nil
+		})
+	})
+	return rooms, err
+}
+
+func (s *BoltStore) SetMember(m Membership) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx, boltMembers, m.Room, m.UserID, &m)
+	})
+}
+
+func (s *BoltStore) Member(room string, userID string) (Membership, bool, error) {
+	var m Membership
+	var ok bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ok, err = getJSON(tx, boltMembers, room, userID, &m)
+		return err
+	})
+	return m, ok, err
+}
+
+func (s *BoltStore) Members(room string) ([]Membership, error) {
+	members := []Membership{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return forEachJSON(tx, boltMembers, room, func(p []byte) error {
+			var m Membership
+			if err := json.Unmarshal(p, &m); err != nil {
+				return err
+			}
+			members = append(members, m)
+			return nil
+		})
+	})
+	return members, err
+}
+
+func (s *BoltStore) SetRole(room string, userID string, role Role) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var m Membership
+		ok, err := getJSON(tx, boltMembers, room, userID, &m)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &ErrNotMember{room: room, userID: userID}
+		}
+		m.Role = role
+		return putJSON(tx, boltMembers, room, userID, &m)
+	})
+}
+
+func (s *BoltStore) AddBan(b Ban) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx, boltBans, b.Room, b.UserID, &b)
+	})
+}
+
+func (s *BoltStore) RemoveBan(room string, userID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBans).Bucket([]byte(room))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(userID))
+	})
+}
+
+func (s *BoltStore) Bans(room string) ([]Ban, error) {
+	bans := []Ban{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return forEachJSON(tx, boltBans, room, func(p []byte) error {
+			var b Ban
+			if err := json.Unmarshal(p, &b); err != nil {
+				return err
+			}
+			bans = append(bans, b)
+			return nil
+		})
+	})
+	return bans, err
+}
+
+func (s *BoltStore) AddHistory(room string, events []Event, limit int) error {
+	lines := make([][]byte, 0, len(events))
+	for _, e := range events {
+		line, err := encodeRecordLine(e.When(), e)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(boltHistory).CreateBucketIfNotExists([]byte(room))
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := b.Put(boltSeqKey(seq), line); err != nil {
+				return err
+			}
+		}
+		seq := b.Sequence()
+		if limit <= 0 || seq <= uint64(limit) {
+			return nil
+		}
+		// Keys are sequence numbers in order, so the events over limit
+		// are the keys before the cutoff, without walking the others.
+		cutoff := boltSeqKey(seq - uint64(limit) + 1)
+		keys := [][]byte{}
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.Next() {
+			keys = append(keys, k)
+		}
+		return deleteKeys(b, keys)
+	})
+}
+
+func (s *BoltStore) History(room string) ([]Event, error) {
+	events := []Event{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltHistory).Bucket([]byte(room))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			re, err := decodeRecordLine(v)
+			if err != nil {
+				return err
+			}
+			events = append(events, re.Event)
+			return nil
+		})
+	})
+	return events, err
+}
+
+func (s *BoltStore) RemoveHistory(room string, remove func(e Event) bool) (int, error) {
+	n := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltHistory).Bucket([]byte(room))
+		if b == nil {
+			return nil
+		}
+		keys := [][]byte{}
+		err := b.ForEach(func(k, v []byte) error {
+			re, err := decodeRecordLine(v)
+			if err != nil {
+				return err
+			}
+			if remove(re.Event) {
+				keys = append(keys, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		n = len(keys)
+		return deleteKeys(b, keys)
+	})
+	return n, err
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// putJSON puts v as JSON by key in the room bucket of the bucket.
+func putJSON(tx *bolt.Tx, bucket []byte, room string, key string, v any) error {
+	b, err := tx.Bucket(bucket).CreateBucketIfNotExists([]byte(room))
+	if err != nil {
+		return err
+	}
+	p, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), p)
+}
+
+// getJSON reads the JSON by key in the room bucket of the bucket into v,
+// returning false when not found.
+func getJSON(tx *bolt.Tx, bucket []byte, room string, key string, v any) (bool, error) {
+	b := tx.Bucket(bucket).Bucket([]byte(room))
+	if b == nil {
+		return false, nil
+	}
+	p := b.Get([]byte(key))
+	if p == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(p, v)
+}
+
+// forEachJSON calls fn with the values in the room bucket of the bucket,
+// ordered by key.
+func forEachJSON(tx *bolt.Tx, bucket []byte, room string, fn func(p []byte) error) error {
+	b := tx.Bucket(bucket).Bucket([]byte(room))
+	if b == nil {
+		return nil
+	}
+	return b.ForEach(func(_, v []byte) error { return fn(v) })
+}
+
+// boltSeqKey returns the key of the sequence number, big endian so keys
+// sort by number.
+func boltSeqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// deleteKeys deletes the keys, collected first as deleting while
+// iterating with a cursor skips keys.
+func deleteKeys(b *bolt.Bucket, keys [][]byte) error {
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
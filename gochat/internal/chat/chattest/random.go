@@ -0,0 +1,76 @@
+// Package chattest implements test helpers for chat events.
+package chattest
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+)
+
+var randomRunes = []rune("abcdefghijklmnopqrstuvwxyzABC0123456789 @#.-_éü日本😀")
+
+// NewRandomEvent returns an event of the type with random field values,
+// for property testing transports. Panics on field types it can not
+// generate, so new events with new field types fail loudly.
+func NewRandomEvent(r *rand.Rand, t *chat.EventType) chat.Event {
+	e := t.New()
+	randomValue(r, reflect.ValueOf(e).Elem())
+	return e
+}
+
+func randomValue(r *rand.Rand, v reflect.Value) {
+	switch v.Interface().(type) {
+	case map[string]string:
+		// Trace context is kept by envelopes only, tested separately.
+		return
+	case time.Time:
+		// Wire formats keep UTC time with nanoseconds.
+		t := time.Unix(r.Int63n(1<<33), r.Int63n(int64(time.Second))).UTC()
+		v.Set(reflect.ValueOf(t))
+		return
+	case []chat.Event:
+		// Events without nested events, so generating ends.
+		types := []*chat.EventType{}
+		for _, t := range chat.Events.Types() {
+			if _, ok := t.New().(*chat.EventHistory); !ok {
+				types = append(types, t)
+			}
+		}
+		events := make([]chat.Event, 1+r.Intn(3))
+		for i := range events {
+			events[i] = NewRandomEvent(r, types[r.Intn(len(types))])
+		}
+		v.Set(reflect.ValueOf(events))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			randomValue(r, v.Field(i))
+		}
+	case reflect.String:
+		runes := make([]rune, r.Intn(20))
+		for i := range runes {
+			runes[i] = randomRunes[r.Intn(len(randomRunes))]
+		}
+		v.SetString(string(runes))
+	case reflect.Bool:
+		v.SetBool(r.Intn(2) == 1)
+	case reflect.Int:
+		v.SetInt(int64(r.Intn(1000)))
+	case reflect.Slice:
+		// Not empty, wire formats don't tell nil and empty slices apart.
+		n := 1 + r.Intn(3)
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < s.Len(); i++ {
+			randomValue(r, s.Index(i))
+		}
+		v.Set(s)
+	default:
+		panic(fmt.Sprintf("can not generate random %s", v.Type()))
+	}
+}
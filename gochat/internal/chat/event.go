@@ -1,9 +1,12 @@
 package chat
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"time"
 
-	"github.com/marcelbeumer/go-playground/gochat/internal/util/now"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
 )
 
 // Event is the interface for all events
@@ -18,6 +21,9 @@ type Event interface {
 // basic meta data that all events have
 type EventMeta struct {
 	Time time.Time `json:"time"`
+	// Trace is the trace context of the event, carried in the
+	// transport envelope. See StartEventSpan.
+	Trace map[string]string `json:"-"`
 }
 
 // When returns the time of the event.
@@ -25,18 +31,35 @@ func (e *EventMeta) When() time.Time {
 	return e.Time
 }
 
-// NewEventMetaNow returns EventMeta with time set to "now".
-func NewEventMetaNow() *EventMeta {
+// Meta returns the EventMeta.
+func (e *EventMeta) Meta() *EventMeta {
+	return e
+}
+
+// NewEventMetaNow returns EventMeta with time set to "now" of the clock.
+func NewEventMetaNow(c clock.Clock) *EventMeta {
 	return &EventMeta{
-		Time: now.Now(),
+		Time: c.Now(),
 	}
 }
 
+// EventHello is the protocol handshake. Clients send it after connecting
+// to announce their protocol version and features, the hub replies
+// with the negotiated version and features.
+type EventHello struct {
+	EventMeta
+	Version  int      `json:"version"`
+	Features []string `json:"features"`
+}
+
 // EventConnected is (guaranteed) the first event sent when
 // a new connection is made
 type EventConnected struct {
 	EventMeta
 	Users []string `json:"users"`
+	// LastRead is the ID of the last message the user read, see
+	// EventMarkRead. Empty when unknown.
+	LastRead string `json:"lastRead,omitempty"`
 }
 
 type EventUserListUpdate struct {
@@ -61,6 +84,228 @@ type EventSendMessage struct {
 
 type EventNewMessage struct {
 	EventMeta
+	// ID is unique per hub, see EventMessageEnrichment.
+	ID      string `json:"id,omitempty"`
 	Sender  string `json:"sender"`
 	Message string `json:"message"`
+	// Mentioned is true when the receiver is mentioned in the message.
+	Mentioned bool `json:"mentioned,omitempty"`
+}
+
+// EventMessageEnrichment adds a link preview to the message with the ID.
+type EventMessageEnrichment struct {
+	EventMeta
+	MessageID   string `json:"messageId"`
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+func (e *EventMessageEnrichment) Feature() string {
+	return FeaturePreviews
+}
+
+// UserProfile is the public profile of a user.
+type UserProfile struct {
+	// UserID is stable across nick changes, and across sessions
+	// for authenticated users.
+	UserID string `json:"userId"`
+	// Name is the display name.
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Color is a Color value, empty for the default color.
+	Color string `json:"color"`
+}
+
+// EventChangeNick requests to change the display name of the sender.
+type EventChangeNick struct {
+	EventMeta
+	Name string `json:"name"`
+}
+
+// EventNickChange is sent to all users when a user changed name.
+type EventNickChange struct {
+	EventMeta
+	UserID  string `json:"userId"`
+	OldName string `json:"oldName"`
+	NewName string `json:"newName"`
+}
+
+// EventSetProfile replaces the status and color of the sender profile.
+type EventSetProfile struct {
+	EventMeta
+	Status string `json:"status"`
+	Color  string `json:"color"`
+}
+
+// EventWhois requests the profile of the user with the name.
+type EventWhois struct {
+	EventMeta
+	Name string `json:"name"`
+}
+
+// EventProfile is the reply to EventWhois and EventSetProfile.
+type EventProfile struct {
+	EventMeta
+	UserProfile
+}
+
+// EventNotice is a message from the hub to a single user, like the
+// error of a request.
+type EventNotice struct {
+	EventMeta
+	Message string `json:"message"`
+}
+
+// EventMarkRead marks the message with the ID and the messages before
+// it as read by the user.
+type EventMarkRead struct {
+	EventMeta
+	MessageID string `json:"messageId"`
+}
+
+// ReadMarker is the ID of the last message a user read.
+type ReadMarker struct {
+	UserID    string `json:"userId"`
+	Name      string `json:"name"`
+	MessageID string `json:"messageId"`
+}
+
+// EventReadState is the read state of the online users, sent when it
+// changes.
+type EventReadState struct {
+	EventMeta
+	Markers []ReadMarker `json:"markers"`
+}
+
+func (e *EventReadState) Feature() string {
+	return FeatureReads
+}
+
+// EventMessagesDeleted tells that messages were deleted from the
+// history, for example by a retention policy.
+type EventMessagesDeleted struct {
+	EventMeta
+	MessageIDs []string `json:"messageIds"`
+	Reason     string   `json:"reason,omitempty"`
+}
+
+func (e *EventMessagesDeleted) Feature() string {
+	return FeatureDeletions
+}
+
+// EventOfflineBacklog precedes the events an authenticated user missed
+// while offline, like mentions, delivered after negotiating the protocol.
+type EventOfflineBacklog struct {
+	EventMeta
+	// Count is the number of events that follow.
+	Count int `json:"count"`
+	// Since is the time of the oldest event.
+	Since time.Time `json:"since"`
+}
+
+func (e *EventOfflineBacklog) Feature() string {
+	return FeatureOffline
+}
+
+// EventHistoryRequest requests the room history between From and To.
+// Zero times are unbounded.
+type EventHistoryRequest struct {
+	EventMeta
+	// ID is returned in EventHistory.RequestID.
+	ID   string    `json:"id"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// EventHistory is the reply to EventHistoryRequest.
+type EventHistory struct {
+	EventMeta
+	RequestID string `json:"requestId"`
+	// Events are the room events: EventUserEnter, EventUserLeave,
+	// EventNickChange and EventNewMessage.
+	Events []Event `json:"-"`
+	// Error is why the request was denied, without events.
+	Error string `json:"error,omitempty"`
+}
+
+// historyJSON is the JSON of EventHistory, with named events.
+type historyJSON struct {
+	EventMeta
+	RequestID string       `json:"requestId"`
+	Events    []NamedEvent `json:"events"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes the events by their wire name.
+func (e *EventHistory) MarshalJSON() ([]byte, error) {
+	h := historyJSON{
+		EventMeta: e.EventMeta,
+		RequestID: e.RequestID,
+		Events:    make([]NamedEvent, len(e.Events)),
+		Error:     e.Error,
+	}
+	for i, event := range e.Events {
+		h.Events[i] = NamedEvent{Event: event}
+	}
+	return json.Marshal(&h)
+}
+
+// UnmarshalJSON decodes the events by their wire name.
+func (e *EventHistory) UnmarshalJSON(data []byte) error {
+	var h historyJSON
+	if err := json.Unmarshal(data, &h); err != nil {
+		return err
+	}
+	e.EventMeta = h.EventMeta
+	e.RequestID = h.RequestID
+	e.Error = h.Error
+	e.Events = make([]Event, len(h.Events))
+	for i, event := range h.Events {
+		e.Events[i] = event.Event
+	}
+	return nil
+}
+
+// NamedEvent is the JSON of an event with its wire name:
+// {"name": ..., "data": ...}.
+type NamedEvent struct {
+	Event Event
+}
+
+type namedEventJSON struct {
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data"`
+}
+
+// MarshalJSON encodes the event with its wire name.
+func (e NamedEvent) MarshalJSON() ([]byte, error) {
+	t, ok := Events.ByEvent(e.Event)
+	if !ok {
+		return nil, fmt.Errorf("unknown event type <%s>", reflect.TypeOf(e.Event))
+	}
+	data, err := json.Marshal(e.Event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&namedEventJSON{Name: t.Name, Data: data})
+}
+
+// UnmarshalJSON decodes the event by its wire name.
+// Returns ErrUnknownEvent for unknown names.
+func (e *NamedEvent) UnmarshalJSON(data []byte) error {
+	var named namedEventJSON
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	t, ok := Events.ByName(named.Name)
+	if !ok {
+		return &ErrUnknownEvent{Name: named.Name}
+	}
+	event := t.New()
+	if err := json.Unmarshal(named.Data, event); err != nil {
+		return err
+	}
+	e.Event = event
+	return nil
 }
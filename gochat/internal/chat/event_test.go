@@ -5,7 +5,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/marcelbeumer/go-playground/gochat/internal/util/now"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -18,12 +18,9 @@ func TestEventMetaWhen(t *testing.T) {
 }
 
 func TestNewEventMetaNow(t *testing.T) {
-	nowStub := now.SetupStub()
-	t.Cleanup(func() {
-		now.ClearStub()
-	})
-	m := NewEventMetaNow()
-	assert.Equal(t, nowStub.Time, m.Time)
+	fake := clock.NewFake(time.UnixMilli(0))
+	m := NewEventMetaNow(fake)
+	assert.Equal(t, fake.Now(), m.Time)
 }
 
 func TestEventUserListUpdateJSON(t *testing.T) {
@@ -0,0 +1,244 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	ExportMarkdown = "markdown"
+	ExportText     = "text"
+	ExportJSONL    = "jsonl"
+	ExportHTML     = "html"
+)
+
+// ExportFormats are the supported export formats.
+var ExportFormats = []string{ExportMarkdown, ExportText, ExportJSONL, ExportHTML}
+
+// ExportFormatFromPath returns the export format by file extension,
+// text for unknown extensions.
+func ExportFormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return ExportMarkdown
+	case ".jsonl", ".ndjson":
+		return ExportJSONL
+	case ".html", ".htm":
+		return ExportHTML
+	default:
+		return ExportText
+	}
+}
+
+// exportTimeFormat is the time format of the human readable exports.
+const exportTimeFormat = "2006-01-02 15:04:05"
+
+// Export writes the room history events in the format.
+func Export(w io.Writer, events []Event, format string) error {
+	switch format {
+	case ExportMarkdown:
+		return exportMarkdown(w, events)
+	case ExportText:
+		return exportText(w, events)
+	case ExportJSONL:
+		return exportJSONL(w, events)
+	case ExportHTML:
+		return exportHTML(w, events)
+	default:
+		return fmt.Errorf(`unknown export format "%s"`, format)
+	}
+}
+
+// exportFile writes the events in the format to the file at path.
+func exportFile(path string, events []Event, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Export(f, events, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func exportTime(t time.Time) string {
+	return t.Local().Format(exportTimeFormat)
+}
+
+func exportText(w io.Writer, events []Event) error {
+	for _, e := range events {
+		var err error
+		switch t := e.(type) {
+		case *EventUserEnter:
+			_, err = fmt.Fprintf(w, "[%s] <<user \"%s\" entered the room>>\n", exportTime(t.Time), t.Name)
+		case *EventUserLeave:
+			_, err = fmt.Fprintf(w, "[%s] <<user \"%s\" left the room>>\n", exportTime(t.Time), t.Name)
+		case *EventNickChange:
+			_, err = fmt.Fprintf(w, "[%s] <<user \"%s\" is now known as \"%s\">>\n",
+				exportTime(t.Time), t.OldName, t.NewName)
+		case *EventNewMessage:
+			_, err = fmt.Fprintf(w, "[%s %s] >> %s\n", exportTime(t.Time), t.Sender, t.Message)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownEscaper escapes characters with meaning in Markdown text.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+func exportMarkdown(w io.Writer, events []Event) error {
+	if _, err := fmt.Fprint(w, "# Chat transcript\n\n"); err != nil {
+		return err
+	}
+	for _, e := range events {
+		var err error
+		switch t := e.(type) {
+		case *EventUserEnter:
+			_, err = fmt.Fprintf(w, "- `%s` _%s entered the room_\n",
+				exportTime(t.Time), markdownEscaper.Replace(t.Name))
+		case *EventUserLeave:
+			_, err = fmt.Fprintf(w, "- `%s` _%s left the room_\n",
+				exportTime(t.Time), markdownEscaper.Replace(t.Name))
+		case *EventNickChange:
+			_, err = fmt.Fprintf(w, "- `%s` _%s is now known as %s_\n",
+				exportTime(t.Time),
+				markdownEscaper.Replace(t.OldName),
+				markdownEscaper.Replace(t.NewName))
+		case *EventNewMessage:
+			// Continuation lines are indented to stay in the list item.
+			message := strings.ReplaceAll(
+				markdownEscaper.Replace(t.Message), "\n", "  \n  ")
+			_, err = fmt.Fprintf(w, "- `%s` **%s**: %s\n",
+				exportTime(t.Time), markdownEscaper.Replace(t.Sender), message)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportJSONL(w io.Writer, events []Event) error {
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(NamedEvent{Event: e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportHTML(w io.Writer, events []Event) error {
+	_, err := fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Chat transcript</title>
+<style>
+body { font-family: sans-serif; }
+.time { color: #888; font-family: monospace; }
+.presence { color: #888; font-style: italic; }
+.message { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Chat transcript</h1>
+<ul>
+`)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		switch t := e.(type) {
+		case *EventUserEnter:
+			_, err = fmt.Fprintf(w,
+				"<li><span class=\"time\">%s</span> <span class=\"presence\">%s entered the room</span></li>\n",
+				exportTime(t.Time), html.EscapeString(t.Name))
+		case *EventUserLeave:
+			_, err = fmt.Fprintf(w,
+				"<li><span class=\"time\">%s</span> <span class=\"presence\">%s left the room</span></li>\n",
+				exportTime(t.Time), html.EscapeString(t.Name))
+		case *EventNickChange:
+			_, err = fmt.Fprintf(w,
+				"<li><span class=\"time\">%s</span> <span class=\"presence\">%s is now known as %s</span></li>\n",
+				exportTime(t.Time), html.EscapeString(t.OldName), html.EscapeString(t.NewName))
+		case *EventNewMessage:
+			_, err = fmt.Fprintf(w,
+				"<li><span class=\"time\">%s</span> <strong>%s</strong>: <span class=\"message\">%s</span></li>\n",
+				exportTime(t.Time), html.EscapeString(t.Sender), html.EscapeString(t.Message))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprint(w, "</ul>\n</body>\n</html>\n")
+	return err
+}
+
+// ParseExportTime parses a time range bound: RFC 3339, a date
+// (2006-01-02), a local date and time (2006-01-02 15:04) or a duration
+// before now (24h). Empty is the zero time.
+func ParseExportTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf(`invalid time "%s"`, s)
+}
+
+// ExportCommand is a parsed /export command.
+type ExportCommand struct {
+	Path   string
+	Format string
+	From   time.Time
+	To     time.Time
+}
+
+// ParseExportCommand parses "/export <file> [from] [to]", see
+// ParseExportTime for the time formats. The format is by file extension.
+func ParseExportCommand(input string, now time.Time) (*ExportCommand, error) {
+	args := strings.Fields(input)
+	if len(args) < 2 || len(args) > 4 || args[0] != "/export" {
+		return nil, fmt.Errorf("usage: /export <file> [from] [to]")
+	}
+	cmd := &ExportCommand{
+		Path:   args[1],
+		Format: ExportFormatFromPath(args[1]),
+	}
+	var err error
+	if len(args) > 2 {
+		if cmd.From, err = ParseExportTime(args[2], now); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) > 3 {
+		if cmd.To, err = ParseExportTime(args[3], now); err != nil {
+			return nil, err
+		}
+	}
+	return cmd, nil
+}
@@ -0,0 +1,122 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTranscript() []Event {
+	at := func(sec int64) EventMeta { return EventMeta{Time: time.Unix(sec, 0).UTC()} }
+	return []Event{
+		&EventUserEnter{EventMeta: at(1), Name: "user1"},
+		&EventNewMessage{EventMeta: at(2), Sender: "user1", Message: "hi <b>*all*</b>"},
+		&EventUserLeave{EventMeta: at(3), Name: "user1"},
+	}
+}
+
+func TestExport(t *testing.T) {
+	events := testTranscript()
+	ts := func(sec int64) string { return exportTime(time.Unix(sec, 0)) }
+
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, events, ExportText))
+		assert.Equal(t, "["+ts(1)+"] <<user \"user1\" entered the room>>\n"+
+			"["+ts(2)+" user1] >> hi <b>*all*</b>\n"+
+			"["+ts(3)+"] <<user \"user1\" left the room>>\n", buf.String())
+	})
+
+	t.Run("markdown escapes", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, events, ExportMarkdown))
+		assert.Contains(t, buf.String(), "- `"+ts(2)+"` **user1**: hi \\<b\\>\\*all\\*\\</b\\>\n")
+		assert.Contains(t, buf.String(), "- `"+ts(3)+"` _user1 left the room_\n")
+	})
+
+	t.Run("html escapes", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, events, ExportHTML))
+		assert.Contains(t, buf.String(), "hi &lt;b&gt;*all*&lt;/b&gt;")
+		assert.NotContains(t, buf.String(), "<b>")
+	})
+
+	t.Run("jsonl round trips", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, events, ExportJSONL))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, len(events))
+		for i, line := range lines {
+			var named NamedEvent
+			require.NoError(t, json.Unmarshal([]byte(line), &named))
+			assert.Equal(t, events[i], named.Event)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		assert.Error(t, Export(&bytes.Buffer{}, events, "pdf"))
+	})
+}
+
+func TestExportFormatFromPath(t *testing.T) {
+	assert.Equal(t, ExportMarkdown, ExportFormatFromPath("room.md"))
+	assert.Equal(t, ExportJSONL, ExportFormatFromPath("room.jsonl"))
+	assert.Equal(t, ExportHTML, ExportFormatFromPath("room.HTML"))
+	assert.Equal(t, ExportText, ExportFormatFromPath("room.log"))
+	assert.Equal(t, ExportText, ExportFormatFromPath(""))
+}
+
+func TestParseExportCommand(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cmd, err := ParseExportCommand("/export room.md 2h 2022-06-01T11:30:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, &ExportCommand{
+		Path:   "room.md",
+		Format: ExportMarkdown,
+		From:   now.Add(-2 * time.Hour),
+		To:     time.Date(2022, 6, 1, 11, 30, 0, 0, time.UTC),
+	}, cmd)
+
+	cmd, err = ParseExportCommand("/export room.txt", now)
+	require.NoError(t, err)
+	assert.True(t, cmd.From.IsZero())
+	assert.True(t, cmd.To.IsZero())
+
+	_, err = ParseExportCommand("/export", now)
+	assert.Error(t, err)
+	_, err = ParseExportCommand("/export room.md yesterday", now)
+	assert.Error(t, err)
+}
+
+func TestHubHistoryRequest(t *testing.T) {
+	hub := NewHub(test.NewTestLogger(true), HubOpts{AdminToken: "secret"})
+	t.Cleanup(func() { _ = hub.Close() })
+
+	guestIn := make(chan Event)
+	guestOut := make(chan Event, 10)
+	_, err := hub.Connect("guest", NewTestConnection(guestIn, guestOut))
+	require.NoError(t, err)
+	guestIn <- &EventHistoryRequest{ID: "export-1"}
+	history := nextEvent[*EventHistory](t, guestOut)
+	assert.Equal(t, "export-1", history.RequestID)
+	assert.Equal(t, "history requires the admin token", history.Error)
+	assert.Empty(t, history.Events)
+
+	adminIn := make(chan Event)
+	adminOut := make(chan Event, 10)
+	admin := IdentityFromAuthorization("Bearer secret")
+	_, err = hub.ConnectIdentity(admin, "admin", NewTestConnection(adminIn, adminOut))
+	require.NoError(t, err)
+	adminIn <- &EventHistoryRequest{ID: "export-2"}
+	history = nextEvent[*EventHistory](t, adminOut)
+	assert.Equal(t, "export-2", history.RequestID)
+	assert.Empty(t, history.Error)
+	assert.Len(t, history.Events, 2) // both users entered
+}
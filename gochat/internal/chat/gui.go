@@ -2,21 +2,89 @@ package chat
 
 import (
 	"fmt"
-	"io"
 	"reflect"
+	"strings"
 	"sync"
+	"unicode"
 
 	"github.com/awesome-gocui/gocui"
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
 	"github.com/marcelbeumer/go-playground/gochat/internal/util/channel"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
 )
 
+// GUIFrontendOpts are the options for NewGUIFrontend.
+type GUIFrontendOpts struct {
+	// Username of the connected user, used to ignore own messages
+	// when counting unread messages. Follows nick changes.
+	Username string
+	// Notifier is called for new messages, optional.
+	Notifier *Notifier
+	// Config is the theming and layout config,
+	// DefaultGUIConfig() when nil.
+	Config *GUIConfig
+	// Clock for event times, clock.Real when nil.
+	Clock clock.Clock
+}
+
 type GUIFrontend struct {
 	logger log.Logger
 	conn   Connection
 	gui    *gocui.Gui
+	opts   GUIFrontendOpts
+	clock  clock.Clock
+	// config is the current config, see SetConfig.
+	config   *GUIConfig
+	configMu sync.RWMutex
+	// unread and mentions count the messages since the user last
+	// sent a message or activated the messages view.
+	// Only to be accessed from the gui goroutine.
+	unread   int
+	mentions int
+	// users is the last received user list, used for tab completion.
+	// Only to be accessed from the gui goroutine.
+	users []string
+	// history of sent inputs. Only to be accessed from the gui goroutine.
+	history inputHistory
+	// lines are the lines of the messages view, lineIDs the message ID
+	// of each line (empty for other lines) and messageLines the index of
+	// the last line of each message by ID, to add link previews under
+	// it. Only to be accessed from the gui goroutine.
+	lines        []string
+	lineIDs      []string
+	messageLines map[string]int
+	// reads is true when the hub supports read markers. lastMessage is
+	// the ID of the last message shown and lastMarked the last one
+	// marked as read. unreadFrom is the last read message on connect,
+	// to draw the new messages separator after. Only to be accessed
+	// from the gui goroutine.
+	reads       bool
+	lastMessage string
+	lastMarked  string
+	unreadFrom  string
+	// exports are the /export commands waiting for EventHistory,
+	// by request id.
+	exports   map[string]*ExportCommand
+	exportsMu sync.Mutex
+	exportInc int
+	// self is the profile of the connected user, requested with
+	// EventWhois on connect and updated by EventProfile and
+	// EventNickChange. selfPending hides the reply to that request.
+	self        UserProfile
+	selfPending bool
+	selfMu      sync.Mutex
 }
 
+// newMessagesSeparator is drawn before the first unread message after
+// connecting.
+const newMessagesSeparator = "\x1b[2m──── new messages ────\x1b[0m"
+
+// maxInputLines is the max height of the input view.
+const maxInputLines = 5
+
+// mouseScrollLines is the amount of lines to scroll per mouse wheel event.
+const mouseScrollLines = 3
+
 func (f *GUIFrontend) Start() error {
 	g := f.gui
 	g.Mouse = true
@@ -68,6 +136,55 @@ func (f *GUIFrontend) Start() error {
 		return err
 	}
 
+	err = g.SetKeybinding("input",
+		gocui.KeyTab,
+		gocui.ModNone,
+		f.completeInput,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding(
+		"",
+		gocui.KeyPgup,
+		gocui.ModNone,
+		f.scrollMessagesPage(-1),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding(
+		"",
+		gocui.KeyPgdn,
+		gocui.ModNone,
+		f.scrollMessagesPage(1),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding(
+		"messages",
+		gocui.MouseWheelUp,
+		gocui.ModNone,
+		f.scrollMessagesLines(-mouseScrollLines),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding(
+		"messages",
+		gocui.MouseWheelDown,
+		gocui.ModNone,
+		f.scrollMessagesLines(mouseScrollLines),
+	)
+	if err != nil {
+		return err
+	}
+
 	stop := make(chan struct{})
 	defer close(stop)
 
@@ -108,10 +225,28 @@ func (f *GUIFrontend) eventPump(stop <-chan struct{}) error {
 		}
 
 		switch t := e.(type) {
+		case *EventHello:
+			logger.Debugw("protocol negotiated", "version", t.Version, "features", t.Features)
+			reads := false
+			for _, feature := range t.Features {
+				reads = reads || feature == FeatureReads
+			}
+			f.gui.Update(func(g *gocui.Gui) error {
+				f.reads = reads
+				return nil
+			})
 		case *EventConnected:
+			lastRead := t.LastRead
+			f.gui.Update(func(g *gocui.Gui) error {
+				f.unreadFrom = lastRead
+				return nil
+			})
 			if err := f.setUsers(t.Users); err != nil {
 				return err
 			}
+			if err := f.requestSelf(); err != nil {
+				return err
+			}
 		case *EventUserListUpdate:
 			if err := f.setUsers(t.Users); err != nil {
 				return err
@@ -119,7 +254,7 @@ func (f *GUIFrontend) eventPump(stop <-chan struct{}) error {
 		case *EventUserEnter:
 			msg := fmt.Sprintf(
 				"[%s] <<user \"%s\" entered the room>>",
-				t.Time.Local(),
+				f.Config().FormatTime(t.Time),
 				t.Name,
 			)
 			if err := f.addMessageLine(msg); err != nil {
@@ -128,17 +263,60 @@ func (f *GUIFrontend) eventPump(stop <-chan struct{}) error {
 		case *EventUserLeave:
 			msg := fmt.Sprintf(
 				"[%s] <<user \"%s\" left the room>>",
-				t.Time.Local(),
+				f.Config().FormatTime(t.Time),
 				t.Name,
 			)
 			if err := f.addMessageLine(msg); err != nil {
 				return err
 			}
 		case *EventNewMessage:
+			if err := f.addMessage(t.ID, f.formatMessage(t)); err != nil {
+				return err
+			}
+			if t.Sender != f.getSelf().Name {
+				f.countUnread(t.Mentioned)
+			}
+			if f.opts.Notifier != nil {
+				if err := f.opts.Notifier.Notify(t); err != nil {
+					logger.Warnw("could not notify", log.Error(err))
+				}
+			}
+		case *EventMessageEnrichment:
+			if err := f.addPreview(t); err != nil {
+				return err
+			}
+		case *EventHistory:
+			if err := f.finishExport(t); err != nil {
+				return err
+			}
+		case *EventNickChange:
+			if err := f.handleNickChange(t); err != nil {
+				return err
+			}
+		case *EventProfile:
+			if err := f.handleProfile(t); err != nil {
+				return err
+			}
+		case *EventReadState:
+			logger.Debugw("read state", "markers", t.Markers)
+		case *EventMessagesDeleted:
+			if err := f.deleteMessages(t); err != nil {
+				return err
+			}
+		case *EventOfflineBacklog:
 			msg := fmt.Sprintf(
-				"[%s %s] >> %s",
-				t.Time.Local(),
-				t.Sender,
+				"[%s] <<%d missed while offline, since %s>>",
+				f.Config().FormatTime(t.Time),
+				t.Count,
+				f.Config().FormatTime(t.Since),
+			)
+			if err := f.addMessageLine(msg); err != nil {
+				return err
+			}
+		case *EventNotice:
+			msg := fmt.Sprintf(
+				"[%s] <<%s>>",
+				f.Config().FormatTime(t.Time),
 				t.Message,
 			)
 			if err := f.addMessageLine(msg); err != nil {
@@ -152,45 +330,266 @@ func (f *GUIFrontend) eventPump(stop <-chan struct{}) error {
 	}
 }
 
+// formatMessage formats e as message line, using the sender color or
+// the mention color when the user is mentioned.
+func (f *GUIFrontend) formatMessage(e *EventNewMessage) string {
+	config := f.Config()
+	prefix := fmt.Sprintf("[%s %s]", config.FormatTime(e.Time), e.Sender)
+	if e.Mentioned {
+		prefix = config.Colors.Mention.ANSI() + "\x1b[1m" + prefix + "\x1b[0m"
+	} else if color := config.SenderColor(e.Sender).ANSI(); color != "" {
+		prefix = color + prefix + "\x1b[0m"
+	}
+	return fmt.Sprintf("%s >> %s", prefix, FormatMarkdownANSI(e.Message))
+}
+
+// Config returns the current config.
+func (f *GUIFrontend) Config() *GUIConfig {
+	f.configMu.RLock()
+	defer f.configMu.RUnlock()
+	return f.config
+}
+
+// SetConfig replaces the config, for example when the config file
+// was reloaded. Colors apply to new messages only.
+func (f *GUIFrontend) SetConfig(config *GUIConfig) {
+	f.configMu.Lock()
+	f.config = config
+	f.configMu.Unlock()
+	f.gui.Update(func(g *gocui.Gui) error {
+		if v := g.CurrentView(); v != nil {
+			return f.activateView(g, v)
+		}
+		return nil
+	})
+}
+
 func (f *GUIFrontend) setUsers(usernames []string) error {
-	g := f.gui
+	f.gui.Update(func(g *gocui.Gui) error {
+		f.users = usernames
+		return f.renderUsers(g)
+	})
+	return nil
+}
+
+// renderUsers renders the user list, when the users view is not hidden.
+func (f *GUIFrontend) renderUsers(g *gocui.Gui) error {
 	v, err := g.View("users")
+	if err == gocui.ErrUnknownView {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	g.Update(func(g *gocui.Gui) error {
-		v.Clear()
-		for _, u := range usernames {
-			fmt.Fprintln(v, u)
-		}
-		return nil
-	})
+	v.Clear()
+	for _, u := range f.users {
+		fmt.Fprintln(v, u)
+	}
 	return nil
 }
 
 func (f *GUIFrontend) addMessageLine(line string) error {
+	return f.addMessage("", line)
+}
+
+// addMessage adds the line of the message with the ID (if any).
+func (f *GUIFrontend) addMessage(id string, line string) error {
 	g := f.gui
 	v, err := g.View("messages")
 	if err != nil {
 		return err
 	}
 	g.Update(func(g *gocui.Gui) error {
+		if id != "" && MessageBefore(f.unreadFrom, id) {
+			f.unreadFrom = ""
+			f.lines = append(f.lines, newMessagesSeparator)
+			f.lineIDs = append(f.lineIDs, "")
+			if _, err := fmt.Fprintln(v, newMessagesSeparator); err != nil {
+				return err
+			}
+		}
+		f.lines = append(f.lines, line)
+		f.lineIDs = append(f.lineIDs, id)
+		if id != "" {
+			f.messageLines[id] = len(f.lines) - 1
+			f.lastMessage = id
+		}
 		_, err := fmt.Fprintln(v, line)
 		return err
 	})
 	return nil
 }
 
+// addPreview adds the link preview under its message, ignoring
+// previews of unknown messages.
+func (f *GUIFrontend) addPreview(e *EventMessageEnrichment) error {
+	title := e.Title
+	if title == "" {
+		title = e.URL
+	}
+	preview := []string{"  \x1b[2m↳ " + title + "\x1b[0m"}
+	if e.Description != "" {
+		preview = append(preview, "    \x1b[2m"+e.Description+"\x1b[0m")
+	}
+
+	f.gui.Update(func(g *gocui.Gui) error {
+		at, ok := f.messageLines[e.MessageID]
+		if !ok {
+			return nil
+		}
+		at++
+		lines := make([]string, 0, len(f.lines)+len(preview))
+		lines = append(lines, f.lines[:at]...)
+		lines = append(lines, preview...)
+		f.lines = append(lines, f.lines[at:]...)
+		ids := make([]string, 0, len(f.lineIDs)+len(preview))
+		ids = append(ids, f.lineIDs[:at]...)
+		for range preview {
+			ids = append(ids, e.MessageID)
+		}
+		f.lineIDs = append(ids, f.lineIDs[at:]...)
+		for id, i := range f.messageLines {
+			if i >= at-1 {
+				// Including the message itself, so more previews
+				// go under this one.
+				f.messageLines[id] = i + len(preview)
+			}
+		}
+		return f.renderMessages(g)
+	})
+	return nil
+}
+
+// deleteMessages removes the lines of the deleted messages, including
+// their previews, with a notice when any were shown.
+func (f *GUIFrontend) deleteMessages(e *EventMessagesDeleted) error {
+	deleted := map[string]bool{}
+	for _, id := range e.MessageIDs {
+		deleted[id] = true
+	}
+	f.gui.Update(func(g *gocui.Gui) error {
+		shown := map[string]bool{}
+		lines := make([]string, 0, len(f.lines))
+		ids := make([]string, 0, len(f.lineIDs))
+		f.messageLines = map[string]int{}
+		for i, id := range f.lineIDs {
+			if deleted[id] {
+				shown[id] = true
+				continue
+			}
+			lines = append(lines, f.lines[i])
+			ids = append(ids, id)
+			if id != "" {
+				f.messageLines[id] = len(lines) - 1
+			}
+		}
+		f.lines, f.lineIDs = lines, ids
+		if err := f.renderMessages(g); err != nil {
+			return err
+		}
+		if len(shown) == 0 {
+			return nil
+		}
+		return f.addMessageLine(fmt.Sprintf(
+			"[%s] <<%d messages deleted: %s>>",
+			f.Config().FormatTime(e.Time),
+			len(shown),
+			e.Reason,
+		))
+	})
+	return nil
+}
+
+// renderMessages renders all lines in the messages view, keeping the
+// scroll position when scrolled back.
+func (f *GUIFrontend) renderMessages(g *gocui.Gui) error {
+	v, err := g.View("messages")
+	if err != nil {
+		return err
+	}
+	ox, oy := v.Origin()
+	v.Clear()
+	for _, line := range f.lines {
+		fmt.Fprintln(v, line)
+	}
+	if !v.Autoscroll {
+		return v.SetOrigin(ox, oy)
+	}
+	return nil
+}
+
+func (f *GUIFrontend) countUnread(mentioned bool) {
+	f.gui.Update(func(g *gocui.Gui) error {
+		f.unread++
+		if mentioned {
+			f.mentions++
+		}
+		return f.updateMessagesTitle(g)
+	})
+}
+
+// resetUnread resets the unread counts and marks the last message as
+// read.
+func (f *GUIFrontend) resetUnread(g *gocui.Gui) error {
+	f.unread = 0
+	f.mentions = 0
+	if f.reads && f.lastMessage != "" && f.lastMessage != f.lastMarked {
+		f.lastMarked = f.lastMessage
+		err := f.conn.SendEvent(&EventMarkRead{
+			EventMeta: *NewEventMetaNow(f.clock),
+			MessageID: f.lastMessage,
+		})
+		if err != nil {
+			f.logger.Warnw("could not mark read", log.Error(err))
+		}
+	}
+	return f.updateMessagesTitle(g)
+}
+
+func (f *GUIFrontend) updateMessagesTitle(g *gocui.Gui) error {
+	v, err := g.View("messages")
+	if err != nil {
+		return err
+	}
+	switch {
+	case f.unread == 0:
+		v.Title = "Messages"
+	case f.mentions == 0:
+		v.Title = fmt.Sprintf("Messages (%d unread)", f.unread)
+	default:
+		v.Title = fmt.Sprintf(
+			"Messages (%d unread, %d mentioned)",
+			f.unread,
+			f.mentions,
+		)
+	}
+	return nil
+}
+
 func (f *GUIFrontend) newManagerFunc(onReady func()) gocui.ManagerFunc {
 	once := sync.Once{}
 	return func(g *gocui.Gui) error {
 		maxX, maxY := g.Size()
-		x0 := 5
-		x1 := maxX - 5
-		y0 := 5
-		y1 := maxY - 5
+		layout := f.Config().Layout
+
+		// Grow the input view with multi-line input.
+		inputLines := 1
+		if v, err := g.View("input"); err == nil {
+			inputLines = len(v.BufferLines())
+		}
+		if inputLines < 1 {
+			inputLines = 1
+		}
+		if inputLines > maxInputLines {
+			inputLines = maxInputLines
+		}
+		messages, users, input := layout.viewRects(maxX, maxY, inputLines)
 
-		if v, err := g.SetView("messages", x0, y0, x1-36, y1-4, 0); err != nil {
+		if v, err := g.SetView(
+			"messages",
+			messages.x0, messages.y0, messages.x1, messages.y1,
+			0,
+		); err != nil {
 			if err != gocui.ErrUnknownView {
 				return nil
 			}
@@ -200,7 +599,16 @@ func (f *GUIFrontend) newManagerFunc(onReady func()) gocui.ManagerFunc {
 			v.Frame = true
 		}
 
-		if v, err := g.SetView("users", x1-35, y0, x1-1, y1-1, 0); err != nil {
+		if layout.HideUsers {
+			if err := g.DeleteView("users"); err != nil &&
+				err != gocui.ErrUnknownView {
+				return err
+			}
+		} else if v, err := g.SetView(
+			"users",
+			users.x0, users.y0, users.x1, users.y1,
+			0,
+		); err != nil {
 			if err != gocui.ErrUnknownView {
 				return nil
 			}
@@ -208,9 +616,16 @@ func (f *GUIFrontend) newManagerFunc(onReady func()) gocui.ManagerFunc {
 			v.Wrap = true
 			v.Autoscroll = true
 			v.Frame = true
+			if err := f.renderUsers(g); err != nil {
+				return err
+			}
 		}
 
-		if v, err := g.SetView("input", x0, y1-3, x1-36, y1-1, 0); err != nil {
+		if v, err := g.SetView(
+			"input",
+			input.x0, input.y0, input.x1, input.y1,
+			0,
+		); err != nil {
 			if err != gocui.ErrUnknownView {
 				return nil
 			}
@@ -219,6 +634,7 @@ func (f *GUIFrontend) newManagerFunc(onReady func()) gocui.ManagerFunc {
 			v.Frame = true
 			// v.Autoscroll = true
 			v.Editable = true
+			v.Editor = gocui.EditorFunc(f.editInput)
 			if err := f.activateView(g, v); err != nil {
 				return err
 			}
@@ -231,10 +647,11 @@ func (f *GUIFrontend) newManagerFunc(onReady func()) gocui.ManagerFunc {
 
 func (f *GUIFrontend) activateView(g *gocui.Gui, v *gocui.View) error {
 	_, _ = g.SetCurrentView(v.Name())
+	colors := f.Config().Colors
 	for _, v := range g.Views() {
 		if g.CurrentView() == v {
-			v.SelFgColor = gocui.ColorRed
-			v.SelBgColor = gocui.ColorBlue
+			v.SelFgColor = colors.SelFg.Attribute()
+			v.SelBgColor = colors.SelBg.Attribute()
 		} else {
 			v.SelFgColor = gocui.ColorDefault
 			v.SelBgColor = gocui.ColorDefault
@@ -245,6 +662,9 @@ func (f *GUIFrontend) activateView(g *gocui.Gui, v *gocui.View) error {
 	} else {
 		g.Cursor = false
 	}
+	if v.Name() == "messages" {
+		return f.resetUnread(g)
+	}
 	return nil
 }
 
@@ -268,31 +688,394 @@ func (f *GUIFrontend) sendMessageFromInput(g *gocui.Gui, v *gocui.View) error {
 	if err != nil {
 		return err
 	}
-	bytes, err := io.ReadAll(v)
+	message := strings.TrimRight(input.Buffer(), "\n")
+	input.Clear()
+	if message == "" {
+		return nil
+	}
+	f.history.add(message)
+	if strings.HasPrefix(message, "/") {
+		return f.runCommand(message)
+	}
+	_ = sendTraced(f.conn, &EventSendMessage{
+		EventMeta: *NewEventMetaNow(f.clock),
+		Message:   message,
+	}, "gui.sendMessage")
+	return f.resetUnread(g)
+}
+
+// runCommand runs the slash command in input.
+func (f *GUIFrontend) runCommand(input string) error {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "/export":
+		return f.startExport(input)
+	case "/nick":
+		return f.conn.SendEvent(&EventChangeNick{
+			EventMeta: *NewEventMetaNow(f.clock),
+			Name:      arg,
+		})
+	case "/status", "/color":
+		profile := f.getSelf()
+		if name == "/status" {
+			profile.Status = arg
+		} else {
+			profile.Color = arg
+		}
+		return f.conn.SendEvent(&EventSetProfile{
+			EventMeta: *NewEventMetaNow(f.clock),
+			Status:    profile.Status,
+			Color:     profile.Color,
+		})
+	case "/whois":
+		if arg == "" {
+			arg = f.getSelf().Name
+		}
+		return f.conn.SendEvent(&EventWhois{
+			EventMeta: *NewEventMetaNow(f.clock),
+			Name:      strings.TrimPrefix(arg, "@"),
+		})
+	default:
+		return f.addMessageLine(fmt.Sprintf(
+			"<<unknown command %s, commands: /nick /status /color /whois /export>>",
+			name,
+		))
+	}
+}
+
+func (f *GUIFrontend) getSelf() UserProfile {
+	f.selfMu.Lock()
+	defer f.selfMu.Unlock()
+	return f.self
+}
+
+// requestSelf requests the profile of the connected user, which is
+// already set when joining the sessions of a connected user.
+func (f *GUIFrontend) requestSelf() error {
+	f.selfMu.Lock()
+	f.selfPending = true
+	name := f.self.Name
+	f.selfMu.Unlock()
+	return f.conn.SendEvent(&EventWhois{
+		EventMeta: *NewEventMetaNow(f.clock),
+		Name:      name,
+	})
+}
+
+func (f *GUIFrontend) handleNickChange(e *EventNickChange) error {
+	f.selfMu.Lock()
+	if e.OldName == f.self.Name {
+		f.self.Name = e.NewName
+	}
+	f.selfMu.Unlock()
+	return f.addMessageLine(fmt.Sprintf(
+		"[%s] <<user \"%s\" is now known as \"%s\">>",
+		f.Config().FormatTime(e.Time),
+		e.OldName,
+		e.NewName,
+	))
+}
+
+func (f *GUIFrontend) handleProfile(e *EventProfile) error {
+	f.selfMu.Lock()
+	quiet := false
+	if e.Name == f.self.Name {
+		f.self = e.UserProfile
+		quiet = f.selfPending
+		f.selfPending = false
+	}
+	f.selfMu.Unlock()
+	if quiet {
+		return nil
+	}
+	name := e.Name
+	if color := Color(e.Color).ANSI(); color != "" {
+		name = color + name + "\x1b[0m"
+	}
+	status := e.Status
+	if status == "" {
+		status = "-"
+	}
+	return f.addMessageLine(fmt.Sprintf(
+		"<<%s (id %s) status: %s>>",
+		name,
+		e.UserID,
+		status,
+	))
+}
+
+// startExport requests the history for the /export command.
+func (f *GUIFrontend) startExport(input string) error {
+	cmd, err := ParseExportCommand(input, f.clock.Now())
 	if err != nil {
-		return err
+		return f.addMessageLine(fmt.Sprintf("<<%s>>", err))
 	}
-	_ = f.conn.SendEvent(&EventSendMessage{
-		EventMeta: *NewEventMetaNow(),
-		Message:   string(bytes),
+	f.exportsMu.Lock()
+	f.exportInc++
+	id := fmt.Sprintf("export-%d", f.exportInc)
+	f.exports[id] = cmd
+	f.exportsMu.Unlock()
+	return f.conn.SendEvent(&EventHistoryRequest{
+		EventMeta: *NewEventMetaNow(f.clock),
+		ID:        id,
+		From:      cmd.From,
+		To:        cmd.To,
 	})
-	input.Clear()
-	return err
+}
+
+// finishExport writes the history of an /export command to its file.
+func (f *GUIFrontend) finishExport(e *EventHistory) error {
+	f.exportsMu.Lock()
+	cmd, ok := f.exports[e.RequestID]
+	delete(f.exports, e.RequestID)
+	f.exportsMu.Unlock()
+	if !ok {
+		return nil
+	}
+	if e.Error != "" {
+		return f.addMessageLine(fmt.Sprintf("<<could not export: %s>>", e.Error))
+	}
+	msg := fmt.Sprintf("<<exported %d events to \"%s\">>", len(e.Events), cmd.Path)
+	if err := exportFile(cmd.Path, e.Events, cmd.Format); err != nil {
+		msg = fmt.Sprintf("<<could not export: %s>>", err)
+	}
+	return f.addMessageLine(msg)
+}
+
+// editInput is the editor of the input view. Adds history recall with
+// up/down arrows (on the first/last line) and new lines with shift+enter,
+// or alt+enter for terminals that don't report shift.
+func (f *GUIFrontend) editInput(
+	v *gocui.View,
+	key gocui.Key,
+	ch rune,
+	mod gocui.Modifier,
+) {
+	_, cy := v.Cursor()
+	switch {
+	case key == gocui.KeyEnter && mod != gocui.ModNone:
+		v.EditNewLine()
+	case key == gocui.KeyArrowUp && cy == 0:
+		if input, ok := f.history.prev(v.Buffer()); ok {
+			setInput(v, input)
+		}
+	case key == gocui.KeyArrowDown && cy >= len(v.BufferLines())-1:
+		if input, ok := f.history.next(); ok {
+			setInput(v, input)
+		}
+	default:
+		gocui.DefaultEditor.Edit(v, key, ch, mod)
+	}
+}
+
+// completeInput completes the username before the cursor.
+// Moves to the next view when there is nothing to complete.
+func (f *GUIFrontend) completeInput(g *gocui.Gui, v *gocui.View) error {
+	cx, cy := v.Cursor()
+	line, _ := v.Line(cy)
+	runes := []rune(line)
+	if cx > len(runes) {
+		cx = len(runes)
+	}
+	start := cx
+	for start > 0 && !unicode.IsSpace(runes[start-1]) {
+		start--
+	}
+	word := string(runes[start:cx])
+	if word == "" {
+		return f.nextView(g, v)
+	}
+	completed, ok := completeUsername(word, f.users)
+	if !ok {
+		return nil
+	}
+	for range runes[start:cx] {
+		v.EditDelete(true)
+	}
+	for _, r := range completed {
+		v.EditWrite(r)
+	}
+	return nil
+}
+
+// completeUsername completes word (optionally prefixed with "@") to the
+// matching username, adding a trailing space when the match is unique.
+// With multiple matches it completes to their longest common prefix.
+func completeUsername(word string, users []string) (string, bool) {
+	at := ""
+	if strings.HasPrefix(word, "@") {
+		at = "@"
+	}
+	prefix := strings.ToLower(strings.TrimPrefix(word, "@"))
+	if prefix == "" {
+		return "", false
+	}
+	matches := []string{}
+	for _, u := range users {
+		if strings.HasPrefix(strings.ToLower(u), prefix) {
+			matches = append(matches, u)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", false
+	case 1:
+		return at + matches[0] + " ", true
+	}
+	common := []rune(matches[0])
+	for _, m := range matches[1:] {
+		mr := []rune(m)
+		i := 0
+		for i < len(common) && i < len(mr) &&
+			unicode.ToLower(common[i]) == unicode.ToLower(mr[i]) {
+			i++
+		}
+		common = common[:i]
+	}
+	if len(common) < len([]rune(prefix)) {
+		return "", false
+	}
+	return at + string(common), true
+}
+
+// scrollMessagesPage returns a keybinding handler scrolling the messages
+// view by pages.
+func (f *GUIFrontend) scrollMessagesPage(
+	pages int,
+) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, _ *gocui.View) error {
+		v, err := g.View("messages")
+		if err != nil {
+			return err
+		}
+		_, height := v.Size()
+		return f.scrollMessages(v, pages*(height-1))
+	}
+}
+
+// scrollMessagesLines returns a keybinding handler scrolling the messages
+// view by lines.
+func (f *GUIFrontend) scrollMessagesLines(
+	lines int,
+) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, _ *gocui.View) error {
+		v, err := g.View("messages")
+		if err != nil {
+			return err
+		}
+		return f.scrollMessages(v, lines)
+	}
+}
+
+// scrollMessages scrolls the messages view. Autoscroll is paused while
+// scrolled back and resumes when scrolled to the bottom again.
+func (f *GUIFrontend) scrollMessages(v *gocui.View, delta int) error {
+	_, height := v.Size()
+	// The buffer ends with an empty line, which autoscroll does not show.
+	bottom := len(v.ViewBufferLines()) - height - 1
+	if bottom < 0 {
+		bottom = 0
+	}
+	_, oy := v.Origin()
+	if v.Autoscroll {
+		oy = bottom
+	}
+	oy += delta
+	if oy < 0 {
+		oy = 0
+	}
+	if oy >= bottom {
+		v.Autoscroll = true
+		v.Subtitle = ""
+		return v.SetOrigin(0, bottom)
+	}
+	v.Autoscroll = false
+	v.Subtitle = "scrolled back, PgDn to resume"
+	return v.SetOrigin(0, oy)
+}
+
+// setInput replaces the contents of the input view and moves the cursor
+// to the end.
+func setInput(v *gocui.View, input string) {
+	v.Clear()
+	fmt.Fprint(v, input)
+	lines := strings.Split(input, "\n")
+	last := lines[len(lines)-1]
+	_ = v.SetCursor(len([]rune(last)), len(lines)-1)
+}
+
+// inputHistory keeps previously sent inputs for recall.
+type inputHistory struct {
+	entries []string
+	// pos is the index of the recalled entry,
+	// len(entries) when editing the draft.
+	pos int
+	// draft is the unsent input from before recalling entries.
+	draft string
+}
+
+// add adds input to the history and resets recall to the (empty) draft.
+func (h *inputHistory) add(input string) {
+	if n := len(h.entries); n == 0 || h.entries[n-1] != input {
+		h.entries = append(h.entries, input)
+	}
+	h.pos = len(h.entries)
+	h.draft = ""
+}
+
+// prev returns the previous entry, remembering current as draft when
+// starting to recall. Returns false when there is no previous entry.
+func (h *inputHistory) prev(current string) (string, bool) {
+	if h.pos == 0 {
+		return "", false
+	}
+	if h.pos == len(h.entries) {
+		h.draft = current
+	}
+	h.pos--
+	return h.entries[h.pos], true
+}
+
+// next returns the next entry, or the draft after the last entry.
+// Returns false when already at the draft.
+func (h *inputHistory) next() (string, bool) {
+	if h.pos >= len(h.entries) {
+		return "", false
+	}
+	h.pos++
+	if h.pos == len(h.entries) {
+		return h.draft, true
+	}
+	return h.entries[h.pos], true
 }
 
 func (f *GUIFrontend) quit(g *gocui.Gui, v *gocui.View) error {
 	return gocui.ErrQuit
 }
 
-func NewGUIFrontend(conn Connection, logger log.Logger) (*GUIFrontend, error) {
+func NewGUIFrontend(
+	conn Connection,
+	logger log.Logger,
+	opts GUIFrontendOpts,
+) (*GUIFrontend, error) {
 	g, err := gocui.NewGui(gocui.OutputTrue, true)
 	if err != nil {
 		return nil, err
 	}
 	fe := &GUIFrontend{
-		logger: logger,
-		conn:   conn,
-		gui:    g,
+		logger:       logger,
+		conn:         conn,
+		gui:          g,
+		opts:         opts,
+		clock:        clock.OrReal(opts.Clock),
+		config:       opts.Config,
+		exports:      map[string]*ExportCommand{},
+		self:         UserProfile{Name: opts.Username},
+		messageLines: map[string]int{},
+	}
+	if fe.config == nil {
+		fe.config = DefaultGUIConfig()
 	}
 	return fe, nil
 }
@@ -0,0 +1,251 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	"gopkg.in/yaml.v3"
+)
+
+// GUIConfig is the theming and layout configuration of the GUI frontend.
+type GUIConfig struct {
+	// TimeFormat is the Go time layout used for timestamps.
+	// Empty uses the default time.Time string.
+	TimeFormat string    `yaml:"timeFormat"`
+	Layout     GUILayout `yaml:"layout"`
+	Colors     GUIColors `yaml:"colors"`
+}
+
+// GUILayout configures the sizes and positions of the views.
+type GUILayout struct {
+	// Margin is the number of cells around all views.
+	Margin int `yaml:"margin"`
+	// UsersWidth is the width of the users view, including the border.
+	UsersWidth int `yaml:"usersWidth"`
+	// UsersPosition is "left" or "right".
+	UsersPosition string `yaml:"usersPosition"`
+	// InputPosition is "top" or "bottom".
+	InputPosition string `yaml:"inputPosition"`
+	// HideUsers hides the users view.
+	HideUsers bool `yaml:"hideUsers"`
+}
+
+// GUIColors configures the colors of the GUI.
+type GUIColors struct {
+	// SelFg and SelBg are the colors of the selected view.
+	SelFg Color `yaml:"selFg"`
+	SelBg Color `yaml:"selBg"`
+	// Mention is the color of messages mentioning the user.
+	Mention Color `yaml:"mention"`
+	// Sender is the color of senders not listed in Senders.
+	Sender Color `yaml:"sender"`
+	// Senders are colors per sender (username).
+	Senders map[string]Color `yaml:"senders"`
+}
+
+// Color is a color name (default, black, red, green, yellow, blue,
+// magenta, cyan, white) or a hex RGB value like "#ff8800".
+type Color string
+
+var colorNames = []string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+}
+
+// parse returns the color index in colorNames, or the RGB value for
+// hex colors. Index is -1 for the default color.
+func (c Color) parse() (index int, rgb [3]int32, err error) {
+	s := strings.ToLower(string(c))
+	if s == "" || s == "default" {
+		return -1, rgb, nil
+	}
+	for i, name := range colorNames {
+		if s == name {
+			return i, rgb, nil
+		}
+	}
+	if strings.HasPrefix(s, "#") && len(s) == 7 {
+		for i := range rgb {
+			v, err := strconv.ParseUint(s[1+i*2:3+i*2], 16, 8)
+			if err != nil {
+				return 0, rgb, fmt.Errorf(`invalid color "%s": %w`, c, err)
+			}
+			rgb[i] = int32(v)
+		}
+		return len(colorNames), rgb, nil
+	}
+	return 0, rgb, fmt.Errorf(`invalid color "%s"`, c)
+}
+
+// Validate returns an error for invalid colors.
+func (c Color) Validate() error {
+	_, _, err := c.parse()
+	return err
+}
+
+// Attribute returns the color as gocui attribute.
+func (c Color) Attribute() gocui.Attribute {
+	i, rgb, err := c.parse()
+	switch {
+	case err != nil || i < 0:
+		return gocui.ColorDefault
+	case i == len(colorNames):
+		return gocui.NewRGBColor(rgb[0], rgb[1], rgb[2])
+	default:
+		return gocui.ColorBlack + gocui.Attribute(i)
+	}
+}
+
+// ANSI returns the ANSI escape sequence for the foreground color,
+// or empty string for the default color.
+func (c Color) ANSI() string {
+	i, rgb, err := c.parse()
+	switch {
+	case err != nil || i < 0:
+		return ""
+	case i == len(colorNames):
+		return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", rgb[0], rgb[1], rgb[2])
+	default:
+		return fmt.Sprintf("\x1b[%dm", 30+i)
+	}
+}
+
+// FormatTime formats t (in local time) with the configured time format.
+func (c *GUIConfig) FormatTime(t time.Time) string {
+	if c.TimeFormat == "" {
+		return t.Local().String()
+	}
+	return t.Local().Format(c.TimeFormat)
+}
+
+// SenderColor returns the color for the sender.
+func (c *GUIConfig) SenderColor(sender string) Color {
+	if color, ok := c.Colors.Senders[sender]; ok {
+		return color
+	}
+	return c.Colors.Sender
+}
+
+// Validate returns an error when the config has invalid values.
+func (c *GUIConfig) Validate() error {
+	l := c.Layout
+	if l.Margin < 0 {
+		return fmt.Errorf("invalid layout margin %d", l.Margin)
+	}
+	if l.UsersWidth < 3 {
+		return fmt.Errorf("invalid layout usersWidth %d", l.UsersWidth)
+	}
+	if l.UsersPosition != "left" && l.UsersPosition != "right" {
+		return fmt.Errorf(`invalid layout usersPosition "%s"`, l.UsersPosition)
+	}
+	if l.InputPosition != "top" && l.InputPosition != "bottom" {
+		return fmt.Errorf(`invalid layout inputPosition "%s"`, l.InputPosition)
+	}
+	colors := []Color{
+		c.Colors.SelFg,
+		c.Colors.SelBg,
+		c.Colors.Mention,
+		c.Colors.Sender,
+	}
+	for _, color := range c.Colors.Senders {
+		colors = append(colors, color)
+	}
+	for _, color := range colors {
+		if _, _, err := color.parse(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultGUIConfig returns the config used when there is no config file.
+func DefaultGUIConfig() *GUIConfig {
+	return &GUIConfig{
+		Layout: GUILayout{
+			Margin:        5,
+			UsersWidth:    36,
+			UsersPosition: "right",
+			InputPosition: "bottom",
+		},
+		Colors: GUIColors{
+			SelFg:   "red",
+			SelBg:   "blue",
+			Mention: "yellow",
+		},
+	}
+}
+
+// DefaultGUIConfigPath returns the path of the config file in the
+// user config dir ($XDG_CONFIG_HOME/gochat/gui.yaml on Linux).
+func DefaultGUIConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gochat", "gui.yaml"), nil
+}
+
+// LoadGUIConfig reads the YAML config file at path on top of the defaults.
+// Returns the defaults when the file does not exist.
+func LoadGUIConfig(path string) (*GUIConfig, error) {
+	config := DefaultGUIConfig()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// viewRect is the position of a view.
+type viewRect struct {
+	x0, y0, x1, y1 int
+}
+
+// viewRects returns the positions of the messages, users and input views
+// for a screen of maxX by maxY cells and an input of inputLines lines.
+func (l GUILayout) viewRects(
+	maxX, maxY, inputLines int,
+) (messages, users, input viewRect) {
+	x0 := l.Margin
+	x1 := maxX - l.Margin
+	y0 := l.Margin
+	y1 := maxY - l.Margin
+
+	// Main column with the messages and input views.
+	mainX0, mainX1 := x0, x1-1
+	if !l.HideUsers {
+		if l.UsersPosition == "left" {
+			users = viewRect{x0, y0, x0 + l.UsersWidth - 2, y1 - 1}
+			mainX0 = x0 + l.UsersWidth - 1
+		} else {
+			users = viewRect{x1 - l.UsersWidth + 1, y0, x1 - 1, y1 - 1}
+			mainX1 = x1 - l.UsersWidth
+		}
+	}
+
+	if l.InputPosition == "top" {
+		inputY1 := y0 + inputLines + 1
+		input = viewRect{mainX0, y0, mainX1, inputY1}
+		messages = viewRect{mainX0, inputY1 + 1, mainX1, y1 - 1}
+	} else {
+		inputY0 := y1 - 2 - inputLines
+		messages = viewRect{mainX0, y0, mainX1, inputY0 - 1}
+		input = viewRect{mainX0, inputY0, mainX1, y1 - 1}
+	}
+	return messages, users, input
+}
@@ -0,0 +1,101 @@
+package chat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadGUIConfigMissingFile(t *testing.T) {
+	config, err := LoadGUIConfig(filepath.Join(t.TempDir(), "gui.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultGUIConfig(), config)
+}
+
+func TestLoadGUIConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gui.yaml")
+	data := `
+timeFormat: "15:04"
+layout:
+  usersPosition: left
+  hideUsers: true
+colors:
+  sender: cyan
+  senders:
+    Mario: "#ff0000"
+`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	config, err := LoadGUIConfig(path)
+	require.NoError(t, err)
+
+	expected := DefaultGUIConfig()
+	expected.TimeFormat = "15:04"
+	expected.Layout.UsersPosition = "left"
+	expected.Layout.HideUsers = true
+	expected.Colors.Sender = "cyan"
+	expected.Colors.Senders = map[string]Color{"Mario": "#ff0000"}
+	assert.Equal(t, expected, config)
+
+	assert.Equal(t, Color("#ff0000"), config.SenderColor("Mario"))
+	assert.Equal(t, Color("cyan"), config.SenderColor("Luigi"))
+}
+
+func TestLoadGUIConfigInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gui.yaml")
+	tests := []string{
+		"colors: {sender: pink}",
+		"layout: {usersPosition: top}",
+		"layout: {inputPosition: left}",
+		"layout: {usersWidth: 1}",
+		"layout: [",
+	}
+	for _, data := range tests {
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+		_, err := LoadGUIConfig(path)
+		assert.Error(t, err, data)
+	}
+}
+
+func TestGUIConfigFormatTime(t *testing.T) {
+	tm := time.Date(2022, 6, 1, 13, 14, 15, 0, time.Local)
+	config := DefaultGUIConfig()
+	assert.Equal(t, tm.String(), config.FormatTime(tm))
+	config.TimeFormat = "15:04:05"
+	assert.Equal(t, "13:14:15", config.FormatTime(tm))
+}
+
+func TestColor(t *testing.T) {
+	assert.Equal(t, gocui.ColorDefault, Color("").Attribute())
+	assert.Equal(t, gocui.ColorRed, Color("red").Attribute())
+	assert.Equal(t, gocui.NewRGBColor(255, 136, 0), Color("#ff8800").Attribute())
+	assert.Equal(t, "", Color("default").ANSI())
+	assert.Equal(t, "\x1b[34m", Color("Blue").ANSI())
+	assert.Equal(t, "\x1b[38;2;255;136;0m", Color("#ff8800").ANSI())
+}
+
+func TestGUILayoutViewRects(t *testing.T) {
+	layout := DefaultGUIConfig().Layout
+	messages, users, input := layout.viewRects(100, 50, 1)
+	assert.Equal(t, viewRect{5, 5, 59, 41}, messages)
+	assert.Equal(t, viewRect{60, 5, 94, 44}, users)
+	assert.Equal(t, viewRect{5, 42, 59, 44}, input)
+
+	layout.UsersPosition = "left"
+	layout.InputPosition = "top"
+	messages, users, input = layout.viewRects(100, 50, 2)
+	assert.Equal(t, viewRect{40, 9, 94, 44}, messages)
+	assert.Equal(t, viewRect{5, 5, 39, 44}, users)
+	assert.Equal(t, viewRect{40, 5, 94, 8}, input)
+
+	layout.HideUsers = true
+	layout.InputPosition = "bottom"
+	messages, users, _ = layout.viewRects(100, 50, 1)
+	assert.Equal(t, viewRect{5, 5, 94, 41}, messages)
+	assert.Equal(t, viewRect{}, users)
+}
@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompleteUsername(t *testing.T) {
+	users := []string{"Mario", "Luigi", "Lakitu", "Larry"}
+	tests := []struct {
+		word      string
+		expected  string
+		completed bool
+	}{
+		{"ma", "Mario ", true},
+		{"@ma", "@Mario ", true},
+		{"@lu", "@Luigi ", true},
+		{"la", "La", true},
+		{"lar", "Larry ", true},
+		{"x", "", false},
+		{"@", "", false},
+	}
+	for _, tt := range tests {
+		completed, ok := completeUsername(tt.word, users)
+		assert.Equal(t, tt.completed, ok, tt.word)
+		assert.Equal(t, tt.expected, completed, tt.word)
+	}
+}
+
+func TestInputHistory(t *testing.T) {
+	h := inputHistory{}
+
+	_, ok := h.prev("draft")
+	assert.False(t, ok)
+
+	h.add("one")
+	h.add("two")
+	h.add("two") // no duplicates in a row
+
+	input, ok := h.prev("draft")
+	assert.True(t, ok)
+	assert.Equal(t, "two", input)
+
+	input, ok = h.prev(input)
+	assert.True(t, ok)
+	assert.Equal(t, "one", input)
+
+	_, ok = h.prev(input)
+	assert.False(t, ok)
+
+	input, ok = h.next()
+	assert.True(t, ok)
+	assert.Equal(t, "two", input)
+
+	input, ok = h.next()
+	assert.True(t, ok)
+	assert.Equal(t, "draft", input)
+
+	_, ok = h.next()
+	assert.False(t, ok)
+}
@@ -0,0 +1,75 @@
+package chat
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultHistoryLimit is the number of events the hub keeps in history.
+const DefaultHistoryLimit = 10000
+
+// History is the room history: users entering, leaving, changing
+// names and messages.
+// It keeps the last limit events in memory.
+type History struct {
+	mu     sync.RWMutex
+	events []Event
+	limit  int
+}
+
+// Add adds the event, dropping the oldest event when full.
+func (h *History) Add(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, e)
+	if len(h.events) > h.limit {
+		h.events = h.events[len(h.events)-h.limit:]
+	}
+}
+
+// Remove removes the events for which remove returns true, returning
+// the number of removed events.
+func (h *History) Remove(remove func(e Event) bool) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events := make([]Event, 0, len(h.events))
+	for _, e := range h.events {
+		if !remove(e) {
+			events = append(events, e)
+		}
+	}
+	n := len(h.events) - len(events)
+	h.events = events
+	return n
+}
+
+// Len returns the number of events.
+func (h *History) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.events)
+}
+
+// Range returns the events from (inclusive) to (exclusive) in order.
+// Zero times are unbounded.
+func (h *History) Range(from, to time.Time) []Event {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	events := []Event{}
+	for _, e := range h.events {
+		t := e.When()
+		if !from.IsZero() && t.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !t.Before(to) {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// NewHistory returns a history keeping the last limit events.
+func NewHistory(limit int) *History {
+	return &History{events: []Event{}, limit: limit}
+}
@@ -0,0 +1,22 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	h := NewHistory(2)
+	events := testTranscript()
+	for _, e := range events {
+		h.Add(e)
+	}
+
+	// Only the last two are kept.
+	assert.Equal(t, events[1:], h.Range(time.Time{}, time.Time{}))
+	// From is inclusive, to exclusive.
+	assert.Equal(t, events[1:2], h.Range(time.Unix(2, 0), time.Unix(3, 0)))
+	assert.Empty(t, h.Range(time.Unix(4, 0), time.Time{}))
+}
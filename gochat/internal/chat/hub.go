@@ -6,58 +6,210 @@ import (
 	"reflect"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/marcelbeumer/go-playground/gochat/internal/kvstore"
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
 	"github.com/marcelbeumer/go-playground/gochat/internal/queue"
+	"github.com/marcelbeumer/go-playground/gochat/internal/tracing"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Use simple int increment for ids.
 type hubId = int
 
-// hubUser encapsulates a user in the hub.
+// hubUser is a user in the hub, connected with one or more sessions.
 type hubUser struct {
-	name   string
+	// identity of authenticated users, empty for anonymous users.
+	identity string
+	// sessions is the number of connected sessions, guarded by
+	// Hub.usersMu.
+	sessions int
+	// profile is changed with EventChangeNick and EventSetProfile.
+	profile   UserProfile
+	profileMu sync.RWMutex
+}
+
+func (u *hubUser) getProfile() UserProfile {
+	u.profileMu.RLock()
+	defer u.profileMu.RUnlock()
+	return u.profile
+}
+
+func (u *hubUser) setProfile(p UserProfile) {
+	u.profileMu.Lock()
+	defer u.profileMu.Unlock()
+	u.profile = p
+}
+
+// name returns the display name.
+func (u *hubUser) name() string {
+	return u.getProfile().Name
+}
+
+// hubSession encapsulates a connection of a user in the hub.
+type hubSession struct {
+	user   *hubUser
 	conn   Connection
 	events *queue.Queue[Event]
+	// protocol is the protocol negotiated with EventHello.
+	protocol   *Protocol
+	protocolMu sync.RWMutex
+	// lastEvent is the hub time of the last inbound event in unix
+	// nanos, accessed atomically.
+	lastEvent int64
+}
+
+func (s *hubSession) getProtocol() *Protocol {
+	s.protocolMu.RLock()
+	defer s.protocolMu.RUnlock()
+	return s.protocol
+}
+
+func (s *hubSession) setProtocol(p *Protocol) {
+	s.protocolMu.Lock()
+	defer s.protocolMu.Unlock()
+	s.protocol = p
+}
+
+// HubOpts are the options of NewHub.
+type HubOpts struct {
+	// Clock for event times and timeouts, clock.Real when nil.
+	Clock clock.Clock
+	// Offline keeps the mentions of authenticated users while they are
+	// offline, disabled when nil.
+	Offline OfflineStore
+	// OfflineRetention is how long Offline keeps events,
+	// DefaultOfflineRetention when zero.
+	OfflineRetention time.Duration
+	// IdleTimeout disconnects sessions without inbound events for
+	// this long, disabled when zero.
+	IdleTimeout time.Duration
+	// Retention purges history by the policies, disabled when nil.
+	Retention *RetentionConfig
+	// Store persists rooms, memberships, roles, bans and history, a
+	// MemoryStore when nil. The caller closes it after the hub.
+	Store Store
+	// AdminToken is the admin token of the server. Only sessions
+	// connected with it as token get the history, none when empty.
+	AdminToken string
 }
 
 // Hub is the chat hub/room where users can connect to.
 type Hub struct {
-	logger  log.Logger
-	users   *kvstore.KVStore[hubId, *hubUser]
-	usersMu sync.RWMutex
-	idInc   hubId
-	closed  chan struct{}
+	logger   log.Logger
+	clock    clock.Clock
+	sessions *kvstore.KVStore[hubId, *hubSession]
+	usersMu  sync.RWMutex
+	idInc    hubId
+	msgInc   int64
+	// eventsIn and eventsOut count the events read from and delivered
+	// to sessions, for Stats.
+	eventsIn  int64
+	eventsOut int64
+	startedAt time.Time
+	closed    chan struct{}
+	history   *History
+	store     Store
+	// storeWrites are written to store by writeStore, which closes
+	// storeDone when done.
+	storeWrites chan storeWrite
+	storeDone   chan struct{}
+	// bansMu orders the ban check of new sessions and adding bans, so
+	// no session connects between banning and kicking a user.
+	bansMu sync.RWMutex
+	// adminIdentity is the identity of the admin token, empty without.
+	adminIdentity string
+	// offline is the store for offline users, nil when disabled.
+	offline          OfflineStore
+	offlineRetention time.Duration
+	idleTimeout      time.Duration
+	// retention are the retention policies, nil when disabled.
+	retention *RetentionConfig
+	// reads are the IDs of the last read messages by user ID.
+	reads   map[string]string
+	readsMu sync.Mutex
+	// inbound and outbound are the handlers with the middleware of
+	// the plugins, see Use.
+	plugins  []*Plugin
+	inbound  Handler
+	outbound Handler
+}
+
+// Use adds the plugin middleware, the first plugin being the outermost.
+// Must be called before connecting sessions.
+func (h *Hub) Use(plugins ...*Plugin) {
+	h.plugins = append(h.plugins, plugins...)
+	inbound := []Middleware{}
+	outbound := []Middleware{}
+	for _, p := range h.plugins {
+		if p.Inbound != nil {
+			inbound = append(inbound, p.Inbound)
+		}
+		if p.Outbound != nil {
+			outbound = append(outbound, p.Outbound)
+		}
+	}
+	h.inbound = Chain(h.handleEvent, inbound...)
+	h.outbound = Chain(h.deliverEvent, outbound...)
 }
 
+// Clock returns the clock of the hub.
+func (h *Hub) Clock() clock.Clock {
+	return h.clock
+}
+
+// History returns the room history from (inclusive) to (exclusive).
+// Zero times are unbounded.
+func (h *Hub) History(from, to time.Time) []Event {
+	return h.history.Range(from, to)
+}
+
+// Connect connects a session for the username,
+// returning the session id for Disconnect. Fails for usernames that do
+// not pass ValidateUsername.
 func (h *Hub) Connect(username string, conn Connection) (hubId, error) {
+	return h.ConnectIdentity("", username, conn)
+}
+
+// ConnectIdentity is like Connect for an authenticated user, see
+// IdentityFromAuthorization. When the username is connected with the same
+// identity, the connection is added as another session of that user.
+func (h *Hub) ConnectIdentity(
+	identity string,
+	username string,
+	conn Connection,
+) (hubId, error) {
 	select {
 	case <-h.closed:
 		return 0, ErrHubClosed
 	default:
 	}
 
-	userId, err := h.newUser(username, conn)
+	sessionId, err := h.newSession(identity, username, conn)
 	if err != nil {
-		return userId, err
+		return sessionId, err
 	}
 	go func() {
-		if err := h.pumpFromUser(userId); err != nil {
-			_ = h.disconnectUser(userId, err, true)
+		if err := h.pumpFromSession(sessionId); err != nil {
+			_ = h.disconnectSession(sessionId, err, true)
 		}
 	}()
 	go func() {
-		if err := h.pumpToUser(userId); err != nil {
-			_ = h.disconnectUser(userId, err, true)
+		if err := h.pumpToSession(sessionId); err != nil {
+			_ = h.disconnectSession(sessionId, err, true)
 		}
 	}()
-	return userId, nil
+	return sessionId, nil
 }
 
-func (h *Hub) Disconnect(userId hubId) error {
-	return h.disconnectUser(userId, nil, true)
+// Disconnect disconnects the session. The user leaves when it was the
+// last session of the user.
+func (h *Hub) Disconnect(sessionId hubId) error {
+	return h.disconnectSession(sessionId, nil, true)
 }
 
 func (h *Hub) Close() error {
@@ -72,15 +224,16 @@ func (h *Hub) Close() error {
 	}
 
 	var wg sync.WaitGroup
-	for _, userId := range h.userIds() {
-		userId := userId
+	for _, sessionId := range h.sessionIds() {
+		sessionId := sessionId
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			_ = h.disconnectUser(userId, ErrHubClosed, false)
+			_ = h.disconnectSession(sessionId, ErrHubClosed, false)
 		}()
 	}
 	wg.Wait()
+	<-h.storeDone // pending writes are stored
 
 	return nil
 }
@@ -90,79 +243,131 @@ func (h *Hub) genId() hubId {
 	return h.idInc
 }
 
-func (h *Hub) newUser(username string, conn Connection) (hubId, error) {
+func (h *Hub) newSession(
+	identity string,
+	username string,
+	conn Connection,
+) (hubId, error) {
+	if err := ValidateUsername(username); err != nil {
+		return 0, err
+	}
+	h.bansMu.RLock()
+	defer h.bansMu.RUnlock()
+	if identity != "" {
+		if ban, ok := h.activeBan(identityUserID(identity)); ok {
+			return 0, &ErrBanned{username: username, reason: ban.Reason}
+		}
+	}
 	h.usersMu.Lock()
-	for _, user := range h.users.Values() {
-		if user.name == username {
+	defer h.usersMu.Unlock()
+
+	sessionId := h.genId()
+	// Sessions of an identity belong to one user, by one name.
+	user, ok := h.findUserByIdentity(identity)
+	if ok && !SameUsername(user.name(), username) {
+		return 0, &ErrIdentityConnected{username: user.name()}
+	}
+	if !ok {
+		if _, exists := h.findUserByName(username); exists {
 			return 0, &ErrUsernameExists{username: username}
 		}
+		user = &hubUser{
+			identity: identity,
+			profile: UserProfile{
+				UserID: fmt.Sprintf("guest-%d", sessionId),
+				Name:   username,
+			},
+		}
+		if identity != "" {
+			user.profile.UserID = identityUserID(identity)
+		}
 	}
+	if identity != "" && h.offline != nil {
+		if err := h.offline.SetName(identity, user.name()); err != nil {
+			h.logger.Errorw("could not store offline name", log.Error(err))
+		}
+	}
+
 	events := queue.NewQueue[Event]()
 	_ = events.Add(&EventConnected{ // first event
-		EventMeta: *NewEventMetaNow(),
-		Users:     h.userList(username),
+		EventMeta: *NewEventMetaNow(h.clock),
+		Users:     h.userList(user.name()),
+		LastRead:  h.lastRead(user.profile.UserID),
 	})
 
-	userId := h.genId()
-	h.users.Set(userId, &hubUser{
-		name:   username,
-		conn:   conn,
-		events: events,
+	user.sessions++
+	h.sessions.Set(sessionId, &hubSession{
+		user:      user,
+		conn:      conn,
+		events:    events,
+		protocol:  NewProtocolV1(),
+		lastEvent: h.clock.Now().UnixNano(),
 	})
-	defer h.usersMu.Unlock()
+	if user.sessions > 1 {
+		return sessionId, nil // already online
+	}
+	if identity != "" {
+		h.storeMember(user.profile)
+	}
 
-	others := h.userIds(userId)
-	_ = h.sendEvent(&EventUserEnter{
-		EventMeta: *NewEventMetaNow(),
+	others := h.sessionIds(sessionId)
+	enter := &EventUserEnter{
+		EventMeta: *NewEventMetaNow(h.clock),
 		Name:      username,
-	}, others...)
+	}
+	h.addHistory(enter)
+	_ = h.sendEvent(enter, others...)
 	_ = h.sendEvent(&EventUserListUpdate{
-		EventMeta: *NewEventMetaNow(),
+		EventMeta: *NewEventMetaNow(h.clock),
 		Users:     h.userList(),
 	}, others...)
 
-	return userId, nil
+	return sessionId, nil
 }
 
-func (h *Hub) disconnectUser(userId hubId, reasonErr error, notify bool) error {
+func (h *Hub) disconnectSession(sessionId hubId, reasonErr error, notify bool) error {
 	h.usersMu.Lock()
-	defer h.usersMu.Unlock()
-
-	user, err := h.findUser(userId)
+	session, err := h.findSession(sessionId)
 	if err != nil {
+		h.usersMu.Unlock()
 		return err
 	}
-	h.users.Delete(userId)
-	user.events.Close()
+	h.sessions.Delete(sessionId)
+	session.events.Close()
+	session.user.sessions--
 
-	if notify {
+	if notify && session.user.sessions == 0 {
 		// Notify other users.
-		others := h.userIds(userId)
-		_ = h.sendEvent(&EventUserLeave{
-			EventMeta: *NewEventMetaNow(),
-			Name:      user.name,
-		}, others...)
+		others := h.sessionIds(sessionId)
+		leave := &EventUserLeave{
+			EventMeta: *NewEventMetaNow(h.clock),
+			Name:      session.user.name(),
+		}
+		h.addHistory(leave)
+		_ = h.sendEvent(leave, others...)
 		_ = h.sendEvent(&EventUserListUpdate{
-			EventMeta: *NewEventMetaNow(),
+			EventMeta: *NewEventMetaNow(h.clock),
 			Users:     h.userList(),
 		}, others...)
 	}
+	h.usersMu.Unlock()
 
-	// Give the user some time to consume events.
+	// Give the session some time to consume events. Not holding the lock,
+	// other users should not wait for slow or gone users.
 	select {
-	case <-time.After(time.Second * 2):
-	case <-user.events.Empty():
+	case <-h.clock.After(time.Second * 2):
+	case <-session.events.Empty():
 	}
-	// Truly disconnect the user.
-	return user.conn.Close(nil)
+	// Truly disconnect the session.
+	return session.conn.Close(nil)
 }
 
-func (h *Hub) userIds(exclude ...hubId) []hubId {
+func (h *Hub) sessionIds(exclude ...hubId) []hubId {
 	ex := map[int]bool{}
 	for _, v := range exclude {
 		ex[v] = true
 	}
-	keys := h.users.Keys()
+	keys := h.sessions.Keys()
 	ids := []hubId{}
 	for _, v := range keys {
 		if _, ok := ex[v]; !ok {
@@ -172,11 +377,23 @@ func (h *Hub) userIds(exclude ...hubId) []hubId {
 	return ids
 }
 
+// userSessionIds returns the ids of the sessions of the user.
+func (h *Hub) userSessionIds(user *hubUser) []hubId {
+	ids := []hubId{}
+	for _, sessionId := range h.sessions.Keys() {
+		session, _ := h.sessions.Get(sessionId)
+		if session != nil && session.user == user {
+			ids = append(ids, sessionId)
+		}
+	}
+	return ids
+}
+
 func (h *Hub) userList(pendingUsernames ...string) []string {
 	coll := map[string]struct{}{}
 
-	for _, user := range h.users.Values() {
-		coll[user.name] = struct{}{}
+	for _, session := range h.sessions.Values() {
+		coll[session.user.name()] = struct{}{}
 	}
 	for _, name := range pendingUsernames {
 		coll[name] = struct{}{}
@@ -191,101 +408,458 @@ func (h *Hub) userList(pendingUsernames ...string) []string {
 	return names
 }
 
-func (h *Hub) findUser(userId hubId) (*hubUser, error) {
-	user, _ := h.users.Get(userId)
-	if user == nil {
-		return nil, &ErrUserIdNotFound{id: userId}
+func (h *Hub) findSession(sessionId hubId) (*hubSession, error) {
+	session, _ := h.sessions.Get(sessionId)
+	if session == nil {
+		return nil, &ErrSessionIdNotFound{id: sessionId}
 	}
-	return user, nil
+	return session, nil
 }
 
-func (h *Hub) pumpToUser(userId hubId) error {
-	user, err := h.findUser(userId)
+// findUserByName returns the user with the display name, see
+// SameUsername.
+func (h *Hub) findUserByName(name string) (*hubUser, bool) {
+	for _, session := range h.sessions.Values() {
+		if SameUsername(session.user.name(), name) {
+			return session.user, true
+		}
+	}
+	return nil, false
+}
+
+// findUserByIdentity returns the user of the identity, false for
+// anonymous users.
+func (h *Hub) findUserByIdentity(identity string) (*hubUser, bool) {
+	if identity == "" {
+		return nil, false
+	}
+	for _, session := range h.sessions.Values() {
+		if session.user.identity == identity {
+			return session.user, true
+		}
+	}
+	return nil, false
+}
+
+func (h *Hub) pumpToSession(sessionId hubId) error {
+	session, err := h.findSession(sessionId)
 	if err != nil {
 		return err
 	}
 	for {
-		e, err := user.events.Read()
+		e, err := session.events.Read()
 		if err != nil {
 			return err
 		}
-		err = user.conn.SendEvent(e)
+		if EventTrace(e) != nil {
+			ctx, span := StartEventSpan(e, "hub.pumpToSession",
+				trace.WithAttributes(attribute.Int("sessionId", sessionId)))
+			err = session.conn.SendEvent(WithTrace(e, ctx))
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.End()
+		} else {
+			err = session.conn.SendEvent(e)
+		}
 		if err != nil {
 			return err
 		}
 	}
 }
 
-func (h *Hub) pumpFromUser(userId hubId) error {
-	user, err := h.findUser(userId)
+func (h *Hub) pumpFromSession(sessionId hubId) error {
+	session, err := h.findSession(sessionId)
 	if err != nil {
 		return err
 	}
 	for {
-		e, err := user.conn.ReadEvent()
+		e, err := session.conn.ReadEvent()
 		if err != nil {
 			return err
 		}
-		if err := h.handleEvent(userId, e); err != nil {
+		atomic.AddInt64(&h.eventsIn, 1)
+		session.touch(h.clock.Now())
+		ctx, span := StartEventSpan(e, "hub.handleEvent", trace.WithAttributes(
+			attribute.String("event", reflect.TypeOf(e).String()),
+			attribute.Int("sessionId", sessionId),
+		))
+		err = h.inbound(&EventContext{
+			Context:   ctx,
+			Hub:       h,
+			Direction: Inbound,
+			SessionID: sessionId,
+			User:      session.user.getProfile(),
+		}, e)
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+		var notFound *ErrSessionIdNotFound
+		if errors.As(err, &notFound) {
 			return err
 		}
+		if err != nil {
+			_ = h.sendNotice(err.Error(), sessionId)
+		}
 	}
 }
 
-func (h *Hub) handleEvent(userId hubId, e Event) error {
-	logger := h.logger
-	user, err := h.findUser(userId)
+// handleEvent is the inbound handler.
+func (h *Hub) handleEvent(ctx *EventContext, e Event) error {
+	logger := h.logger.WithContext(ctx.Context)
+	sessionId := ctx.SessionID
+	session, err := h.findSession(sessionId)
 	if err != nil {
 		return err
 	}
+	user := session.user
 
 	switch t := e.(type) {
+	case *EventHello:
+		p := Negotiate(t)
+		session.setProtocol(p)
+		logger.Debugw(
+			"negotiated protocol",
+			"username", user.name(),
+			"sessionid", sessionId,
+			"version", p.Version,
+			"features", p.EventHello(h.clock).Features)
+		_ = h.sendEvent(p.EventHello(h.clock), sessionId)
+		h.deliverOffline(session, sessionId)
+		if state := h.readState(); len(state.Markers) > 0 {
+			_ = h.sendEvent(state, sessionId)
+		}
 	case *EventConnected:
 	case *EventUserListUpdate:
 	case *EventUserEnter:
 	case *EventUserLeave:
 		//
 	case *EventSendMessage:
-		_ = h.sendEvent(&EventNewMessage{
-			EventMeta: *NewEventMetaNow(),
-			Sender:    user.name,
+		meta := *NewEventMetaNow(h.clock)
+		mentions := ParseMentions(t.Message)
+		sender := user.name()
+		id := fmt.Sprintf("m%d", atomic.AddInt64(&h.msgInc, 1))
+		logger.Debugw("new message", "id", id, "sessionid", sessionId)
+		h.addHistory(&EventNewMessage{
+			EventMeta: meta,
+			ID:        id,
+			Sender:    sender,
+			Message:   t.Message,
+		})
+		// All sessions, the sender's own sessions included.
+		meta.Trace = tracing.Inject(ctx.Context)
+		for _, recipientId := range h.sessionIds() {
+			recipient, err := h.findSession(recipientId)
+			if err != nil {
+				continue // disconnected in the meantime
+			}
+			_ = h.sendEvent(&EventNewMessage{
+				EventMeta: meta,
+				ID:        id,
+				Sender:    sender,
+				Message:   t.Message,
+				Mentioned: IsMentioned(recipient.user.name(), mentions),
+			}, recipientId)
+		}
+		h.storeOffline(mentions, &EventNewMessage{
+			EventMeta: EventMeta{Time: meta.Time},
+			ID:        id,
+			Sender:    sender,
 			Message:   t.Message,
-		}, h.userIds()...)
+			Mentioned: true,
+		})
 
 	case *EventNewMessage:
 		//
+	case *EventHistoryRequest:
+		history := &EventHistory{
+			EventMeta: *NewEventMetaNow(h.clock),
+			RequestID: t.ID,
+			Events:    []Event{},
+		}
+		if h.adminIdentity != "" && user.identity == h.adminIdentity {
+			history.Events = h.History(t.From, t.To)
+		} else {
+			history.Error = "history requires the admin token"
+		}
+		_ = h.sendEvent(history, sessionId)
+	case *EventHistory:
+		//
+	case *EventChangeNick:
+		if err := h.changeNick(user, t.Name); err != nil {
+			_ = h.sendNotice(err.Error(), sessionId)
+		}
+	case *EventSetProfile:
+		if err := Color(t.Color).Validate(); err != nil {
+			_ = h.sendNotice(err.Error(), sessionId)
+			break
+		}
+		profile := user.getProfile()
+		profile.Status = t.Status
+		profile.Color = t.Color
+		user.setProfile(profile)
+		// All sessions of the user, to keep them in sync.
+		h.usersMu.RLock()
+		_ = h.sendEvent(&EventProfile{
+			EventMeta:   *NewEventMetaNow(h.clock),
+			UserProfile: profile,
+		}, h.userSessionIds(user)...)
+		h.usersMu.RUnlock()
+	case *EventWhois:
+		h.usersMu.RLock()
+		other, ok := h.findUserByName(t.Name)
+		h.usersMu.RUnlock()
+		if !ok {
+			_ = h.sendNotice((&ErrUserNotFound{username: t.Name}).Error(), sessionId)
+			break
+		}
+		_ = h.sendEvent(&EventProfile{
+			EventMeta:   *NewEventMetaNow(h.clock),
+			UserProfile: other.getProfile(),
+		}, sessionId)
+	case *EventNickChange:
+	case *EventProfile:
+	case *EventNotice:
+	case *EventMessageEnrichment:
+	case *EventMarkRead:
+		if err := h.markRead(user, t.MessageID); err != nil {
+			_ = h.sendNotice(err.Error(), sessionId)
+		}
+	case *EventReadState:
+	case *EventMessagesDeleted:
+	case *EventOfflineBacklog:
+		//
 	default:
 		logger.Warnw(
 			"unhandled event type",
-			"username", user.name,
-			"userid", userId,
+			"username", user.name(),
+			"sessionid", sessionId,
 			"type", reflect.TypeOf(e).String())
 	}
 	return nil
 }
 
-func (h *Hub) sendEvent(e Event, userIds ...hubId) error {
-	for _, userId := range userIds {
-		user, err := h.findUser(userId)
+// changeNick changes the display name of the user and notifies all users.
+func (h *Hub) changeNick(user *hubUser, name string) error {
+	if err := ValidateUsername(name); err != nil {
+		return err
+	}
+	h.usersMu.Lock()
+	defer h.usersMu.Unlock()
+	if other, ok := h.findUserByName(name); ok && other != user {
+		return &ErrUsernameExists{username: name}
+	}
+	profile := user.getProfile()
+	change := &EventNickChange{
+		EventMeta: *NewEventMetaNow(h.clock),
+		UserID:    profile.UserID,
+		OldName:   profile.Name,
+		NewName:   name,
+	}
+	profile.Name = name
+	user.setProfile(profile)
+	if user.identity != "" && h.offline != nil {
+		if err := h.offline.SetName(user.identity, name); err != nil {
+			h.logger.Errorw("could not store offline name", log.Error(err))
+		}
+	}
+	if user.identity != "" {
+		h.storeMember(profile)
+	}
+
+	h.addHistory(change)
+	all := h.sessionIds()
+	_ = h.sendEvent(change, all...)
+	_ = h.sendEvent(&EventUserListUpdate{
+		EventMeta: *NewEventMetaNow(h.clock),
+		Users:     h.userList(),
+	}, all...)
+	return nil
+}
+
+// storeOffline stores the message for the mentioned users that are
+// offline, if known.
+func (h *Hub) storeOffline(mentions []string, m *EventNewMessage) {
+	if h.offline == nil {
+		return
+	}
+	for _, name := range mentions {
+		h.usersMu.RLock()
+		_, online := h.findUserByName(name)
+		h.usersMu.RUnlock()
+		if online {
+			continue
+		}
+		identity, err := h.offline.IdentityByName(name)
+		if err == nil && identity != "" {
+			err = h.offline.Add(identity, m.Time, m)
+		}
 		if err != nil {
-			return err
+			h.logger.Errorw("could not store offline event", "name", name, log.Error(err))
 		}
-		if err := user.events.Add(e); err != nil {
-			_ = h.disconnectUser(userId, err, true) // unforgiving
-			return err
+	}
+}
+
+// deliverOffline sends the events the user missed while offline to the
+// session, preceded by EventOfflineBacklog. Events stay stored for
+// sessions without FeatureOffline.
+func (h *Hub) deliverOffline(session *hubSession, sessionId hubId) {
+	identity := session.user.identity
+	if h.offline == nil || identity == "" || !session.getProtocol().Features[FeatureOffline] {
+		return
+	}
+	stored, err := h.offline.Take(identity)
+	if err != nil {
+		h.logger.Errorw("could not read offline events", log.Error(err))
+		return
+	}
+	expired := h.clock.Now().Add(-h.offlineRetention)
+	events := []Event{}
+	var since time.Time
+	for _, re := range stored {
+		if re.Time.Before(expired) {
+			continue
+		}
+		if len(events) == 0 {
+			since = re.Time
+		}
+		events = append(events, re.Event)
+	}
+	if len(events) == 0 {
+		return
+	}
+	_ = h.sendEvent(&EventOfflineBacklog{
+		EventMeta: *NewEventMetaNow(h.clock),
+		Count:     len(events),
+		Since:     since,
+	}, sessionId)
+	for _, e := range events {
+		_ = h.sendEvent(e, sessionId)
+	}
+}
+
+// purgeOffline purges expired offline events until the hub closes.
+func (h *Hub) purgeOffline() {
+	for {
+		select {
+		case <-h.closed:
+			return
+		case <-h.clock.After(offlinePurgeInterval):
+			before := h.clock.Now().Add(-h.offlineRetention)
+			if err := h.offline.Purge(before); err != nil {
+				h.logger.Errorw("could not purge offline events", log.Error(err))
+			}
+		}
+	}
+}
+
+// Broadcast sends the event to all sessions.
+func (h *Hub) Broadcast(e Event) {
+	h.usersMu.RLock()
+	defer h.usersMu.RUnlock()
+	_ = h.sendEvent(e, h.sessionIds()...)
+}
+
+// sendNotice sends the message as EventNotice.
+func (h *Hub) sendNotice(message string, sessionIds ...hubId) error {
+	return h.sendEvent(&EventNotice{
+		EventMeta: *NewEventMetaNow(h.clock),
+		Message:   message,
+	}, sessionIds...)
+}
+
+// sendEvent sends the event to the sessions through the outbound
+// middleware. A failing session does not keep the event from the other
+// sessions; errors are logged and the first one is returned.
+func (h *Hub) sendEvent(e Event, sessionIds ...hubId) error {
+	var firstErr error
+	for _, sessionId := range sessionIds {
+		err := h.sendSessionEvent(e, sessionId)
+		if err == nil {
+			continue
+		}
+		h.logger.Warnw("could not send event",
+			"session", sessionId, "type", reflect.TypeOf(e).String(), log.Error(err))
+		if firstErr == nil {
+			firstErr = err
 		}
 	}
+	return firstErr
+}
+
+func (h *Hub) sendSessionEvent(e Event, sessionId hubId) error {
+	session, err := h.findSession(sessionId)
+	if err != nil {
+		return err
+	}
+	if !session.getProtocol().Supports(e) {
+		return nil
+	}
+	return h.outbound(&EventContext{
+		Hub:       h,
+		Direction: Outbound,
+		SessionID: sessionId,
+		User:      session.user.getProfile(),
+	}, e)
+}
+
+// deliverEvent is the outbound handler, adding the event to the
+// session queue.
+func (h *Hub) deliverEvent(ctx *EventContext, e Event) error {
+	session, err := h.findSession(ctx.SessionID)
+	if err != nil {
+		return err
+	}
+	if err := session.events.Add(e); err != nil {
+		_ = h.disconnectSession(ctx.SessionID, err, true) // unforgiving
+		return err
+	}
+	atomic.AddInt64(&h.eventsOut, 1)
 	return nil
 }
 
-func NewHub(logger log.Logger) *Hub {
-	return &Hub{
-		logger:  logger,
-		users:   kvstore.NewKVStore[int, *hubUser](),
-		usersMu: sync.RWMutex{},
-		idInc:   0,
-		closed:  make(chan struct{}),
+func NewHub(logger log.Logger, opts HubOpts) *Hub {
+	c := clock.OrReal(opts.Clock)
+	h := &Hub{
+		logger:    logger,
+		clock:     c,
+		startedAt: c.Now(),
+		sessions:  kvstore.NewKVStore[int, *hubSession](),
+		usersMu:   sync.RWMutex{},
+		idInc:     0,
+		closed:    make(chan struct{}),
+		history:   NewHistory(DefaultHistoryLimit),
+		reads:     map[string]string{},
+		store:     opts.Store,
+
+		storeWrites:   make(chan storeWrite, storeQueueSize),
+		storeDone:     make(chan struct{}),
+		adminIdentity: IdentityFromAuthorization("Bearer " + opts.AdminToken),
+	}
+	if h.store == nil {
+		h.store = NewMemoryStore()
+	}
+	h.loadStore()
+	go h.writeStore()
+	if opts.Offline != nil {
+		h.offline = opts.Offline
+		h.offlineRetention = opts.OfflineRetention
+		if h.offlineRetention == 0 {
+			h.offlineRetention = DefaultOfflineRetention
+		}
+		go h.purgeOffline()
 	}
+	if opts.Retention != nil {
+		h.retention = opts.Retention
+		go h.purgeHistory()
+	}
+	if opts.IdleTimeout > 0 {
+		h.idleTimeout = opts.IdleTimeout
+		go h.evictIdle()
+	}
+	h.Use()
+	return h
 }
 
 // Used when disconnecting users on close,
@@ -301,13 +875,26 @@ func (e *ErrUserNotFound) Error() string {
 	return fmt.Sprintf(`uknown user "%s"`, e.username)
 }
 
-// ErrUserIdNotFound when hub did not find the user by id.
-type ErrUserIdNotFound struct {
+// ErrSessionIdNotFound when hub did not find the session by id.
+type ErrSessionIdNotFound struct {
 	id hubId
 }
 
-func (e *ErrUserIdNotFound) Error() string {
-	return fmt.Sprintf(`uknown user id "%d"`, e.id)
+func (e *ErrSessionIdNotFound) Error() string {
+	return fmt.Sprintf(`uknown session id "%d"`, e.id)
+}
+
+// ErrBanned for when the user is banned from the room.
+type ErrBanned struct {
+	username string
+	reason   string
+}
+
+func (e *ErrBanned) Error() string {
+	if e.reason == "" {
+		return fmt.Sprintf(`user "%s" is banned`, e.username)
+	}
+	return fmt.Sprintf(`user "%s" is banned: %s`, e.username, e.reason)
 }
 
 // ErrUsernameExists for when the hub already has the user(name)
@@ -318,3 +905,13 @@ type ErrUsernameExists struct {
 func (e *ErrUsernameExists) Error() string {
 	return fmt.Sprintf(`user "%s" already exists`, e.username)
 }
+
+// ErrIdentityConnected for when the identity is connected with
+// another username.
+type ErrIdentityConnected struct {
+	username string
+}
+
+func (e *ErrIdentityConnected) Error() string {
+	return fmt.Sprintf(`already connected as "%s"`, e.username)
+}
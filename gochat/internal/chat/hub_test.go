@@ -1,24 +1,22 @@
 package chat
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/marcelbeumer/go-playground/gochat/internal/util/now"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
 	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestHubConnectUserEvents(t *testing.T) {
-	nowStub := now.SetupStub()
-	t.Cleanup(func() {
-		now.ClearStub()
-	})
-
-	nowStub.Frozen = true // less brittle
-	startTime := nowStub.Time
+	fake := clock.NewFake(time.UnixMilli(0))
+	startTime := fake.Now()
 
-	hub := NewHub(test.NewTestLogger(true))
+	hub := NewHub(test.NewTestLogger(true), HubOpts{Clock: fake})
 
 	user1Ch := make(chan Event)
 	user2Ch := make(chan Event)
@@ -100,3 +98,283 @@ func TestHubConnectUserEvents(t *testing.T) {
 	assert.Equal(t, expectedUser1, user1Events)
 	assert.Equal(t, expectedUser2, user2Events)
 }
+
+func TestHubMentions(t *testing.T) {
+	fake := clock.NewFake(time.UnixMilli(0))
+
+	hub := NewHub(test.NewTestLogger(true), HubOpts{Clock: fake})
+	t.Cleanup(func() { _ = hub.Close() })
+
+	user1In := make(chan Event)
+	user1Out := make(chan Event)
+	user2Out := make(chan Event)
+	user1Conn := NewTestConnection(user1In, user1Out)
+	user2Conn := NewTestConnection(make(chan Event), user2Out)
+
+	_, err := hub.Connect("user1", user1Conn)
+	require.NoError(t, err)
+	_, err = test.ChTimeout(t, user1Out) // connected
+	require.NoError(t, err)
+
+	_, err = hub.Connect("user2", user2Conn)
+	require.NoError(t, err)
+
+	nextMessage := func(ch <-chan Event) *EventNewMessage {
+		for {
+			e, err := test.ChTimeout(t, ch)
+			require.NoError(t, err)
+			if m, ok := e.(*EventNewMessage); ok {
+				return m
+			}
+		}
+	}
+
+	user1In <- &EventSendMessage{Message: "hi @User2!"}
+
+	assert.Equal(t, &EventNewMessage{
+		EventMeta: EventMeta{Time: fake.Now()},
+		ID:        "m1",
+		Sender:    "user1",
+		Message:   "hi @User2!",
+		Mentioned: false,
+	}, nextMessage(user1Out))
+
+	assert.Equal(t, &EventNewMessage{
+		EventMeta: EventMeta{Time: fake.Now()},
+		ID:        "m1",
+		Sender:    "user1",
+		Message:   "hi @User2!",
+		Mentioned: true,
+	}, nextMessage(user2Out))
+}
+
+func TestHubHello(t *testing.T) {
+	fake := clock.NewFake(time.UnixMilli(0))
+
+	hub := NewHub(test.NewTestLogger(true), HubOpts{Clock: fake})
+
+	userIn := make(chan Event)
+	userOut := make(chan Event)
+	_, err := hub.Connect("user", NewTestConnection(userIn, userOut))
+	require.NoError(t, err)
+	e, err := test.ChTimeout(t, userOut)
+	require.NoError(t, err)
+	require.IsType(t, &EventConnected{}, e)
+
+	userIn <- &EventHello{Version: ProtocolVersion + 1, Features: []string{"x", FeatureMentions}}
+
+	e, err = test.ChTimeout(t, userOut)
+	require.NoError(t, err)
+	assert.Equal(t, &EventHello{
+		EventMeta: EventMeta{Time: fake.Now()},
+		Version:   ProtocolVersion,
+		Features:  []string{FeatureMentions},
+	}, e)
+
+	// Version 1 clients never send hello and still get messages.
+	v1Out := make(chan Event)
+	_, err = hub.Connect("v1", NewTestConnection(make(chan Event), v1Out))
+	require.NoError(t, err)
+	e, err = test.ChTimeout(t, v1Out)
+	require.NoError(t, err)
+	require.IsType(t, &EventConnected{}, e)
+
+	userIn <- &EventSendMessage{Message: "hi"}
+	for {
+		e, err = test.ChTimeout(t, v1Out)
+		require.NoError(t, err)
+		if m, ok := e.(*EventNewMessage); ok {
+			assert.Equal(t, "hi", m.Message)
+			break
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	for _, ch := range []chan Event{userOut, v1Out} {
+		ch := ch
+		go func() {
+			for {
+				select {
+				case <-ch:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	require.NoError(t, hub.Close())
+}
+
+func TestHubDisconnectDoesNotBlock(t *testing.T) {
+	hub := NewHub(test.NewTestLogger(true), HubOpts{})
+
+	// A user that never reads keeps pending events on disconnect.
+	userId, err := hub.Connect("gone", NewTestConnection(make(chan Event), make(chan Event)))
+	require.NoError(t, err)
+	_, err = hub.Connect("user", NewTestConnection(make(chan Event), make(chan Event)))
+	require.NoError(t, err)
+	go func() { _ = hub.Disconnect(userId) }()
+
+	err = test.GoTimeoutDur(t, func() error {
+		// Give the disconnect time to take the lock.
+		time.Sleep(10 * time.Millisecond)
+		_, err := hub.Connect("other", NewTestConnection(make(chan Event), make(chan Event)))
+		return err
+	}, time.Second)
+	require.NoError(t, err)
+}
+
+func TestHubDisconnectDrainTimeout(t *testing.T) {
+	fake := clock.NewFake(time.UnixMilli(0))
+	hub := NewHub(test.NewTestLogger(true), HubOpts{Clock: fake})
+
+	// A user that never reads keeps pending events on disconnect.
+	goneConn := NewTestConnection(make(chan Event), make(chan Event))
+	goneId, err := hub.Connect("gone", goneConn)
+	require.NoError(t, err)
+	_, err = hub.Connect("user", NewTestConnection(make(chan Event), make(chan Event, 10)))
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() { done <- hub.Disconnect(goneId) }()
+
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+	assert.False(t, goneConn.Closed(), "closed before the drain timeout")
+	fake.Advance(time.Second)
+	disconnectErr, err := test.ChTimeout(t, done)
+	require.NoError(t, err)
+	require.NoError(t, disconnectErr)
+	assert.True(t, goneConn.Closed())
+}
+
+// nextEvent returns the next event on ch of type T.
+func nextEvent[T Event](t *testing.T, ch <-chan Event) T {
+	for {
+		e, err := test.ChTimeout(t, ch)
+		require.NoError(t, err)
+		if m, ok := e.(T); ok {
+			return m
+		}
+	}
+}
+
+func TestHubNickChange(t *testing.T) {
+	hub := NewHub(test.NewTestLogger(true), HubOpts{})
+	t.Cleanup(func() { _ = hub.Close() })
+
+	user1In := make(chan Event)
+	user1Out := make(chan Event, 10)
+	user2Out := make(chan Event, 10)
+	_, err := hub.Connect("user1", NewTestConnection(user1In, user1Out))
+	require.NoError(t, err)
+	_, err = hub.Connect("user2", NewTestConnection(make(chan Event), user2Out))
+	require.NoError(t, err)
+
+	for _, name := range []string{"", "  ", "not valid", strings.Repeat("m", MaxUsernameLength+1)} {
+		_, err := hub.Connect(name, NewTestConnection(make(chan Event), make(chan Event, 10)))
+		assert.EqualError(t, err, fmt.Sprintf(`invalid name "%s"`, name))
+	}
+	assert.Len(t, hub.Sessions(), 2)
+
+	_, err = hub.Connect("USER2", NewTestConnection(make(chan Event), make(chan Event, 10)))
+	assert.EqualError(t, err, `user "USER2" already exists`)
+
+	user1In <- &EventChangeNick{Name: "user2"}
+	assert.Equal(t, `user "user2" already exists`, nextEvent[*EventNotice](t, user1Out).Message)
+	user1In <- &EventChangeNick{Name: "User2"}
+	assert.Equal(t, `user "User2" already exists`, nextEvent[*EventNotice](t, user1Out).Message)
+	user1In <- &EventChangeNick{Name: "not valid"}
+	assert.Equal(t, `invalid name "not valid"`, nextEvent[*EventNotice](t, user1Out).Message)
+
+	user1In <- &EventChangeNick{Name: "mario"}
+	for _, ch := range []chan Event{user1Out, user2Out} {
+		change := nextEvent[*EventNickChange](t, ch)
+		assert.Equal(t, "guest-1", change.UserID)
+		assert.Equal(t, "user1", change.OldName)
+		assert.Equal(t, "mario", change.NewName)
+		assert.Equal(t, []string{"mario", "user2"}, nextEvent[*EventUserListUpdate](t, ch).Users)
+	}
+
+	user1In <- &EventSetProfile{Status: "away", Color: "blue"}
+	assert.Equal(t, UserProfile{
+		UserID: "guest-1",
+		Name:   "mario",
+		Status: "away",
+		Color:  "blue",
+	}, nextEvent[*EventProfile](t, user1Out).UserProfile)
+	user1In <- &EventSetProfile{Color: "purple"}
+	assert.Equal(t, `invalid color "purple"`, nextEvent[*EventNotice](t, user1Out).Message)
+
+	user1In <- &EventWhois{Name: "user2"}
+	assert.Equal(t, UserProfile{
+		UserID: "guest-2",
+		Name:   "user2",
+	}, nextEvent[*EventProfile](t, user1Out).UserProfile)
+	user1In <- &EventWhois{Name: "user1"}
+	assert.Equal(t, `uknown user "user1"`, nextEvent[*EventNotice](t, user1Out).Message)
+}
+
+func TestHubMultipleSessions(t *testing.T) {
+	hub := NewHub(test.NewTestLogger(true), HubOpts{})
+	t.Cleanup(func() { _ = hub.Close() })
+	identity := IdentityFromAuthorization("Bearer secret")
+
+	laptopIn := make(chan Event)
+	laptopOut := make(chan Event, 10)
+	laptopId, err := hub.ConnectIdentity(identity, "mario", NewTestConnection(laptopIn, laptopOut))
+	require.NoError(t, err)
+	otherOut := make(chan Event, 10)
+	_, err = hub.Connect("other", NewTestConnection(make(chan Event), otherOut))
+	require.NoError(t, err)
+	laptopIn <- &EventSetProfile{Status: "here"}
+	nextEvent[*EventProfile](t, laptopOut)
+
+	// Anonymous and other identities can not take the name.
+	_, err = hub.Connect("mario", NewTestConnection(make(chan Event), make(chan Event, 10)))
+	assert.ErrorAs(t, err, new(*ErrUsernameExists))
+	other := IdentityFromAuthorization("Bearer other")
+	_, err = hub.ConnectIdentity(other, "mario", NewTestConnection(make(chan Event), make(chan Event, 10)))
+	assert.ErrorAs(t, err, new(*ErrUsernameExists))
+
+	// The identity can not connect by another name, so one user ID is
+	// online once.
+	_, err = hub.ConnectIdentity(identity, "luigi", NewTestConnection(make(chan Event), make(chan Event, 10)))
+	assert.EqualError(t, err, `already connected as "mario"`)
+
+	botIn := make(chan Event)
+	botOut := make(chan Event, 10)
+	_, err = hub.ConnectIdentity(identity, "Mario", NewTestConnection(botIn, botOut))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mario", "other"}, nextEvent[*EventConnected](t, botOut).Users)
+
+	// Sessions share the profile.
+	botIn <- &EventWhois{Name: "mario"}
+	profile := nextEvent[*EventProfile](t, botOut)
+	assert.Equal(t, "u"+identity[:12], profile.UserID)
+	assert.Equal(t, "here", profile.Status)
+
+	// Messages are echoed to all sessions.
+	botIn <- &EventSendMessage{Message: "beep"}
+	for _, ch := range []chan Event{laptopOut, botOut, otherOut} {
+		m := nextEvent[*EventNewMessage](t, ch)
+		assert.Equal(t, "mario", m.Sender)
+		assert.Equal(t, "beep", m.Message)
+	}
+
+	// Mario stays online while a session lives.
+	require.NoError(t, hub.Disconnect(laptopId))
+	botIn <- &EventSendMessage{Message: "still here"}
+	for {
+		e := nextEvent[Event](t, otherOut)
+		if m, ok := e.(*EventNewMessage); ok {
+			assert.Equal(t, "still here", m.Message)
+			break
+		}
+		switch e.(type) {
+		case *EventUserEnter, *EventUserLeave:
+			assert.Fail(t, "unexpected presence event", "%#v", e)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package chat
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIdleTimeout is the reason of disconnecting idle sessions.
+var ErrIdleTimeout = errors.New("idle timeout")
+
+// touch marks the session active at the time.
+func (s *hubSession) touch(t time.Time) {
+	atomic.StoreInt64(&s.lastEvent, t.UnixNano())
+}
+
+// lastActivity returns the hub time of the last inbound event.
+// Transport keepalive is no activity: it keeps idle clients connected,
+// dead peers are dropped by the transports themselves.
+func (s *hubSession) lastActivity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastEvent))
+}
+
+// evictIdle disconnects sessions without activity for the idle timeout
+// until the hub closes.
+func (h *Hub) evictIdle() {
+	for {
+		select {
+		case <-h.closed:
+			return
+		case <-h.clock.After(h.idleTimeout / 2):
+			before := h.clock.Now().Add(-h.idleTimeout)
+			for _, sessionId := range h.sessions.Keys() {
+				session, err := h.findSession(sessionId)
+				if err != nil || !session.lastActivity().Before(before) {
+					continue
+				}
+				h.logger.Infow("evicting idle session",
+					"sessionId", sessionId,
+					"username", session.user.name())
+				_ = h.sendNotice("disconnected: "+ErrIdleTimeout.Error(), sessionId)
+				go func(sessionId hubId) {
+					_ = h.disconnectSession(sessionId, ErrIdleTimeout, true)
+				}(sessionId)
+			}
+		}
+	}
+}
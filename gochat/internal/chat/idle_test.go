@@ -0,0 +1,46 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubIdleTimeout(t *testing.T) {
+	fake := clock.NewFake(time.UnixMilli(0))
+	hub := NewHub(test.NewTestLogger(true), HubOpts{
+		Clock:       fake,
+		IdleTimeout: 10 * time.Minute,
+	})
+	t.Cleanup(func() { _ = hub.Close() })
+
+	aliceIn := make(chan Event)
+	aliceOut := make(chan Event, 10)
+	aliceConn := NewTestConnection(aliceIn, aliceOut)
+	_, err := hub.Connect("alice", aliceConn)
+	require.NoError(t, err)
+	nextEvent[*EventConnected](t, aliceOut)
+	bobIn := make(chan Event)
+	bobOut := make(chan Event, 10)
+	_, err = hub.Connect("bob", NewTestConnection(bobIn, bobOut))
+	require.NoError(t, err)
+	nextEvent[*EventConnected](t, bobOut)
+
+	fake.BlockUntil(1)
+	fake.Advance(5 * time.Minute)
+	bobIn <- &EventSendMessage{Message: "still here"}
+	nextEvent[*EventNewMessage](t, aliceOut)
+
+	fake.BlockUntil(1)
+	fake.Advance(6 * time.Minute)
+	notice := nextEvent[*EventNotice](t, aliceOut)
+	assert.Contains(t, notice.Message, ErrIdleTimeout.Error())
+	leave := nextEvent[*EventUserLeave](t, bobOut)
+	assert.Equal(t, "alice", leave.Name)
+	require.NoError(t, test.GoTimeout(t, aliceConn.Wait))
+	assert.Equal(t, []string{"bob"}, hub.userList())
+}
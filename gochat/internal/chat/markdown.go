@@ -0,0 +1,289 @@
+package chat
+
+import (
+	"strings"
+)
+
+// Messages support a Markdown subset: **bold** (or __bold__), *italics*
+// (or _italics_), `inline code`, [links](https://example.com) and code
+// blocks fenced by ``` lines. Backslashes escape the markers.
+
+// mdStyle is a set of text styles.
+type mdStyle uint8
+
+const (
+	mdBold mdStyle = 1 << iota
+	mdItalic
+	mdCode
+	mdLink
+)
+
+// mdCodeIndent indents the lines of code blocks.
+const mdCodeIndent = "    "
+
+// mdEscapable are the characters that can be escaped with a backslash.
+const mdEscapable = "\\`*_[]()"
+
+// mdSpan is text with a style.
+type mdSpan struct {
+	text  string
+	style mdStyle
+}
+
+// ansi returns the escape codes of the style.
+func (s mdStyle) ansi() string {
+	codes := []string{}
+	if s&mdBold != 0 {
+		codes = append(codes, "1")
+	}
+	if s&mdItalic != 0 {
+		codes = append(codes, "3")
+	}
+	if s&mdLink != 0 {
+		codes = append(codes, "4")
+	}
+	if s&mdCode != 0 {
+		codes = append(codes, "36")
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
+
+// FormatMarkdownANSI renders the Markdown of the message with ANSI
+// escape codes, with "@username" mentions in bold.
+func FormatMarkdownANSI(message string) string {
+	var b strings.Builder
+	for i, line := range parseMarkdown(message) {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		var style mdStyle
+		for _, span := range mentionSpans(line) {
+			if span.style != style {
+				// Resets before each change, as not all terminals (and
+				// gocui) know how to end single styles.
+				if style != 0 {
+					b.WriteString("\x1b[0m")
+				}
+				if span.style != 0 {
+					b.WriteString(span.style.ansi())
+				}
+				style = span.style
+			}
+			b.WriteString(span.text)
+		}
+		if style != 0 {
+			b.WriteString("\x1b[0m") // styles end with the line
+		}
+	}
+	return b.String()
+}
+
+// StripMarkdown returns the message as plain text, without the
+// Markdown markers.
+func StripMarkdown(message string) string {
+	var b strings.Builder
+	for i, line := range parseMarkdown(message) {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		for _, span := range line {
+			b.WriteString(span.text)
+		}
+	}
+	return b.String()
+}
+
+// parseMarkdown parses the message into lines of spans. Code block
+// lines keep their indentation, indented by mdCodeIndent.
+func parseMarkdown(message string) [][]mdSpan {
+	lines := [][]mdSpan{}
+	code := false
+	for _, line := range strings.Split(message, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if isFence(trimmed, code) {
+			code = !code
+			continue
+		}
+		if code {
+			if line == "" {
+				lines = append(lines, []mdSpan{})
+			} else {
+				lines = append(lines, []mdSpan{{text: mdCodeIndent + line, style: mdCode}})
+			}
+			continue
+		}
+		lines = append(lines, parseInline(line, 0))
+	}
+	return lines
+}
+
+// isFence returns true for lines opening (with optional language) or
+// closing code blocks.
+func isFence(trimmed string, inCode bool) bool {
+	if !strings.HasPrefix(trimmed, "```") {
+		return false
+	}
+	if inCode {
+		return trimmed == "```"
+	}
+	return !strings.Contains(trimmed[3:], "`")
+}
+
+// parseInline parses the inline Markdown of the line with the style of
+// the surrounding text.
+func parseInline(s string, style mdStyle) []mdSpan {
+	spans := []mdSpan{}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			spans = append(spans, mdSpan{text: text.String(), style: style})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(mdEscapable, s[i+1]) >= 0:
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			n := runLength(s, i)
+			fence := s[i : i+n]
+			if end := strings.Index(s[i+n:], fence); end > 0 {
+				flush()
+				spans = append(spans, mdSpan{text: s[i+n : i+n+end], style: style | mdCode})
+				i += n + end + n
+				continue
+			}
+			text.WriteString(fence)
+			i += n
+			continue
+
+		case c == '[':
+			if label, url, n, ok := parseLink(s[i:]); ok {
+				flush()
+				spans = append(spans, parseInline(label, style|mdLink)...)
+				if url != label {
+					spans = append(spans, mdSpan{text: " (" + url + ")", style: style})
+				}
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_':
+			n := runLength(s, i)
+			if n <= 2 {
+				if end, ok := findEmphasis(s, i, n); ok {
+					flush()
+					flag := mdItalic
+					if n == 2 {
+						flag = mdBold
+					}
+					spans = append(spans, parseInline(s[i+n:end], style|flag)...)
+					i = end + n
+					continue
+				}
+			}
+			text.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+		text.WriteByte(c)
+		i++
+	}
+	flush()
+	return spans
+}
+
+// runLength returns the number of repeats of the byte at i.
+func runLength(s string, i int) int {
+	n := 1
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+// parseLink parses "[label](url)" at the start of s, returning the
+// length of the link.
+func parseLink(s string) (label string, url string, n int, ok bool) {
+	end := strings.Index(s, "](")
+	if end <= 1 || strings.IndexByte(s[1:end], ']') >= 0 {
+		return "", "", 0, false
+	}
+	urlEnd := strings.IndexByte(s[end+2:], ')')
+	if urlEnd <= 0 {
+		return "", "", 0, false
+	}
+	url = s[end+2 : end+2+urlEnd]
+	if strings.ContainsAny(url, " \t") {
+		return "", "", 0, false
+	}
+	return s[1:end], url, end + 2 + urlEnd + 1, true
+}
+
+// findEmphasis returns the index of the delimiter closing the emphasis
+// opened by the n delimiters at i. Underscores only emphasize whole
+// words, so snake_case stays as is.
+func findEmphasis(s string, i int, n int) (int, bool) {
+	c := s[i]
+	open := i + n
+	if open >= len(s) || s[open] == ' ' {
+		return 0, false
+	}
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return 0, false
+	}
+	for j := open + 1; j+n <= len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] != c || runLength(s, j) != n || s[j-1] == ' ' {
+			if s[j] == c {
+				j += runLength(s, j) - 1
+			}
+			continue
+		}
+		if c == '_' && j+n < len(s) && isWordByte(s[j+n]) {
+			continue
+		}
+		return j, true
+	}
+	return 0, false
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' ||
+		(b >= '0' && b <= '9') ||
+		(b >= 'a' && b <= 'z') ||
+		(b >= 'A' && b <= 'Z')
+}
+
+// mentionSpans splits the "@username" mentions of the spans outside of
+// code into bold spans.
+func mentionSpans(spans []mdSpan) []mdSpan {
+	out := make([]mdSpan, 0, len(spans))
+	for _, span := range spans {
+		if span.style&mdCode != 0 {
+			out = append(out, span)
+			continue
+		}
+		last := 0
+		for _, m := range mentionRe.FindAllStringSubmatchIndex(span.text, -1) {
+			at := m[4] - 1 // the "@" before the name
+			if at > last {
+				out = append(out, mdSpan{text: span.text[last:at], style: span.style})
+			}
+			out = append(out, mdSpan{text: span.text[at:m[5]], style: span.style | mdBold})
+			last = m[5]
+		}
+		if last < len(span.text) {
+			out = append(out, mdSpan{text: span.text[last:], style: span.style})
+		}
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripMarkdown(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"plain text", "plain text"},
+		{"**bold** and __bold__", "bold and bold"},
+		{"*italics* and _italics_", "italics and italics"},
+		{"*italics with **bold** inside*", "italics with bold inside"},
+		{"use `go vet` and ``a ` tick``", "use go vet and a ` tick"},
+		{"`**not bold**`", "**not bold**"},
+		{"see [the docs](https://example.com)", "see the docs (https://example.com)"},
+		{"[https://example.com](https://example.com)", "https://example.com"},
+		{"snake_case_name and 2 * 3 * 4", "snake_case_name and 2 * 3 * 4"},
+		{"unclosed **bold and `code", "unclosed **bold and `code"},
+		{`\*escaped\* and \_too\_`, "*escaped* and _too_"},
+		{"[not a link] (x)", "[not a link] (x)"},
+		{
+			"look:\n```go\nfunc main() {\n\tfmt.Println(\"*hi*\")\n}\n```\ndone",
+			"look:\n    func main() {\n    \tfmt.Println(\"*hi*\")\n    }\ndone",
+		},
+		{"```\nunclosed\n  block", "    unclosed\n      block"},
+		{"```inline```", "inline"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, StripMarkdown(tt.message), tt.message)
+	}
+}
+
+func TestFormatMarkdownANSI(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"plain text", "plain text"},
+		{"**bold** text", "\x1b[1mbold\x1b[0m text"},
+		{"*it **both** it*", "\x1b[3mit \x1b[0m\x1b[1;3mboth\x1b[0m\x1b[3m it\x1b[0m"},
+		{"run `ls`", "run \x1b[36mls\x1b[0m"},
+		{"[docs](https://example.com)", "\x1b[4mdocs\x1b[0m (https://example.com)"},
+		{"hi @bob!", "hi \x1b[1m@bob\x1b[0m!"},
+		{"`@bob`", "\x1b[36m@bob\x1b[0m"},
+		{"```\n  indented\n```", "\x1b[36m      indented\x1b[0m"},
+		{"**multi\nline**", "**multi\nline**"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FormatMarkdownANSI(tt.message), tt.message)
+	}
+}
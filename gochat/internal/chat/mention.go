@@ -0,0 +1,59 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// mentionRe matches "@username" when not preceded by a word character,
+// so e-mail addresses like "mario@example.com" are not mentions.
+var mentionRe = regexp.MustCompile(`(^|[^\w@])@([\w][\w.-]*)`)
+
+// ParseMentions returns the unique usernames mentioned with "@username"
+// in message, in order of appearance.
+func ParseMentions(message string) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, m := range mentionRe.FindAllStringSubmatch(message, -1) {
+		name := strings.TrimRight(m[2], ".-") // trailing punctuation
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// IsMentioned returns true when username is in mentions (case insensitive).
+func IsMentioned(username string, mentions []string) bool {
+	for _, m := range mentions {
+		if SameUsername(m, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameUsername returns true when the usernames are the same, ignoring
+// case. Names are unique, mentioned and looked up by this rule.
+func SameUsername(a string, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// usernameRe matches usernames that can be mentioned.
+var usernameRe = regexp.MustCompile(`^\w([\w.-]*\w)?$`)
+
+// MaxUsernameLength is the maximum length of usernames.
+const MaxUsernameLength = 32
+
+// ValidateUsername returns an error for names that can not be mentioned
+// or are too long, used when connecting and for nick changes.
+func ValidateUsername(name string) error {
+	if !usernameRe.MatchString(name) || len(name) > MaxUsernameLength {
+		return fmt.Errorf(`invalid name "%s"`, name)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected []string
+	}{
+		{"hello", []string{}},
+		{"@mario", []string{"mario"}},
+		{"hi @mario and @luigi!", []string{"mario", "luigi"}},
+		{"@mario, @Mario, @MARIO", []string{"mario"}},
+		{"see you @peach.", []string{"peach"}},
+		{"mail mario@example.com", []string{}},
+		{"(@bowser)", []string{"bowser"}},
+		{"@@toad", []string{}},
+		{"@", []string{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, ParseMentions(tt.message), tt.message)
+	}
+}
+
+func TestIsMentioned(t *testing.T) {
+	mentions := []string{"mario", "Luigi"}
+	assert.True(t, IsMentioned("Mario", mentions))
+	assert.True(t, IsMentioned("luigi", mentions))
+	assert.False(t, IsMentioned("peach", mentions))
+	assert.False(t, IsMentioned("peach", nil))
+}
+
+func TestValidateUsername(t *testing.T) {
+	for _, name := range []string{"mario", "Mario_64", "princess.peach", "a"} {
+		assert.NoError(t, ValidateUsername(name), name)
+	}
+	for _, name := range []string{
+		"", " ", "mario bros", "@mario", "mario.", "-luigi",
+		strings.Repeat("m", MaxUsernameLength+1),
+	} {
+		assert.Error(t, ValidateUsername(name), name)
+	}
+}
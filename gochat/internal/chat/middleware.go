@@ -0,0 +1,147 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/unfurl"
+)
+
+// Direction of an event through the hub.
+type Direction string
+
+const (
+	// Inbound events are read from a session.
+	Inbound Direction = "inbound"
+	// Outbound events are sent to a session.
+	Outbound Direction = "outbound"
+)
+
+// EventContext is the context of an event in the hub middleware.
+type EventContext struct {
+	// Context carries the span of the event, see StartEventSpan.
+	Context   context.Context
+	Hub       *Hub
+	Direction Direction
+	// SessionID is the session the event is read from (inbound)
+	// or sent to (outbound).
+	SessionID int
+	// User is the profile of the user of the session.
+	User UserProfile
+}
+
+// Handler handles an event in the hub.
+type Handler func(ctx *EventContext, e Event) error
+
+// Middleware wraps a handler. It can change the event by passing another
+// event to next, or drop it by not calling next. Outbound events are
+// shared between sessions, so they must be replaced and not changed.
+// Inbound errors are sent to the user as EventNotice.
+type Middleware func(next Handler) Handler
+
+// Chain returns h wrapped in the middleware, the first middleware
+// being the outermost.
+func Chain(h Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// Plugin is hub middleware for both directions, see Hub.Use.
+// Either middleware can be nil.
+type Plugin struct {
+	Inbound  Middleware
+	Outbound Middleware
+}
+
+// PluginOpts are the options of NewPlugin.
+type PluginOpts struct {
+	Logger log.Logger
+	// Unfurl are the options of the "unfurl" plugin.
+	Unfurl unfurl.Options
+}
+
+// plugins are the plugins by name.
+var plugins = map[string]func(opts PluginOpts) *Plugin{
+	"audit":     func(opts PluginOpts) *Plugin { return NewAuditPlugin(opts.Logger) },
+	"profanity": func(PluginOpts) *Plugin { return NewProfanityPlugin(profanityWords) },
+	"unfurl": func(opts PluginOpts) *Plugin {
+		return NewUnfurlPlugin(unfurl.New(opts.Unfurl), opts.Logger)
+	},
+}
+
+// PluginNames returns the names for NewPlugin.
+func PluginNames() []string {
+	names := []string{}
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPlugin returns the plugin by name.
+func NewPlugin(name string, opts PluginOpts) (*Plugin, error) {
+	newPlugin, ok := plugins[name]
+	if !ok {
+		return nil, fmt.Errorf(
+			`unknown middleware "%s", available: %s`,
+			name,
+			strings.Join(PluginNames(), ", "),
+		)
+	}
+	return newPlugin(opts), nil
+}
+
+// NewAuditPlugin logs all inbound events with their user.
+func NewAuditPlugin(logger log.Logger) *Plugin {
+	logger = logger.Named("audit")
+	return &Plugin{
+		Inbound: func(next Handler) Handler {
+			return func(ctx *EventContext, e Event) error {
+				logger.WithContext(ctx.Context).Infow(
+					"event",
+					"type", reflect.TypeOf(e).String(),
+					"userId", ctx.User.UserID,
+					"username", ctx.User.Name,
+					"sessionId", ctx.SessionID)
+				return next(ctx, e)
+			}
+		},
+	}
+}
+
+// profanityWords are the words masked by the "profanity" plugin.
+var profanityWords = []string{
+	"arse", "bastard", "bitch", "bollocks", "crap", "damn", "fuck", "shit",
+}
+
+// NewProfanityPlugin masks the words (case insensitive) in sent messages
+// with asterisks.
+func NewProfanityPlugin(words []string) *Plugin {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	re := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	return &Plugin{
+		Inbound: func(next Handler) Handler {
+			return func(ctx *EventContext, e Event) error {
+				if t, ok := e.(*EventSendMessage); ok && len(words) > 0 {
+					masked := *t
+					masked.Message = re.ReplaceAllStringFunc(t.Message, func(w string) string {
+						return strings.Repeat("*", len([]rune(w)))
+					})
+					e = &masked
+				}
+				return next(ctx, e)
+			}
+		},
+	}
+}
@@ -0,0 +1,152 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordMiddleware appends name to calls before and after next.
+func recordMiddleware(name string, calls *[]string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *EventContext, e Event) error {
+			*calls = append(*calls, name+">")
+			err := next(ctx, e)
+			*calls = append(*calls, "<"+name)
+			return err
+		}
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	calls := []string{}
+	h := Chain(func(ctx *EventContext, e Event) error {
+		calls = append(calls, "handler")
+		return nil
+	}, recordMiddleware("a", &calls), recordMiddleware("b", &calls))
+
+	require.NoError(t, h(&EventContext{}, &EventSendMessage{}))
+	assert.Equal(t, []string{"a>", "b>", "handler", "<b", "<a"}, calls)
+}
+
+func TestChainShortCircuit(t *testing.T) {
+	calls := []string{}
+	errBlocked := errors.New("blocked")
+	block := func(next Handler) Handler {
+		return func(ctx *EventContext, e Event) error {
+			return errBlocked
+		}
+	}
+	h := Chain(func(ctx *EventContext, e Event) error {
+		calls = append(calls, "handler")
+		return nil
+	}, recordMiddleware("a", &calls), block, recordMiddleware("b", &calls))
+
+	assert.Equal(t, errBlocked, h(&EventContext{}, &EventSendMessage{}))
+	assert.Equal(t, []string{"a>", "<a"}, calls)
+}
+
+func TestProfanityPlugin(t *testing.T) {
+	var got Event
+	h := Chain(func(ctx *EventContext, e Event) error {
+		got = e
+		return nil
+	}, NewProfanityPlugin([]string{"darn", "heck"}).Inbound)
+
+	sent := &EventSendMessage{Message: "Darn it, what the heck, darning"}
+	require.NoError(t, h(&EventContext{}, sent))
+	assert.Equal(t, "**** it, what the ****, darning", got.(*EventSendMessage).Message)
+	assert.Equal(t, "Darn it, what the heck, darning", sent.Message, "not changed")
+}
+
+func TestNewPlugin(t *testing.T) {
+	for _, name := range PluginNames() {
+		p, err := NewPlugin(name, PluginOpts{Logger: &log.NoopLoggerAdapter{}})
+		require.NoError(t, err)
+		assert.NotNil(t, p)
+	}
+	_, err := NewPlugin("translate", PluginOpts{Logger: &log.NoopLoggerAdapter{}})
+	assert.EqualError(t, err, `unknown middleware "translate", available: audit, profanity, unfurl`)
+}
+
+func TestHubMiddleware(t *testing.T) {
+	hub := NewHub(test.NewTestLogger(true), HubOpts{})
+	t.Cleanup(func() { _ = hub.Close() })
+
+	// Inbound: drops messages with "spam", outbound: hides the
+	// messages of "muted" from "user".
+	hub.Use(&Plugin{
+		Inbound: func(next Handler) Handler {
+			return func(ctx *EventContext, e Event) error {
+				if m, ok := e.(*EventSendMessage); ok && m.Message == "spam" {
+					return errors.New("no spam please")
+				}
+				return next(ctx, e)
+			}
+		},
+		Outbound: func(next Handler) Handler {
+			return func(ctx *EventContext, e Event) error {
+				m, ok := e.(*EventNewMessage)
+				if ok && ctx.User.Name == "user" && m.Sender == "muted" {
+					return nil
+				}
+				return next(ctx, e)
+			}
+		},
+	})
+
+	userIn := make(chan Event)
+	userOut := make(chan Event, 10)
+	mutedIn := make(chan Event)
+	mutedOut := make(chan Event, 10)
+	_, err := hub.Connect("user", NewTestConnection(userIn, userOut))
+	require.NoError(t, err)
+	_, err = hub.Connect("muted", NewTestConnection(mutedIn, mutedOut))
+	require.NoError(t, err)
+
+	userIn <- &EventSendMessage{Message: "spam"}
+	assert.Equal(t, "no spam please", nextEvent[*EventNotice](t, userOut).Message)
+
+	mutedIn <- &EventSendMessage{Message: "hi"}
+	assert.Equal(t, "hi", nextEvent[*EventNewMessage](t, mutedOut).Message)
+	userIn <- &EventSendMessage{Message: "hello"}
+	m := nextEvent[*EventNewMessage](t, userOut)
+	assert.Equal(t, "user", m.Sender, "muted message not delivered")
+	assert.Equal(t, "hello", m.Message)
+}
+
+func TestHubMiddlewareOutboundError(t *testing.T) {
+	hub := NewHub(test.NewTestLogger(true), HubOpts{})
+	t.Cleanup(func() { _ = hub.Close() })
+
+	// Outbound: fails notices for "blocked" only.
+	hub.Use(&Plugin{
+		Outbound: func(next Handler) Handler {
+			return func(ctx *EventContext, e Event) error {
+				if _, ok := e.(*EventNotice); ok && ctx.User.Name == "blocked" {
+					return errors.New("blocked")
+				}
+				return next(ctx, e)
+			}
+		},
+	})
+
+	outs := map[string]chan Event{}
+	for _, name := range []string{"user1", "user2", "blocked", "user3", "user4"} {
+		outs[name] = make(chan Event, 10)
+		_, err := hub.Connect(name, NewTestConnection(make(chan Event), outs[name]))
+		require.NoError(t, err)
+	}
+
+	hub.Broadcast(&EventNotice{Message: "maintenance"})
+	for name, out := range outs {
+		if name == "blocked" {
+			continue
+		}
+		assert.Equal(t, "maintenance", nextEvent[*EventNotice](t, out).Message, name)
+	}
+}
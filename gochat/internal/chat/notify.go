@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+)
+
+// Notifier notifies the end-user when they are mentioned.
+type Notifier struct {
+	logger log.Logger
+	// Bell rings the terminal bell by writing BEL to Out.
+	Bell bool
+	// Cmd is a shell command that is run on every mention. The sender
+	// and message are passed in GOCHAT_SENDER and GOCHAT_MESSAGE.
+	Cmd string
+	// Out is where the bell is written to.
+	Out io.Writer
+}
+
+// Notify notifies about e when it mentions the user, otherwise it's a noop.
+// Does not wait for the notify command to finish.
+func (n *Notifier) Notify(e *EventNewMessage) error {
+	if !e.Mentioned {
+		return nil
+	}
+	if n.Bell {
+		if _, err := n.Out.Write([]byte("\a")); err != nil {
+			return err
+		}
+	}
+	if n.Cmd != "" {
+		cmd := exec.Command("sh", "-c", n.Cmd)
+		cmd.Env = append(
+			os.Environ(),
+			"GOCHAT_SENDER="+e.Sender,
+			"GOCHAT_MESSAGE="+e.Message,
+		)
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		go func() {
+			if err := cmd.Wait(); err != nil {
+				n.logger.Warnw("notify command failed", log.Error(err))
+			}
+		}()
+	}
+	return nil
+}
+
+// NewNotifier creates a Notifier writing the bell to stdout.
+func NewNotifier(bell bool, cmd string, logger log.Logger) *Notifier {
+	return &Notifier{
+		logger: logger,
+		Bell:   bell,
+		Cmd:    cmd,
+		Out:    os.Stdout,
+	}
+}
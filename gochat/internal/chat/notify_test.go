@@ -0,0 +1,22 @@
+package chat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifierBell(t *testing.T) {
+	out := bytes.Buffer{}
+	n := NewNotifier(true, "", test.NewTestLogger(true))
+	n.Out = &out
+
+	require.NoError(t, n.Notify(&EventNewMessage{Message: "hi"}))
+	assert.Equal(t, "", out.String())
+
+	require.NoError(t, n.Notify(&EventNewMessage{Message: "hi @u", Mentioned: true}))
+	assert.Equal(t, "\a", out.String())
+}
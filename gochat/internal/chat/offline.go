@@ -0,0 +1,226 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultOfflineRetention is how long undelivered events are kept.
+const DefaultOfflineRetention = 7 * 24 * time.Hour
+
+// offlinePurgeInterval is the interval of purging expired events.
+const offlinePurgeInterval = time.Hour
+
+// OfflineStore persists events for authenticated users while they are
+// offline, by identity (see IdentityFromAuthorization).
+type OfflineStore interface {
+	// SetName records the last display name of the identity, so
+	// offline users can be found by name. Other identities lose the
+	// name (see SameUsername).
+	SetName(identity string, name string) error
+	// IdentityByName returns the identity of the display name (see
+	// SameUsername), empty when unknown.
+	IdentityByName(name string) (string, error)
+	// Add adds the event to the backlog of the identity.
+	Add(identity string, t time.Time, e Event) error
+	// Take returns and removes the backlog of the identity, in order.
+	Take(identity string) ([]RecordedEvent, error)
+	// Purge removes events from before the time.
+	Purge(before time.Time) error
+}
+
+// DefaultOfflineDir returns the directory of the FileOfflineStore in
+// the user config dir ($XDG_CONFIG_HOME/gochat/offline on Linux).
+func DefaultOfflineDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gochat", "offline"), nil
+}
+
+// identityRe matches identities, which are used as file names.
+var identityRe = regexp.MustCompile(`^[0-9a-f]+$`)
+
+// offlineNamesFile is the file with the names of the identities.
+const offlineNamesFile = "names.json"
+
+// FileOfflineStore is an OfflineStore with a JSON lines file (see
+// RecordingConnection) per identity in a directory.
+type FileOfflineStore struct {
+	dir   string
+	mu    sync.Mutex
+	names map[string]string
+}
+
+// NewFileOfflineStore returns a store in dir, creating it when needed.
+func NewFileOfflineStore(dir string) (*FileOfflineStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &FileOfflineStore{dir: dir, names: map[string]string{}}
+	p, err := os.ReadFile(filepath.Join(dir, offlineNamesFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(p) > 0 {
+		if err := json.Unmarshal(p, &s.names); err != nil {
+			return nil, fmt.Errorf("%s: %w", offlineNamesFile, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *FileOfflineStore) SetName(identity string, name string) error {
+	if !identityRe.MatchString(identity) {
+		return fmt.Errorf(`invalid identity "%s"`, identity)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.names[identity] == name {
+		return nil
+	}
+	for other, n := range s.names {
+		if SameUsername(n, name) {
+			delete(s.names, other)
+		}
+	}
+	s.names[identity] = name
+	p, err := json.Marshal(s.names)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, offlineNamesFile), p)
+}
+
+func (s *FileOfflineStore) IdentityByName(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for identity, n := range s.names {
+		if SameUsername(n, name) {
+			return identity, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *FileOfflineStore) Add(identity string, t time.Time, e Event) error {
+	if !identityRe.MatchString(identity) {
+		return fmt.Errorf(`invalid identity "%s"`, identity)
+	}
+	line, err := encodeRecordLine(t, e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(
+		s.path(identity),
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		0o600,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FileOfflineStore) Take(identity string) ([]RecordedEvent, error) {
+	if !identityRe.MatchString(identity) {
+		return nil, fmt.Errorf(`invalid identity "%s"`, identity)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events, err := s.read(s.path(identity))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(s.path(identity)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *FileOfflineStore) Purge(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		events, err := s.read(path)
+		if err != nil {
+			return err
+		}
+		keep := []RecordedEvent{}
+		for _, re := range events {
+			if !re.Time.Before(before) {
+				keep = append(keep, re)
+			}
+		}
+		if len(keep) == len(events) {
+			continue
+		}
+		if len(keep) == 0 {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		var buf bytes.Buffer
+		for _, re := range keep {
+			line, err := encodeRecordLine(re.Time, re.Event)
+			if err != nil {
+				return err
+			}
+			buf.Write(line)
+		}
+		if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileOfflineStore) path(identity string) string {
+	return filepath.Join(s.dir, identity+".jsonl")
+}
+
+// read reads the events of the file, none when it does not exist.
+func (s *FileOfflineStore) read(path string) ([]RecordedEvent, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []RecordedEvent{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	events, err := ReadRecording(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return events, nil
+}
+
+// writeFileAtomic writes the file by renaming a temporary file, so
+// readers never see partial files.
+func writeFileAtomic(path string, p []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, p, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,175 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileOfflineStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileOfflineStore(dir)
+	require.NoError(t, err)
+	identity := IdentityFromAuthorization("Bearer alice")
+
+	require.NoError(t, s.SetName(identity, "alice"))
+	got, err := s.IdentityByName("Alice")
+	require.NoError(t, err)
+	assert.Equal(t, identity, got)
+	got, err = s.IdentityByName("bob")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	// The name moves to the identity that took it last.
+	other := IdentityFromAuthorization("Bearer other")
+	require.NoError(t, s.SetName(other, "ALICE"))
+	got, err = s.IdentityByName("alice")
+	require.NoError(t, err)
+	assert.Equal(t, other, got)
+	require.NoError(t, s.SetName(identity, "alice"))
+
+	t0 := time.UnixMilli(0).UTC()
+	for i, message := range []string{"one", "two", "three"} {
+		require.NoError(t, s.Add(identity, t0.Add(time.Duration(i)*time.Hour), &EventNewMessage{
+			EventMeta: EventMeta{Time: t0.Add(time.Duration(i) * time.Hour)},
+			Sender:    "bob",
+			Message:   message,
+			Mentioned: true,
+		}))
+	}
+	assert.Error(t, s.Add("../etc", t0, &EventNotice{}))
+
+	require.NoError(t, s.Purge(t0.Add(time.Hour)))
+
+	// Reopened, as after a restart.
+	s, err = NewFileOfflineStore(dir)
+	require.NoError(t, err)
+	got, err = s.IdentityByName("alice")
+	require.NoError(t, err)
+	assert.Equal(t, identity, got)
+
+	events, err := s.Take(identity)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "two", events[0].Event.(*EventNewMessage).Message)
+	assert.Equal(t, "three", events[1].Event.(*EventNewMessage).Message)
+	assert.Equal(t, t0.Add(2*time.Hour), events[1].Time)
+
+	events, err = s.Take(identity)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestHubOfflineDelivery(t *testing.T) {
+	store, err := NewFileOfflineStore(t.TempDir())
+	require.NoError(t, err)
+	hub := NewHub(test.NewTestLogger(true), HubOpts{Offline: store})
+	t.Cleanup(func() { _ = hub.Close() })
+	identity := IdentityFromAuthorization("Bearer alice")
+
+	aliceIn := make(chan Event)
+	aliceOut := make(chan Event, 10)
+	aliceId, err := hub.ConnectIdentity(identity, "alice", NewTestConnection(aliceIn, aliceOut))
+	require.NoError(t, err)
+	nextEvent[*EventConnected](t, aliceOut)
+	require.NoError(t, hub.Disconnect(aliceId))
+
+	bobIn := make(chan Event)
+	bobOut := make(chan Event, 10)
+	_, err = hub.Connect("bob", NewTestConnection(bobIn, bobOut))
+	require.NoError(t, err)
+	bobIn <- NewEventHello(clock.Real)
+	nextEvent[*EventHello](t, bobOut)
+	bobIn <- &EventSendMessage{Message: "hi @alice"}
+	bobIn <- &EventSendMessage{Message: "no mention"}
+	bobIn <- &EventSendMessage{Message: "@alice still there?"}
+	bobMessage := nextEvent[*EventNewMessage](t, bobOut)
+	assert.False(t, bobMessage.Mentioned)
+	nextEvent[*EventNewMessage](t, bobOut)
+	nextEvent[*EventNewMessage](t, bobOut)
+
+	aliceIn = make(chan Event)
+	aliceOut = make(chan Event, 10)
+	_, err = hub.ConnectIdentity(identity, "alice", NewTestConnection(aliceIn, aliceOut))
+	require.NoError(t, err)
+	aliceIn <- NewEventHello(clock.Real)
+	nextEvent[*EventHello](t, aliceOut)
+
+	backlog := nextEvent[*EventOfflineBacklog](t, aliceOut)
+	assert.Equal(t, 2, backlog.Count)
+	for _, message := range []string{"hi @alice", "@alice still there?"} {
+		e, err := test.ChTimeout(t, aliceOut)
+		require.NoError(t, err)
+		m, ok := e.(*EventNewMessage)
+		require.True(t, ok, "got %T", e)
+		assert.Equal(t, "bob", m.Sender)
+		assert.Equal(t, message, m.Message)
+		assert.True(t, m.Mentioned)
+	}
+
+	// Delivered once.
+	events, err := store.Take(identity)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestHubOfflineMentionCase(t *testing.T) {
+	store, err := NewFileOfflineStore(t.TempDir())
+	require.NoError(t, err)
+	hub := NewHub(test.NewTestLogger(true), HubOpts{Offline: store})
+	t.Cleanup(func() { _ = hub.Close() })
+	identity := IdentityFromAuthorization("Bearer alice")
+
+	aliceOut := make(chan Event, 10)
+	_, err = hub.ConnectIdentity(identity, "alice", NewTestConnection(make(chan Event), aliceOut))
+	require.NoError(t, err)
+	nextEvent[*EventConnected](t, aliceOut)
+	bobIn := make(chan Event)
+	_, err = hub.Connect("bob", NewTestConnection(bobIn, make(chan Event, 10)))
+	require.NoError(t, err)
+
+	// Alice is online for @ALICE, so nothing is kept for later.
+	bobIn <- &EventSendMessage{Message: "hi @ALICE"}
+	assert.True(t, nextEvent[*EventNewMessage](t, aliceOut).Mentioned)
+	events, err := store.Take(identity)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestHubOfflineRetention(t *testing.T) {
+	fake := clock.NewFake(time.UnixMilli(0))
+	store, err := NewFileOfflineStore(t.TempDir())
+	require.NoError(t, err)
+	identity := IdentityFromAuthorization("Bearer alice")
+	require.NoError(t, store.SetName(identity, "alice"))
+	require.NoError(t, store.Add(identity, fake.Now(), &EventNewMessage{
+		EventMeta: EventMeta{Time: fake.Now()},
+		Sender:    "bob",
+		Message:   "hi @alice",
+		Mentioned: true,
+	}))
+
+	hub := NewHub(test.NewTestLogger(true), HubOpts{
+		Clock:            fake,
+		Offline:          store,
+		OfflineRetention: time.Hour,
+	})
+	t.Cleanup(func() { _ = hub.Close() })
+	fake.BlockUntil(1) // purge
+	fake.Advance(time.Hour + time.Second)
+
+	aliceIn := make(chan Event)
+	aliceOut := make(chan Event, 10)
+	_, err = hub.ConnectIdentity(identity, "alice", NewTestConnection(aliceIn, aliceOut))
+	require.NoError(t, err)
+	aliceIn <- NewEventHello(fake)
+	nextEvent[*EventHello](t, aliceOut)
+	aliceIn <- &EventSendMessage{Message: "hello"}
+	e, err := test.ChTimeout(t, aliceOut)
+	require.NoError(t, err)
+	assert.IsType(t, &EventNewMessage{}, e, "no backlog")
+}
@@ -0,0 +1,135 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+)
+
+// ProtocolVersion is the version of the event protocol implemented by
+// this package. Version 1 is the protocol from before the handshake,
+// peers that don't send EventHello are assumed to speak version 1.
+const ProtocolVersion = 2
+
+// Features that can be negotiated with EventHello.
+const (
+	// FeatureMentions marks EventNewMessage.Mentioned as supported.
+	FeatureMentions = "mentions"
+	// FeaturePreviews marks EventMessageEnrichment as supported.
+	FeaturePreviews = "previews"
+	// FeatureOffline marks EventOfflineBacklog as supported.
+	FeatureOffline = "offline"
+	// FeatureReads marks EventReadState as supported.
+	FeatureReads = "reads"
+	// FeatureDeletions marks EventMessagesDeleted as supported.
+	FeatureDeletions = "deletions"
+)
+
+// SupportedFeatures are the features this package supports.
+var SupportedFeatures = []string{
+	FeatureMentions,
+	FeaturePreviews,
+	FeatureOffline,
+	FeatureReads,
+	FeatureDeletions,
+}
+
+// FeatureEvent is implemented by events that require a negotiated
+// feature. They are not sent to receivers without the feature.
+type FeatureEvent interface {
+	Event
+	// Feature returns the feature the receiver needs.
+	Feature() string
+}
+
+// Protocol is the negotiated protocol of a connection.
+type Protocol struct {
+	Version  int
+	Features map[string]bool
+}
+
+// Supports returns true when e can be sent with the protocol.
+func (p *Protocol) Supports(e Event) bool {
+	if fe, ok := e.(FeatureEvent); ok {
+		return p.Features[fe.Feature()]
+	}
+	return true
+}
+
+// NewProtocolV1 returns the protocol of peers that did not (yet) send
+// EventHello.
+func NewProtocolV1() *Protocol {
+	return &Protocol{Version: 1, Features: map[string]bool{}}
+}
+
+// Negotiate returns the protocol that both this package and the sender
+// of hello support: the lowest version and the common features.
+func Negotiate(hello *EventHello) *Protocol {
+	p := &Protocol{
+		Version:  hello.Version,
+		Features: map[string]bool{},
+	}
+	if p.Version > ProtocolVersion {
+		p.Version = ProtocolVersion
+	}
+	if p.Version < 1 {
+		p.Version = 1
+	}
+	supported := map[string]bool{}
+	for _, f := range SupportedFeatures {
+		supported[f] = true
+	}
+	for _, f := range hello.Features {
+		if supported[f] {
+			p.Features[f] = true
+		}
+	}
+	return p
+}
+
+// EventHello returns the handshake event announcing the protocol.
+func (p *Protocol) EventHello(c clock.Clock) *EventHello {
+	features := []string{}
+	for f := range p.Features {
+		features = append(features, f)
+	}
+	sort.Strings(features)
+	return &EventHello{
+		EventMeta: *NewEventMetaNow(c),
+		Version:   p.Version,
+		Features:  features,
+	}
+}
+
+// NewEventHello returns the handshake event a client sends to
+// announce its protocol version and features.
+func NewEventHello(c clock.Clock) *EventHello {
+	features := make([]string, len(SupportedFeatures))
+	copy(features, SupportedFeatures)
+	return &EventHello{
+		EventMeta: *NewEventMetaNow(c),
+		Version:   ProtocolVersion,
+		Features:  features,
+	}
+}
+
+// ErrUnknownEvent when a transport receives an event it doesn't know,
+// for example from a peer with a newer protocol version. Transports
+// skip these events instead of failing.
+type ErrUnknownEvent struct {
+	// Name is the wire name of the event, if the transport has one.
+	Name    string
+	Version int
+}
+
+func (e *ErrUnknownEvent) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("unknown event (protocol version %d)", e.Version)
+	}
+	return fmt.Sprintf(
+		`unknown event name "%s" (protocol version %d)`,
+		e.Name,
+		e.Version,
+	)
+}
@@ -0,0 +1,39 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type testFeatureEvent struct {
+	EventMeta
+}
+
+func (e *testFeatureEvent) Feature() string {
+	return "test"
+}
+
+func TestNegotiate(t *testing.T) {
+	p := Negotiate(&EventHello{
+		Version:  ProtocolVersion + 1,
+		Features: []string{FeatureMentions, "fromTheFuture"},
+	})
+	assert.Equal(t, ProtocolVersion, p.Version)
+	assert.Equal(t, map[string]bool{FeatureMentions: true}, p.Features)
+	assert.Equal(t, []string{FeatureMentions}, p.EventHello(clock.Real).Features)
+
+	p = Negotiate(&EventHello{})
+	assert.Equal(t, 1, p.Version)
+	assert.Equal(t, []string{}, p.EventHello(clock.Real).Features)
+}
+
+func TestProtocolSupports(t *testing.T) {
+	p := NewProtocolV1()
+	assert.True(t, p.Supports(&EventNewMessage{}))
+	assert.False(t, p.Supports(&testFeatureEvent{}))
+
+	p.Features["test"] = true
+	assert.True(t, p.Supports(&testFeatureEvent{}))
+}
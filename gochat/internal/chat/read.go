@@ -0,0 +1,89 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// messageSeq returns the sequence number of a message ID assigned by
+// the hub ("m1", "m2", ...).
+func messageSeq(id string) (int64, bool) {
+	if !strings.HasPrefix(id, "m") {
+		return 0, false
+	}
+	seq, err := strconv.ParseInt(id[1:], 10, 64)
+	if err != nil || seq < 1 {
+		return 0, false
+	}
+	return seq, true
+}
+
+// MessageBefore returns true when message ID a was assigned before b.
+// False for IDs not assigned by the hub.
+func MessageBefore(a string, b string) bool {
+	seqA, okA := messageSeq(a)
+	seqB, okB := messageSeq(b)
+	return okA && okB && seqA < seqB
+}
+
+// lastRead returns the ID of the last message the user read, empty when
+// unknown.
+func (h *Hub) lastRead(userID string) string {
+	h.readsMu.Lock()
+	defer h.readsMu.Unlock()
+	return h.reads[userID]
+}
+
+// markRead moves the read marker of the user forward to the message and
+// broadcasts the read state when it changed.
+func (h *Hub) markRead(user *hubUser, messageID string) error {
+	seq, ok := messageSeq(messageID)
+	if !ok || seq > atomic.LoadInt64(&h.msgInc) {
+		return fmt.Errorf(`invalid message id "%s"`, messageID)
+	}
+	userID := user.getProfile().UserID
+	h.readsMu.Lock()
+	if last := h.reads[userID]; last != "" && !MessageBefore(last, messageID) {
+		h.readsMu.Unlock()
+		return nil
+	}
+	h.reads[userID] = messageID
+	h.readsMu.Unlock()
+
+	h.Broadcast(h.readState())
+	return nil
+}
+
+// readState returns the read markers of the online users by name.
+func (h *Hub) readState() *EventReadState {
+	h.usersMu.RLock()
+	profiles := map[string]UserProfile{}
+	for _, session := range h.sessions.Values() {
+		p := session.user.getProfile()
+		profiles[p.UserID] = p
+	}
+	h.usersMu.RUnlock()
+
+	h.readsMu.Lock()
+	markers := []ReadMarker{}
+	for userID, p := range profiles {
+		if id := h.reads[userID]; id != "" {
+			markers = append(markers, ReadMarker{
+				UserID:    userID,
+				Name:      p.Name,
+				MessageID: id,
+			})
+		}
+	}
+	h.readsMu.Unlock()
+	sort.Slice(markers, func(i, j int) bool {
+		return markers[i].Name < markers[j].Name
+	})
+	return &EventReadState{
+		EventMeta: *NewEventMetaNow(h.clock),
+		Markers:   markers,
+	}
+}
@@ -0,0 +1,67 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageBefore(t *testing.T) {
+	assert.True(t, MessageBefore("m1", "m2"))
+	assert.True(t, MessageBefore("m9", "m10"))
+	assert.False(t, MessageBefore("m2", "m2"))
+	assert.False(t, MessageBefore("m3", "m2"))
+	assert.False(t, MessageBefore("", "m2"))
+	assert.False(t, MessageBefore("x1", "m2"))
+	assert.False(t, MessageBefore("m0", "m2"))
+}
+
+func TestHubReadMarkers(t *testing.T) {
+	hub := NewHub(test.NewTestLogger(true), HubOpts{})
+	t.Cleanup(func() { _ = hub.Close() })
+	identity := IdentityFromAuthorization("Bearer alice")
+
+	aliceIn := make(chan Event)
+	aliceOut := make(chan Event, 10)
+	aliceId, err := hub.ConnectIdentity(identity, "alice", NewTestConnection(aliceIn, aliceOut))
+	require.NoError(t, err)
+	connected := nextEvent[*EventConnected](t, aliceOut)
+	assert.Empty(t, connected.LastRead)
+	bobIn := make(chan Event)
+	bobOut := make(chan Event, 10)
+	_, err = hub.Connect("bob", NewTestConnection(bobIn, bobOut))
+	require.NoError(t, err)
+	aliceIn <- NewEventHello(clock.Real)
+	nextEvent[*EventHello](t, aliceOut)
+	bobIn <- NewEventHello(clock.Real)
+	nextEvent[*EventHello](t, bobOut)
+
+	bobIn <- &EventSendMessage{Message: "one"}
+	bobIn <- &EventSendMessage{Message: "two"}
+	nextEvent[*EventNewMessage](t, aliceOut)
+	m := nextEvent[*EventNewMessage](t, aliceOut)
+
+	aliceIn <- &EventMarkRead{MessageID: m.ID}
+	for _, ch := range []chan Event{aliceOut, bobOut} {
+		state := nextEvent[*EventReadState](t, ch)
+		require.Len(t, state.Markers, 1)
+		assert.Equal(t, "alice", state.Markers[0].Name)
+		assert.Equal(t, m.ID, state.Markers[0].MessageID)
+	}
+
+	// Markers only move forward, and only to known messages.
+	aliceIn <- &EventMarkRead{MessageID: "m1"}
+	aliceIn <- &EventMarkRead{MessageID: "m1000"}
+	notice := nextEvent[*EventNotice](t, aliceOut)
+	assert.Contains(t, notice.Message, "m1000")
+
+	require.NoError(t, hub.Disconnect(aliceId))
+	aliceOut = make(chan Event, 10)
+	_, err = hub.ConnectIdentity(identity, "alice", NewTestConnection(aliceIn, aliceOut))
+	require.NoError(t, err)
+	connected = nextEvent[*EventConnected](t, aliceOut)
+	assert.Equal(t, m.ID, connected.LastRead)
+}
@@ -0,0 +1,155 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+)
+
+// RecordedEvent is an event of a recording.
+type RecordedEvent struct {
+	// Time is when the event was read from the connection.
+	Time  time.Time
+	Event Event
+}
+
+// recordLine is a line in a recording file.
+type recordLine struct {
+	Time time.Time       `json:"time"`
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data"`
+}
+
+// RecordingConnection is a Connection that records every event read
+// as timestamped JSON lines.
+type RecordingConnection struct {
+	Connection
+	// Clock for the recorded times, clock.Real by default.
+	Clock clock.Clock
+	w     io.Writer
+	mu    sync.Mutex
+}
+
+// ReadEvent reads the next event and records it.
+// Returns error when recording fails.
+func (c *RecordingConnection) ReadEvent() (Event, error) {
+	e, err := c.Connection.ReadEvent()
+	if err != nil {
+		return e, err
+	}
+	if err := c.record(e); err != nil {
+		return nil, fmt.Errorf("could not record event: %w", err)
+	}
+	return e, nil
+}
+
+func (c *RecordingConnection) record(e Event) error {
+	line, err := encodeRecordLine(c.Clock.Now(), e)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.w.Write(line)
+	return err
+}
+
+// encodeRecordLine returns the recording line for the event at time t,
+// with trailing newline.
+func encodeRecordLine(t time.Time, e Event) ([]byte, error) {
+	et, ok := Events.ByEvent(e)
+	if !ok {
+		return nil, fmt.Errorf("unknown event type <%s>", reflect.TypeOf(e))
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(&recordLine{
+		Time: t,
+		Name: et.Name,
+		Data: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// decodeRecordLine returns the event of a recording line.
+func decodeRecordLine(p []byte) (RecordedEvent, error) {
+	var line recordLine
+	if err := json.Unmarshal(p, &line); err != nil {
+		return RecordedEvent{}, err
+	}
+	t, ok := Events.ByName(line.Name)
+	if !ok {
+		return RecordedEvent{}, &ErrUnknownEvent{Name: line.Name}
+	}
+	e := t.New()
+	if err := json.Unmarshal(line.Data, e); err != nil {
+		return RecordedEvent{}, err
+	}
+	return RecordedEvent{Time: line.Time, Event: e}, nil
+}
+
+// NewRecordingConnection returns conn recording events to w.
+func NewRecordingConnection(conn Connection, w io.Writer) *RecordingConnection {
+	return &RecordingConnection{Connection: conn, Clock: clock.Real, w: w}
+}
+
+// ReadRecording reads all events of a recording.
+func ReadRecording(r io.Reader) ([]RecordedEvent, error) {
+	events := []RecordedEvent{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNr := 1; scanner.Scan(); lineNr++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		re, err := decodeRecordLine(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNr, err)
+		}
+		events = append(events, re)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Replay sends the recorded events to out. When speed is positive the
+// events are paced by their recorded time on clock c, speed 1 being
+// real-time. Returns early when stop closes.
+func Replay(
+	c clock.Clock,
+	events []RecordedEvent,
+	out chan<- Event,
+	speed float64,
+	stop <-chan struct{},
+) {
+	for i, re := range events {
+		if speed > 0 && i > 0 {
+			wait := time.Duration(
+				float64(re.Time.Sub(events[i-1].Time)) / speed,
+			)
+			select {
+			case <-stop:
+				return
+			case <-c.After(wait):
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case out <- re.Event:
+		}
+	}
+}
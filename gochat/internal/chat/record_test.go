@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingConnection(t *testing.T) {
+	fake := clock.NewFake(time.UnixMilli(0))
+
+	in := make(chan Event, 2)
+	var buf bytes.Buffer
+	conn := NewRecordingConnection(NewTestConnection(in, nil), &buf)
+	conn.Clock = fake
+
+	meta := EventMeta{Time: time.Unix(1, 0).UTC()}
+	events := []Event{
+		&EventConnected{EventMeta: meta, Users: []string{"user1"}},
+		&EventNewMessage{EventMeta: meta, Sender: "user1", Message: "hi"},
+	}
+	for _, e := range events {
+		in <- e
+		read, err := conn.ReadEvent()
+		require.NoError(t, err)
+		assert.Equal(t, e, read)
+		fake.Advance(time.Second)
+	}
+
+	recording, err := ReadRecording(&buf)
+	require.NoError(t, err)
+	require.Len(t, recording, 2)
+	for i, re := range recording {
+		assert.Equal(t, events[i], re.Event)
+	}
+	assert.Equal(t, time.Second, recording[1].Time.Sub(recording[0].Time))
+}
+
+func TestReadRecordingInvalid(t *testing.T) {
+	_, err := ReadRecording(strings.NewReader("{\"name\":\"fromTheFuture\",\"data\":{}}\n"))
+	var unknownErr *ErrUnknownEvent
+	assert.ErrorAs(t, err, &unknownErr)
+
+	_, err = ReadRecording(strings.NewReader("\n{"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Unix(0, 0)
+	recording := []RecordedEvent{
+		{Time: start, Event: &EventUserEnter{Name: "user1"}},
+		{Time: start.Add(100 * time.Millisecond), Event: &EventUserEnter{Name: "user2"}},
+		{Time: start.Add(300 * time.Millisecond), Event: &EventUserEnter{Name: "user3"}},
+	}
+
+	c := clock.NewFake(start)
+	out := make(chan Event, len(recording))
+	done := make(chan struct{})
+	go func() {
+		Replay(c, recording, out, 2, nil)
+		close(done)
+	}()
+	assert.Equal(t, recording[0].Event, <-out)
+
+	// At speed 2, 100ms and 200ms apart become 50ms and 100ms.
+	c.BlockUntil(1)
+	c.Advance(49 * time.Millisecond)
+	assert.Len(t, out, 0)
+	c.Advance(time.Millisecond)
+	assert.Equal(t, recording[1].Event, <-out)
+	c.BlockUntil(1)
+	c.Advance(99 * time.Millisecond)
+	assert.Len(t, out, 0)
+	c.Advance(time.Millisecond)
+	assert.Equal(t, recording[2].Event, <-out)
+	<-done
+
+	stop := make(chan struct{})
+	close(stop)
+	Replay(c, recording, make(chan Event), 0, stop)
+}
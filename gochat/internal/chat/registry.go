@@ -0,0 +1,616 @@
+package chat
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// EventType declares how an event type is sent over the wire.
+// All transports map events with the EventType in Events.
+type EventType struct {
+	// Name is the wire name of the event.
+	Name string
+	// New returns a new empty event.
+	New func() Event
+	// Proto is an empty protobuf envelope event (pb.EventEnvelope_*)
+	// of the event.
+	Proto any
+	// ToProto sets e as the protobuf envelope event.
+	ToProto func(e Event, envelope *pb.EventEnvelope)
+	// FromProto returns the event of the protobuf envelope.
+	FromProto func(envelope *pb.EventEnvelope) Event
+}
+
+// EventRegistry looks up event types by wire name, event and protobuf
+// envelope event.
+type EventRegistry struct {
+	types   []*EventType
+	byName  map[string]*EventType
+	byEvent map[reflect.Type]*EventType
+	byProto map[reflect.Type]*EventType
+}
+
+// NewEventRegistry returns a registry for the event types.
+// Panics on duplicate names or types.
+func NewEventRegistry(types ...EventType) *EventRegistry {
+	r := &EventRegistry{
+		byName:  map[string]*EventType{},
+		byEvent: map[reflect.Type]*EventType{},
+		byProto: map[reflect.Type]*EventType{},
+	}
+	for i := range types {
+		t := &types[i]
+		eventType := reflect.TypeOf(t.New())
+		protoType := reflect.TypeOf(t.Proto)
+		if r.byName[t.Name] != nil ||
+			r.byEvent[eventType] != nil ||
+			r.byProto[protoType] != nil {
+			panic(fmt.Sprintf(`duplicate event type "%s"`, t.Name))
+		}
+		r.types = append(r.types, t)
+		r.byName[t.Name] = t
+		r.byEvent[eventType] = t
+		r.byProto[protoType] = t
+	}
+	return r
+}
+
+// Types returns all event types.
+func (r *EventRegistry) Types() []*EventType {
+	return r.types
+}
+
+// ByName returns the event type by wire name, false when unknown.
+func (r *EventRegistry) ByName(name string) (*EventType, bool) {
+	t, ok := r.byName[name]
+	return t, ok
+}
+
+// ByEvent returns the event type of e, false when unknown.
+func (r *EventRegistry) ByEvent(e Event) (*EventType, bool) {
+	t, ok := r.byEvent[reflect.TypeOf(e)]
+	return t, ok
+}
+
+// ToProto returns the protobuf envelope for e.
+// Returns error for unknown event types.
+func (r *EventRegistry) ToProto(e Event) (*pb.EventEnvelope, error) {
+	t, ok := r.ByEvent(e)
+	if !ok {
+		return nil, fmt.Errorf("unknown event type <%s>", reflect.TypeOf(e))
+	}
+	envelope := &pb.EventEnvelope{Version: ProtocolVersion}
+	t.ToProto(e, envelope)
+	envelope.Trace = EventTrace(e)
+	return envelope, nil
+}
+
+// FromProto returns the event for the protobuf envelope.
+// Returns ErrUnknownEvent when the envelope has no known event, for
+// example when sent by a newer peer.
+func (r *EventRegistry) FromProto(envelope *pb.EventEnvelope) (Event, error) {
+	t, ok := r.byProto[reflect.TypeOf(envelope.Event)]
+	if !ok {
+		return nil, &ErrUnknownEvent{Version: int(envelope.Version)}
+	}
+	e := t.FromProto(envelope)
+	SetEventTrace(e, envelope.Trace)
+	return e, nil
+}
+
+// Events is the registry with all events.
+var Events *EventRegistry
+
+// Events is set in init, as history events refer to Events.
+func init() {
+	Events = NewEventRegistry(
+		EventType{
+			Name:  "hello",
+			New:   func() Event { return &EventHello{} },
+			Proto: &pb.EventEnvelope_Hello{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventHello)
+				envelope.Event = &pb.EventEnvelope_Hello{Hello: &pb.Hello{
+					Time:     timestamppb.New(t.Time),
+					Version:  int32(t.Version),
+					Features: t.Features,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetHello()
+				return &EventHello{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Version:   int(t.Version),
+					Features:  t.Features,
+				}
+			},
+		},
+		EventType{
+			Name:  "connected",
+			New:   func() Event { return &EventConnected{} },
+			Proto: &pb.EventEnvelope_Connected{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventConnected)
+				envelope.Event = &pb.EventEnvelope_Connected{Connected: &pb.Connected{
+					Time:     timestamppb.New(t.Time),
+					Users:    t.Users,
+					LastRead: t.LastRead,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetConnected()
+				return &EventConnected{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Users:     t.Users,
+					LastRead:  t.LastRead,
+				}
+			},
+		},
+		EventType{
+			Name:  "userListUpdate",
+			New:   func() Event { return &EventUserListUpdate{} },
+			Proto: &pb.EventEnvelope_UserListUpdate{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventUserListUpdate)
+				envelope.Event = &pb.EventEnvelope_UserListUpdate{UserListUpdate: &pb.UserListUpdate{
+					Time:  timestamppb.New(t.Time),
+					Users: t.Users,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetUserListUpdate()
+				return &EventUserListUpdate{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Users:     t.Users,
+				}
+			},
+		},
+		EventType{
+			Name:  "userEnter",
+			New:   func() Event { return &EventUserEnter{} },
+			Proto: &pb.EventEnvelope_UserEnter{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventUserEnter)
+				envelope.Event = &pb.EventEnvelope_UserEnter{UserEnter: &pb.UserEnter{
+					Time: timestamppb.New(t.Time),
+					Name: t.Name,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetUserEnter()
+				return &EventUserEnter{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Name:      t.Name,
+				}
+			},
+		},
+		EventType{
+			Name:  "userLeave",
+			New:   func() Event { return &EventUserLeave{} },
+			Proto: &pb.EventEnvelope_UserLeave{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventUserLeave)
+				envelope.Event = &pb.EventEnvelope_UserLeave{UserLeave: &pb.UserLeave{
+					Time: timestamppb.New(t.Time),
+					Name: t.Name,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetUserLeave()
+				return &EventUserLeave{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Name:      t.Name,
+				}
+			},
+		},
+		EventType{
+			Name:  "sendMessage",
+			New:   func() Event { return &EventSendMessage{} },
+			Proto: &pb.EventEnvelope_SendMessage{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventSendMessage)
+				envelope.Event = &pb.EventEnvelope_SendMessage{SendMessage: &pb.SendMessage{
+					Time:    timestamppb.New(t.Time),
+					Message: t.Message,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetSendMessage()
+				return &EventSendMessage{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Message:   t.Message,
+				}
+			},
+		},
+		EventType{
+			Name:  "newMessage",
+			New:   func() Event { return &EventNewMessage{} },
+			Proto: &pb.EventEnvelope_NewMessage{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventNewMessage)
+				envelope.Event = &pb.EventEnvelope_NewMessage{NewMessage: &pb.NewMessage{
+					Time:      timestamppb.New(t.Time),
+					Id:        t.ID,
+					Sender:    t.Sender,
+					Message:   t.Message,
+					Mentioned: t.Mentioned,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetNewMessage()
+				return &EventNewMessage{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					ID:        t.Id,
+					Sender:    t.Sender,
+					Message:   t.Message,
+					Mentioned: t.Mentioned,
+				}
+			},
+		},
+		EventType{
+			Name:  "messageEnrichment",
+			New:   func() Event { return &EventMessageEnrichment{} },
+			Proto: &pb.EventEnvelope_MessageEnrichment{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventMessageEnrichment)
+				envelope.Event = &pb.EventEnvelope_MessageEnrichment{
+					MessageEnrichment: &pb.MessageEnrichment{
+						Time:        timestamppb.New(t.Time),
+						MessageId:   t.MessageID,
+						Url:         t.URL,
+						Title:       t.Title,
+						Description: t.Description,
+					},
+				}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetMessageEnrichment()
+				return &EventMessageEnrichment{
+					EventMeta:   EventMeta{Time: t.Time.AsTime()},
+					MessageID:   t.MessageId,
+					URL:         t.Url,
+					Title:       t.Title,
+					Description: t.Description,
+				}
+			},
+		},
+		EventType{
+			Name:  "changeNick",
+			New:   func() Event { return &EventChangeNick{} },
+			Proto: &pb.EventEnvelope_ChangeNick{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventChangeNick)
+				envelope.Event = &pb.EventEnvelope_ChangeNick{ChangeNick: &pb.ChangeNick{
+					Time: timestamppb.New(t.Time),
+					Name: t.Name,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetChangeNick()
+				return &EventChangeNick{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Name:      t.Name,
+				}
+			},
+		},
+		EventType{
+			Name:  "nickChange",
+			New:   func() Event { return &EventNickChange{} },
+			Proto: &pb.EventEnvelope_NickChange{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventNickChange)
+				envelope.Event = &pb.EventEnvelope_NickChange{NickChange: &pb.NickChange{
+					Time:    timestamppb.New(t.Time),
+					UserId:  t.UserID,
+					OldName: t.OldName,
+					NewName: t.NewName,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetNickChange()
+				return &EventNickChange{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					UserID:    t.UserId,
+					OldName:   t.OldName,
+					NewName:   t.NewName,
+				}
+			},
+		},
+		EventType{
+			Name:  "setProfile",
+			New:   func() Event { return &EventSetProfile{} },
+			Proto: &pb.EventEnvelope_SetProfile{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventSetProfile)
+				envelope.Event = &pb.EventEnvelope_SetProfile{SetProfile: &pb.SetProfile{
+					Time:   timestamppb.New(t.Time),
+					Status: t.Status,
+					Color:  t.Color,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetSetProfile()
+				return &EventSetProfile{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Status:    t.Status,
+					Color:     t.Color,
+				}
+			},
+		},
+		EventType{
+			Name:  "whois",
+			New:   func() Event { return &EventWhois{} },
+			Proto: &pb.EventEnvelope_Whois{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventWhois)
+				envelope.Event = &pb.EventEnvelope_Whois{Whois: &pb.Whois{
+					Time: timestamppb.New(t.Time),
+					Name: t.Name,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetWhois()
+				return &EventWhois{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Name:      t.Name,
+				}
+			},
+		},
+		EventType{
+			Name:  "profile",
+			New:   func() Event { return &EventProfile{} },
+			Proto: &pb.EventEnvelope_Profile{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventProfile)
+				envelope.Event = &pb.EventEnvelope_Profile{Profile: &pb.Profile{
+					Time:   timestamppb.New(t.Time),
+					UserId: t.UserID,
+					Name:   t.Name,
+					Status: t.Status,
+					Color:  t.Color,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetProfile()
+				return &EventProfile{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					UserProfile: UserProfile{
+						UserID: t.UserId,
+						Name:   t.Name,
+						Status: t.Status,
+						Color:  t.Color,
+					},
+				}
+			},
+		},
+		EventType{
+			Name:  "notice",
+			New:   func() Event { return &EventNotice{} },
+			Proto: &pb.EventEnvelope_Notice{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventNotice)
+				envelope.Event = &pb.EventEnvelope_Notice{Notice: &pb.Notice{
+					Time:    timestamppb.New(t.Time),
+					Message: t.Message,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetNotice()
+				return &EventNotice{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Message:   t.Message,
+				}
+			},
+		},
+		EventType{
+			Name:  "markRead",
+			New:   func() Event { return &EventMarkRead{} },
+			Proto: &pb.EventEnvelope_MarkRead{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventMarkRead)
+				envelope.Event = &pb.EventEnvelope_MarkRead{MarkRead: &pb.MarkRead{
+					Time:      timestamppb.New(t.Time),
+					MessageId: t.MessageID,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetMarkRead()
+				return &EventMarkRead{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					MessageID: t.MessageId,
+				}
+			},
+		},
+		EventType{
+			Name:  "readState",
+			New:   func() Event { return &EventReadState{} },
+			Proto: &pb.EventEnvelope_ReadState{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventReadState)
+				markers := []*pb.ReadMarker{}
+				for _, m := range t.Markers {
+					markers = append(markers, &pb.ReadMarker{
+						UserId:    m.UserID,
+						Name:      m.Name,
+						MessageId: m.MessageID,
+					})
+				}
+				envelope.Event = &pb.EventEnvelope_ReadState{ReadState: &pb.ReadState{
+					Time:    timestamppb.New(t.Time),
+					Markers: markers,
+				}}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetReadState()
+				markers := []ReadMarker{}
+				for _, m := range t.Markers {
+					markers = append(markers, ReadMarker{
+						UserID:    m.UserId,
+						Name:      m.Name,
+						MessageID: m.MessageId,
+					})
+				}
+				return &EventReadState{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Markers:   markers,
+				}
+			},
+		},
+		EventType{
+			Name:  "messagesDeleted",
+			New:   func() Event { return &EventMessagesDeleted{} },
+			Proto: &pb.EventEnvelope_MessagesDeleted{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventMessagesDeleted)
+				envelope.Event = &pb.EventEnvelope_MessagesDeleted{
+					MessagesDeleted: &pb.MessagesDeleted{
+						Time:       timestamppb.New(t.Time),
+						MessageIds: t.MessageIDs,
+						Reason:     t.Reason,
+					},
+				}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetMessagesDeleted()
+				ids := t.MessageIds
+				if ids == nil {
+					ids = []string{}
+				}
+				return &EventMessagesDeleted{
+					EventMeta:  EventMeta{Time: t.Time.AsTime()},
+					MessageIDs: ids,
+					Reason:     t.Reason,
+				}
+			},
+		},
+		EventType{
+			Name:  "offlineBacklog",
+			New:   func() Event { return &EventOfflineBacklog{} },
+			Proto: &pb.EventEnvelope_OfflineBacklog{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventOfflineBacklog)
+				envelope.Event = &pb.EventEnvelope_OfflineBacklog{
+					OfflineBacklog: &pb.OfflineBacklog{
+						Time:  timestamppb.New(t.Time),
+						Count: int32(t.Count),
+						Since: timestamppb.New(t.Since),
+					},
+				}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				t := envelope.GetOfflineBacklog()
+				return &EventOfflineBacklog{
+					EventMeta: EventMeta{Time: t.Time.AsTime()},
+					Count:     int(t.Count),
+					Since:     t.Since.AsTime(),
+				}
+			},
+		},
+		EventType{
+			Name:  "historyRequest",
+			New:   func() Event { return &EventHistoryRequest{} },
+			Proto: &pb.EventEnvelope_HistoryRequest{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventHistoryRequest)
+				envelope.Event = &pb.EventEnvelope_HistoryRequest{
+					HistoryRequest: HistoryRequestToProto(t),
+				}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				return HistoryRequestFromProto(envelope.GetHistoryRequest())
+			},
+		},
+		EventType{
+			Name:  "history",
+			New:   func() Event { return &EventHistory{} },
+			Proto: &pb.EventEnvelope_History{},
+			ToProto: func(e Event, envelope *pb.EventEnvelope) {
+				t := e.(*EventHistory)
+				envelope.Event = &pb.EventEnvelope_History{
+					History: HistoryToProto(t),
+				}
+			},
+			FromProto: func(envelope *pb.EventEnvelope) Event {
+				return HistoryFromProto(envelope.GetHistory())
+			},
+		},
+	)
+}
+
+// HistoryRequestToProto returns the protobuf message for e,
+// also used by the admin service.
+func HistoryRequestToProto(e *EventHistoryRequest) *pb.HistoryRequest {
+	return &pb.HistoryRequest{
+		Time: timestamppb.New(e.Time),
+		Id:   e.ID,
+		From: protoTimeOrNil(e.From),
+		To:   protoTimeOrNil(e.To),
+	}
+}
+
+// HistoryRequestFromProto returns the event for the protobuf message.
+func HistoryRequestFromProto(p *pb.HistoryRequest) *EventHistoryRequest {
+	return &EventHistoryRequest{
+		EventMeta: EventMeta{Time: p.Time.AsTime()},
+		ID:        p.Id,
+		From:      timeOrZero(p.From),
+		To:        timeOrZero(p.To),
+	}
+}
+
+// HistoryToProto returns the protobuf message for e,
+// also used by the admin service.
+func HistoryToProto(e *EventHistory) *pb.History {
+	p := &pb.History{
+		Time:      timestamppb.New(e.Time),
+		RequestId: e.RequestID,
+		Error:     e.Error,
+	}
+	for _, event := range e.Events {
+		envelope, err := Events.ToProto(event)
+		if err != nil {
+			continue // only known events are in the history
+		}
+		p.Events = append(p.Events, envelope)
+	}
+	return p
+}
+
+// HistoryFromProto returns the event for the protobuf message,
+// skipping unknown events.
+func HistoryFromProto(p *pb.History) *EventHistory {
+	e := &EventHistory{
+		EventMeta: EventMeta{Time: p.Time.AsTime()},
+		RequestID: p.RequestId,
+		Events:    []Event{},
+		Error:     p.Error,
+	}
+	for _, envelope := range p.Events {
+		event, err := Events.FromProto(envelope)
+		if err != nil {
+			continue
+		}
+		e.Events = append(e.Events, event)
+	}
+	return e
+}
+
+// protoTimeOrNil returns the timestamp for t, nil for the zero time.
+func protoTimeOrNil(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// timeOrZero returns the time of the timestamp, the zero time for nil.
+func timeOrZero(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
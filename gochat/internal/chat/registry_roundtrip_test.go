@@ -0,0 +1,37 @@
+package chat_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat/chattest"
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEventsProtoRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, et := range chat.Events.Types() {
+		et := et
+		t.Run(et.Name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				e := chattest.NewRandomEvent(r, et)
+				envelope, err := chat.Events.ToProto(e)
+				require.NoError(t, err)
+				assert.IsType(t, et.Proto, envelope.Event)
+
+				p, err := proto.Marshal(envelope)
+				require.NoError(t, err)
+				decodedEnvelope := &pb.EventEnvelope{}
+				require.NoError(t, proto.Unmarshal(p, decodedEnvelope))
+
+				decoded, err := chat.Events.FromProto(decodedEnvelope)
+				require.NoError(t, err)
+				require.Equal(t, e, decoded)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventTypeNames returns the names of all structs in the package
+// embedding EventMeta.
+func eventTypeNames(t *testing.T) []string {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	require.NoError(t, err)
+	names := []string{}
+	for _, file := range pkgs["chat"].Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			for _, f := range st.Fields.List {
+				if id, ok := f.Type.(*ast.Ident); ok && f.Names == nil && id.Name == "EventMeta" {
+					names = append(names, spec.Name.Name)
+				}
+			}
+			return true
+		})
+	}
+	return names
+}
+
+func TestEventsRegistered(t *testing.T) {
+	registered := map[string]bool{}
+	for _, et := range Events.Types() {
+		registered[reflect.TypeOf(et.New()).Elem().Name()] = true
+	}
+	for _, name := range eventTypeNames(t) {
+		if !strings.HasPrefix(name, "Event") {
+			continue // test and helper types
+		}
+		assert.True(t, registered[name], "event %s not in Events", name)
+	}
+}
+
+func TestEventsFromProtoUnknown(t *testing.T) {
+	_, err := Events.FromProto(&pb.EventEnvelope{Version: 3})
+	var unknownErr *ErrUnknownEvent
+	assert.ErrorAs(t, err, &unknownErr)
+}
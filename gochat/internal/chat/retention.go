@@ -0,0 +1,297 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultRetentionInterval is the interval of purging history by the
+// retention policies.
+const DefaultRetentionInterval = time.Hour
+
+// retentionReason is the reason of EventMessagesDeleted by purges.
+const retentionReason = "retention policy"
+
+// RetentionPolicy limits how long the messages of a room are kept.
+// Zero values are unlimited.
+type RetentionPolicy struct {
+	// Days keeps messages for this many days.
+	Days int `yaml:"days,omitempty" json:"days,omitempty"`
+	// Messages keeps this many of the last messages.
+	Messages int `yaml:"messages,omitempty" json:"messages,omitempty"`
+}
+
+// LegalHold exempts messages from purging.
+type LegalHold struct {
+	// Name identifies the hold.
+	Name string `yaml:"name"`
+	// Room limits the hold to the room, all rooms when empty.
+	Room string `yaml:"room,omitempty"`
+	// Senders holds the messages of these users (case insensitive).
+	Senders []string `yaml:"senders,omitempty"`
+	// MessageIDs holds these messages.
+	MessageIDs []string `yaml:"messageIds,omitempty"`
+}
+
+// holds returns true when the hold exempts the message in the room.
+// Holds without senders and message IDs hold the whole room.
+func (l *LegalHold) holds(room string, m *EventNewMessage) bool {
+	if l.Room != "" && l.Room != room {
+		return false
+	}
+	if len(l.Senders) == 0 && len(l.MessageIDs) == 0 {
+		return true
+	}
+	if IsMentioned(m.Sender, l.Senders) {
+		return true
+	}
+	for _, id := range l.MessageIDs {
+		if id == m.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// RetentionConfig are the retention policies and legal holds of the
+// server config file.
+type RetentionConfig struct {
+	// Default is the policy of rooms without policy.
+	Default RetentionPolicy `yaml:"default"`
+	// Rooms are the policies by room name.
+	Rooms map[string]RetentionPolicy `yaml:"rooms,omitempty"`
+	// LegalHolds exempt messages from the policies.
+	LegalHolds []LegalHold `yaml:"legalHolds,omitempty"`
+	// Interval is the time between purges, DefaultRetentionInterval
+	// when zero.
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+// Validate returns an error when the config has invalid values.
+func (c *RetentionConfig) Validate() error {
+	policies := map[string]RetentionPolicy{"default": c.Default}
+	for room, p := range c.Rooms {
+		policies[fmt.Sprintf(`room "%s"`, room)] = p
+	}
+	for name, p := range policies {
+		if p.Days < 0 || p.Messages < 0 {
+			return fmt.Errorf("%s: negative retention", name)
+		}
+	}
+	for i, hold := range c.LegalHolds {
+		if hold.Name == "" {
+			return fmt.Errorf("legal hold %d: no name", i+1)
+		}
+	}
+	if c.Interval < 0 {
+		return errors.New("negative interval")
+	}
+	return nil
+}
+
+// Policy returns the policy of the room.
+func (c *RetentionConfig) Policy(room string) RetentionPolicy {
+	if p, ok := c.Rooms[room]; ok {
+		return p
+	}
+	return c.Default
+}
+
+// RetentionReport is the result of purging history.
+type RetentionReport struct {
+	Time time.Time `json:"time"`
+	// DryRun is true when nothing was purged.
+	DryRun bool            `json:"dryRun"`
+	Rooms  []RoomRetention `json:"rooms"`
+}
+
+// RoomRetention is the retention report of a room.
+type RoomRetention struct {
+	Room   string          `json:"room"`
+	Policy RetentionPolicy `json:"policy"`
+	// Messages is the number of messages before purging.
+	Messages int `json:"messages"`
+	// Purged are the IDs of the expired messages.
+	Purged []string `json:"purged"`
+	// Held are the IDs of the expired messages exempted by legal holds.
+	Held []string `json:"held"`
+	// Events is the number of other expired events, like users
+	// entering and leaving.
+	Events int `json:"events"`
+}
+
+// PurgeHistory removes the expired history by the retention policies
+// and tells the sessions which messages were deleted. Reports without
+// removing anything when dryRun is set or retention is not configured.
+func (h *Hub) PurgeHistory(dryRun bool) RetentionReport {
+	config := h.retention
+	if config == nil {
+		config = &RetentionConfig{}
+		dryRun = true
+	}
+	now := h.clock.Now()
+	policy := config.Policy(DefaultRoom)
+	report := RoomRetention{
+		Room:   DefaultRoom,
+		Policy: policy,
+		Purged: []string{},
+		Held:   []string{},
+	}
+
+	var before time.Time
+	if policy.Days > 0 {
+		before = now.AddDate(0, 0, -policy.Days)
+	}
+	events := h.history.Range(time.Time{}, time.Time{})
+	for _, e := range events {
+		if _, ok := e.(*EventNewMessage); ok {
+			report.Messages++
+		}
+	}
+
+	expired := map[Event]bool{}
+	messages := 0
+	for _, e := range events {
+		m, ok := e.(*EventNewMessage)
+		if !ok {
+			if !before.IsZero() && e.When().Before(before) {
+				expired[e] = true
+				report.Events++
+			}
+			continue
+		}
+		messages++
+		tooOld := !before.IsZero() && m.Time.Before(before)
+		tooMany := policy.Messages > 0 && report.Messages-messages >= policy.Messages
+		if !tooOld && !tooMany {
+			continue
+		}
+		if held(config.LegalHolds, DefaultRoom, m) {
+			report.Held = append(report.Held, m.ID)
+			continue
+		}
+		expired[e] = true
+		report.Purged = append(report.Purged, m.ID)
+	}
+
+	if !dryRun && len(expired) > 0 {
+		h.history.Remove(func(e Event) bool { return expired[e] })
+		// Stored events are other values, matched by message ID or time.
+		purged := map[string]bool{}
+		for _, id := range report.Purged {
+			purged[id] = true
+		}
+		_, err := h.store.RemoveHistory(DefaultRoom, func(e Event) bool {
+			if m, ok := e.(*EventNewMessage); ok {
+				return purged[m.ID]
+			}
+			return !before.IsZero() && e.When().Before(before)
+		})
+		if err != nil {
+			h.logger.Errorw("could not purge stored history", log.Error(err))
+		}
+		if len(report.Purged) > 0 {
+			h.Broadcast(&EventMessagesDeleted{
+				EventMeta:  *NewEventMetaNow(h.clock),
+				MessageIDs: report.Purged,
+				Reason:     retentionReason,
+			})
+		}
+	}
+	return RetentionReport{
+		Time:   now,
+		DryRun: dryRun,
+		Rooms:  []RoomRetention{report},
+	}
+}
+
+func held(holds []LegalHold, room string, m *EventNewMessage) bool {
+	for i := range holds {
+		if holds[i].holds(room, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// purgeHistory purges history by the retention policies until the hub
+// closes.
+func (h *Hub) purgeHistory() {
+	interval := h.retention.Interval
+	if interval == 0 {
+		interval = DefaultRetentionInterval
+	}
+	for {
+		select {
+		case <-h.closed:
+			return
+		case <-h.clock.After(interval):
+			for _, r := range h.PurgeHistory(false).Rooms {
+				if len(r.Purged) > 0 || r.Events > 0 {
+					h.logger.Infow("purged history",
+						"room", r.Room,
+						"messages", len(r.Purged),
+						"held", len(r.Held),
+						"events", r.Events)
+				}
+			}
+		}
+	}
+}
+
+// RetentionReportToProto returns the protobuf message for the report.
+func RetentionReportToProto(r RetentionReport) *pb.RetentionReport {
+	p := &pb.RetentionReport{
+		Time:   timestamppb.New(r.Time),
+		DryRun: r.DryRun,
+	}
+	for _, room := range r.Rooms {
+		p.Rooms = append(p.Rooms, &pb.RoomRetention{
+			Room: room.Room,
+			Policy: &pb.RetentionPolicy{
+				Days:     int32(room.Policy.Days),
+				Messages: int32(room.Policy.Messages),
+			},
+			Messages: int32(room.Messages),
+			Purged:   room.Purged,
+			Held:     room.Held,
+			Events:   int32(room.Events),
+		})
+	}
+	return p
+}
+
+// RetentionReportFromProto returns the report of the protobuf message.
+func RetentionReportFromProto(p *pb.RetentionReport) RetentionReport {
+	r := RetentionReport{
+		Time:   p.Time.AsTime(),
+		DryRun: p.DryRun,
+		Rooms:  []RoomRetention{},
+	}
+	for _, room := range p.Rooms {
+		rr := RoomRetention{
+			Room: room.Room,
+			Policy: RetentionPolicy{
+				Days:     int(room.Policy.GetDays()),
+				Messages: int(room.Policy.GetMessages()),
+			},
+			Messages: int(room.Messages),
+			Purged:   room.Purged,
+			Held:     room.Held,
+			Events:   int(room.Events),
+		}
+		if rr.Purged == nil {
+			rr.Purged = []string{}
+		}
+		if rr.Held == nil {
+			rr.Held = []string{}
+		}
+		r.Rooms = append(r.Rooms, rr)
+	}
+	return r
+}
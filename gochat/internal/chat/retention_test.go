@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubRetention(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(now)
+	hub := NewHub(test.NewTestLogger(true), HubOpts{
+		Clock: fake,
+		Retention: &RetentionConfig{
+			Default:    RetentionPolicy{Days: 1, Messages: 3},
+			LegalHolds: []LegalHold{{Name: "case-42", Senders: []string{"Mallory"}}},
+			Interval:   time.Minute,
+		},
+	})
+	t.Cleanup(func() { _ = hub.Close() })
+
+	message := func(id string, sender string, age time.Duration) *EventNewMessage {
+		return &EventNewMessage{
+			EventMeta: EventMeta{Time: now.Add(-age)},
+			ID:        id,
+			Sender:    sender,
+		}
+	}
+	hub.history.Add(&EventUserEnter{EventMeta: EventMeta{Time: now.Add(-48 * time.Hour)}})
+	hub.history.Add(message("m1", "alice", 48*time.Hour))   // too old
+	hub.history.Add(message("m2", "mallory", 47*time.Hour)) // too old, held
+	hub.history.Add(message("m3", "alice", time.Hour))      // too many
+	hub.history.Add(message("m4", "alice", 30*time.Minute))
+	hub.history.Add(message("m5", "alice", 10*time.Minute))
+	hub.history.Add(message("m6", "alice", 5*time.Minute))
+
+	want := RoomRetention{
+		Room:     DefaultRoom,
+		Policy:   RetentionPolicy{Days: 1, Messages: 3},
+		Messages: 6,
+		Purged:   []string{"m1", "m3"},
+		Held:     []string{"m2"},
+		Events:   1,
+	}
+	report := hub.PurgeHistory(true)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []RoomRetention{want}, report.Rooms)
+	assert.Equal(t, 7, hub.history.Len())
+
+	bobIn := make(chan Event)
+	bobOut := make(chan Event, 10)
+	_, err := hub.Connect("bob", NewTestConnection(bobIn, bobOut))
+	require.NoError(t, err)
+	nextEvent[*EventConnected](t, bobOut)
+	bobIn <- NewEventHello(fake)
+	nextEvent[*EventHello](t, bobOut)
+
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute)
+	deleted := nextEvent[*EventMessagesDeleted](t, bobOut)
+	assert.Equal(t, []string{"m1", "m3"}, deleted.MessageIDs)
+	ids := []string{}
+	for _, e := range hub.History(time.Time{}, time.Time{}) {
+		if m, ok := e.(*EventNewMessage); ok {
+			ids = append(ids, m.ID)
+		}
+	}
+	assert.Equal(t, []string{"m2", "m4", "m5", "m6"}, ids)
+	assert.Equal(t, 5, hub.history.Len()) // and bob entering
+}
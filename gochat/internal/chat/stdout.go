@@ -2,20 +2,74 @@ package chat
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
-	"time"
+	"strings"
+	"sync"
 
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
 	"github.com/marcelbeumer/go-playground/gochat/internal/util/channel"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
 )
 
+// Formats of the StdoutFrontend.
+const (
+	// StdoutFormatText prints human readable lines and sends every
+	// input line as message.
+	StdoutFormatText = "text"
+	// StdoutFormatJSON prints every event as JSON line and reads JSON
+	// commands (see StdoutCommand), for scripts.
+	StdoutFormatJSON = "json"
+)
+
+// maxStdinLine is the maximum length of input lines.
+const maxStdinLine = 1024 * 1024
+
+// StdoutEvent is an output line of StdoutFormatJSON.
+type StdoutEvent struct {
+	// Name is the event name, or "error" for failed commands.
+	Name string `json:"name"`
+	Data any    `json:"data"`
+}
+
+// StdoutError is the data of "error" output lines.
+type StdoutError struct {
+	Message string `json:"message"`
+}
+
+// StdoutCommand is an input line of StdoutFormatJSON.
+type StdoutCommand struct {
+	// Type is the command: "send" sends Message, "dm" sends Message
+	// to user To and "join" joins Room.
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	To      string `json:"to,omitempty"`
+	Room    string `json:"room,omitempty"`
+}
+
 type StdoutFrontend struct {
 	logger log.Logger
 	conn   Connection
+	// Input is read for messages to send, os.Stdin by default.
+	// Nil for no input.
+	Input io.Reader
+	// Output is written with received events, os.Stdout by default.
+	Output io.Writer
+	// Clock for event times, clock.Real by default.
+	Clock clock.Clock
+	// Plain prints messages without formatting instead of rendering
+	// their Markdown with ANSI escape codes.
+	Plain bool
+	// Format is StdoutFormatText (default) or StdoutFormatJSON.
+	Format   string
+	outputMu sync.Mutex
 }
 
+// Start runs the frontend until the connection closes or the input
+// ends (EOF), which is not an error.
 func (f *StdoutFrontend) Start() error {
 	stop := make(chan struct{})
 	defer close(stop)
@@ -28,82 +82,208 @@ func (f *StdoutFrontend) Start() error {
 }
 
 func (f *StdoutFrontend) pumpStdin(stop <-chan struct{}) error {
-	in := bufio.NewReader(os.Stdin)
-	input := []byte{}
+	if f.Input == nil {
+		<-stop
+		return nil
+	}
+	lines := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		// Reads can not be interrupted, so the reader is left behind
+		// when stopping.
+		scanner := bufio.NewScanner(f.Input)
+		scanner.Buffer(nil, maxStdinLine)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-stop:
+				return
+			}
+		}
+		readErr <- scanner.Err() // nil on EOF
+	}()
+
 	for {
 		select {
 		case <-stop:
 			return nil
-		default:
-			b, err := in.ReadByte() // no way to interrupt
+		case err := <-readErr:
+			return err
+		case line := <-lines:
+			var err error
+			if f.Format == StdoutFormatJSON {
+				err = f.runCommand(line)
+			} else if line != "" {
+				err = f.sendMessage(line)
+			}
 			if err != nil {
 				return err
 			}
-			if string(b) == "\n" {
-				msg := string(input)
-				input = []byte{} // reset
-				err := f.conn.SendEvent(&EventSendMessage{
-					EventMeta: EventMeta{Time: time.Now()},
-					Message:   msg,
-				})
-				if err != nil {
-					return err
-				}
-			} else {
-				input = append(input, b)
-			}
 		}
 	}
 }
 
+// runCommand runs the JSON command line. Invalid commands are reported
+// as "error" output lines.
+func (f *StdoutFrontend) runCommand(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	cmd := StdoutCommand{}
+	if err := json.Unmarshal([]byte(line), &cmd); err != nil {
+		return f.writeError(fmt.Sprintf("invalid command: %s", err))
+	}
+	switch cmd.Type {
+	case "send":
+		if cmd.Message == "" {
+			return f.writeError("send: no message")
+		}
+		return f.sendMessage(cmd.Message)
+	case "dm":
+		return f.writeError("dm: direct messages are not supported by the server")
+	case "join":
+		if cmd.Room != DefaultRoom {
+			return f.writeError(fmt.Sprintf(`join: unknown room "%s"`, cmd.Room))
+		}
+		return nil // the only room, joined on connect
+	default:
+		return f.writeError(fmt.Sprintf(`unknown command "%s"`, cmd.Type))
+	}
+}
+
+func (f *StdoutFrontend) sendMessage(message string) error {
+	return sendTraced(f.conn, &EventSendMessage{
+		EventMeta: *NewEventMetaNow(f.Clock),
+		Message:   message,
+	}, "stdout.sendMessage")
+}
+
+// writeError writes an "error" output line.
+func (f *StdoutFrontend) writeError(message string) error {
+	return f.writeJSON(&StdoutEvent{
+		Name: "error",
+		Data: &StdoutError{Message: message},
+	})
+}
+
+func (f *StdoutFrontend) writeJSON(v any) error {
+	f.outputMu.Lock()
+	defer f.outputMu.Unlock()
+	return json.NewEncoder(f.Output).Encode(v)
+}
+
+func (f *StdoutFrontend) printf(format string, a ...any) {
+	f.outputMu.Lock()
+	defer f.outputMu.Unlock()
+	fmt.Fprintf(f.Output, format, a...)
+}
+
 func (f *StdoutFrontend) pumpEvents(stop <-chan struct{}) error {
 	for {
 		select {
 		case <-stop:
 			return nil
 		default:
-			logger := f.logger
 			e, err := f.conn.ReadEvent()
 			if err != nil {
 				return err
 			}
-
-			switch t := e.(type) {
-			case *EventConnected:
-				//
-			case *EventUserListUpdate:
-				//
-			case *EventUserEnter:
-				fmt.Printf(
-					"[%s] <<user \"%s\" entered the room>>\n",
-					t.Time.Local(),
-					t.Name,
-				)
-			case *EventUserLeave:
-				fmt.Printf(
-					"[%s] <<user \"%s\" left the room>>\n",
-					t.Time.Local(),
-					t.Name,
-				)
-			case *EventNewMessage:
-				fmt.Printf(
-					"[%s %s] >> %s\n",
-					t.Time.Local(),
-					t.Sender,
-					t.Message,
-				)
-			default:
-				logger.Warnw(
-					"unhandled event type",
-					"type", reflect.TypeOf(e).String())
+			if f.Format == StdoutFormatJSON {
+				err = f.writeEvent(e)
+			} else {
+				f.printEvent(e)
+			}
+			if err != nil {
+				return err
 			}
 		}
 	}
 }
 
+// writeEvent writes the event as JSON output line.
+func (f *StdoutFrontend) writeEvent(e Event) error {
+	et, ok := Events.ByEvent(e)
+	if !ok {
+		f.logger.Warnw("unhandled event type", "type", reflect.TypeOf(e).String())
+		return nil
+	}
+	return f.writeJSON(&StdoutEvent{Name: et.Name, Data: e})
+}
+
+// printEvent prints the event as human readable line.
+func (f *StdoutFrontend) printEvent(e Event) {
+	logger := f.logger
+	switch t := e.(type) {
+	case *EventHello:
+		logger.Debugw("protocol negotiated", "version", t.Version, "features", t.Features)
+	case *EventConnected:
+		//
+	case *EventUserListUpdate:
+		//
+	case *EventUserEnter:
+		f.printf(
+			"[%s] <<user \"%s\" entered the room>>\n",
+			t.Time.Local(),
+			t.Name,
+		)
+	case *EventUserLeave:
+		f.printf(
+			"[%s] <<user \"%s\" left the room>>\n",
+			t.Time.Local(),
+			t.Name,
+		)
+	case *EventNewMessage:
+		message := FormatMarkdownANSI(t.Message)
+		if f.Plain {
+			message = StripMarkdown(t.Message)
+		}
+		f.printf(
+			"[%s %s] >> %s\n",
+			t.Time.Local(),
+			t.Sender,
+			message,
+		)
+	case *EventNickChange:
+		f.printf(
+			"[%s] <<user \"%s\" is now known as \"%s\">>\n",
+			t.Time.Local(),
+			t.OldName,
+			t.NewName,
+		)
+	case *EventNotice:
+		f.printf("[%s] <<%s>>\n", t.Time.Local(), t.Message)
+	case *EventOfflineBacklog:
+		f.printf(
+			"[%s] <<%d missed while offline, since %s>>\n",
+			t.Time.Local(), t.Count, t.Since.Local(),
+		)
+	case *EventMessageEnrichment:
+		f.printf("[%s] <<preview of %s: %s>>\n", t.Time.Local(), t.URL, t.Title)
+	case *EventReadState:
+		//
+	case *EventMessagesDeleted:
+		f.printf(
+			"[%s] <<%d messages deleted: %s>>\n",
+			t.Time.Local(), len(t.MessageIDs), t.Reason,
+		)
+	case *EventProfile:
+		//
+	case *EventHistory:
+		//
+	default:
+		logger.Warnw(
+			"unhandled event type",
+			"type", reflect.TypeOf(e).String())
+	}
+}
+
 func NewStdoutFrontend(conn Connection, logger log.Logger) *StdoutFrontend {
 	return &StdoutFrontend{
 		logger: logger,
 		conn:   conn,
+		Input:  os.Stdin,
+		Output: os.Stdout,
+		Clock:  clock.Real,
+		Format: StdoutFormatText,
 	}
 }
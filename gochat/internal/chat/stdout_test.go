@@ -0,0 +1,96 @@
+package chat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// output returns the output of the frontend so far.
+func (f *StdoutFrontend) output() string {
+	f.outputMu.Lock()
+	defer f.outputMu.Unlock()
+	return f.Output.(*bytes.Buffer).String()
+}
+
+func TestStdoutFrontendJSON(t *testing.T) {
+	in := make(chan Event)
+	out := make(chan Event, 10)
+	inputR, inputW := io.Pipe()
+	f := NewStdoutFrontend(NewTestConnection(in, out), test.NewTestLogger(true))
+	f.Input = inputR
+	f.Output = &bytes.Buffer{}
+	f.Format = StdoutFormatJSON
+	done := make(chan error, 1)
+	go func() { done <- f.Start() }()
+
+	in <- &EventNewMessage{
+		EventMeta: EventMeta{Time: time.UnixMilli(0).UTC()},
+		ID:        "m1",
+		Sender:    "bob",
+		Message:   "**hi**",
+	}
+	in <- &EventUserListUpdate{Users: []string{"bob"}} // after writing m1
+	_, err := io.WriteString(inputW, strings.Join([]string{
+		`{"type":"send","message":"hello"}`,
+		`{"type":"dm","to":"bob","message":"psst"}`,
+		`{"type":"join","room":"main"}`,
+		`{"type":"join","room":"random"}`,
+		`not json`,
+		"",
+	}, "\n"))
+	require.NoError(t, err)
+	send := nextEvent[*EventSendMessage](t, out)
+	assert.Equal(t, "hello", send.Message)
+
+	require.NoError(t, inputW.Close())
+	startErr, err := test.ChTimeout(t, done)
+	require.NoError(t, err)
+	require.NoError(t, startErr)
+
+	lines := strings.Split(strings.TrimSpace(f.output()), "\n")
+	assert.ElementsMatch(t, []string{
+		`{"name":"newMessage","data":{"time":"1970-01-01T00:00:00Z","id":"m1","sender":"bob","message":"**hi**"}}`,
+		`{"name":"userListUpdate","data":{"time":"0001-01-01T00:00:00Z","users":["bob"]}}`,
+		`{"name":"error","data":{"message":"dm: direct messages are not supported by the server"}}`,
+		`{"name":"error","data":{"message":"join: unknown room \"random\""}}`,
+		`{"name":"error","data":{"message":"invalid command: invalid character 'o' in literal null (expecting 'u')"}}`,
+	}, lines)
+}
+
+func TestStdoutFrontendText(t *testing.T) {
+	in := make(chan Event)
+	out := make(chan Event, 10)
+	f := NewStdoutFrontend(NewTestConnection(in, out), test.NewTestLogger(true))
+	inputR, inputW := io.Pipe()
+	f.Input = inputR
+	f.Output = &bytes.Buffer{}
+	f.Plain = true
+	done := make(chan error, 1)
+	go func() { done <- f.Start() }()
+
+	in <- &EventNewMessage{
+		EventMeta: EventMeta{Time: time.UnixMilli(0).UTC()},
+		Sender:    "bob",
+		Message:   "**hi** `there`",
+	}
+	in <- &EventUserListUpdate{} // after printing the message
+	_, err := io.WriteString(inputW, "hello\n\nno newline")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", nextEvent[*EventSendMessage](t, out).Message)
+	require.NoError(t, inputW.Close())
+	assert.Equal(t, "no newline", nextEvent[*EventSendMessage](t, out).Message)
+	startErr, err := test.ChTimeout(t, done)
+	require.NoError(t, err)
+	require.NoError(t, startErr)
+
+	want := fmt.Sprintf("[%s bob] >> hi there\n", time.UnixMilli(0).Local())
+	assert.Equal(t, want, f.output())
+}
@@ -0,0 +1,456 @@
+package chat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/kvstore"
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+)
+
+// Store kinds for the --store flag.
+const (
+	StoreMemory = "memory"
+	StoreBolt   = "bolt"
+)
+
+// Role is the role of a member in a room.
+type Role string
+
+const (
+	RoleMember Role = "member"
+	RoleAdmin  Role = "admin"
+)
+
+// Membership is a user that joined a room. Only authenticated users
+// are members, by the user ID of their identity.
+type Membership struct {
+	Room   string    `json:"room"`
+	UserID string    `json:"userId"`
+	Name   string    `json:"name"`
+	Role   Role      `json:"role"`
+	Joined time.Time `json:"joined"`
+}
+
+// Ban keeps an authenticated user out of a room.
+type Ban struct {
+	Room   string `json:"room"`
+	UserID string `json:"userId"`
+	// Name is the name of the user when banned.
+	Name   string `json:"name"`
+	Reason string `json:"reason,omitempty"`
+	// Until is when the ban ends, never when zero.
+	Until time.Time `json:"until,omitempty"`
+}
+
+// Active returns true when the ban applies at the time.
+func (b *Ban) Active(now time.Time) bool {
+	return b.Until.IsZero() || now.Before(b.Until)
+}
+
+// Store persists the hub state: rooms, memberships, roles, bans and
+// history. The hub keeps history in memory as well and only reads it
+// from the store when created.
+type Store interface {
+	// AddRoom adds the room when it does not exist.
+	AddRoom(room string) error
+	// Rooms returns the names of the rooms in order.
+	Rooms() ([]string, error)
+	// SetMember adds or replaces the membership.
+	SetMember(m Membership) error
+	// Member returns the membership of the user in the room, false
+	// when not a member.
+	Member(room string, userID string) (Membership, bool, error)
+	// Members returns the members of the room ordered by user ID.
+	Members(room string) ([]Membership, error)
+	// SetRole sets the role of a member, ErrNotMember for others.
+	SetRole(room string, userID string, role Role) error
+	// AddBan adds or replaces the ban of the user in the room.
+	AddBan(b Ban) error
+	// RemoveBan removes the ban, if any.
+	RemoveBan(room string, userID string) error
+	// Bans returns the bans of the room ordered by user ID, expired
+	// bans included.
+	Bans(room string) ([]Ban, error)
+	// AddHistory appends the events to the history of the room,
+	// dropping the oldest events over limit (unlimited when zero).
+	AddHistory(room string, events []Event, limit int) error
+	// History returns the history of the room in order.
+	History(room string) ([]Event, error)
+	// RemoveHistory removes the events of the room for which remove
+	// returns true, returning the number of removed events.
+	RemoveHistory(room string, remove func(e Event) bool) (int, error)
+	// Close releases the store.
+	Close() error
+}
+
+// DefaultStorePath returns the path of the BoltStore in the user config
+// dir ($XDG_CONFIG_HOME/gochat/gochat.db on Linux).
+func DefaultStorePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gochat", "gochat.db"), nil
+}
+
+// OpenStore opens the store of the kind (StoreMemory or StoreBolt).
+// Path is the file of StoreBolt.
+func OpenStore(kind string, path string) (Store, error) {
+	switch kind {
+	case StoreMemory, "":
+		return NewMemoryStore(), nil
+	case StoreBolt:
+		return OpenBoltStore(path)
+	default:
+		return nil, fmt.Errorf(`unknown store "%s"`, kind)
+	}
+}
+
+// memberKey is the key of memberships and bans.
+type memberKey struct {
+	room   string
+	userID string
+}
+
+// MemoryStore is a Store in memory, lost when the process ends.
+type MemoryStore struct {
+	rooms   *kvstore.KVStore[string, bool]
+	members *kvstore.KVStore[memberKey, Membership]
+	bans    *kvstore.KVStore[memberKey, Ban]
+	// membersMu makes SetRole atomic.
+	membersMu sync.Mutex
+	historyMu sync.RWMutex
+	history   map[string][]Event
+}
+
+// NewMemoryStore returns an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		rooms:   kvstore.NewKVStore[string, bool](),
+		members: kvstore.NewKVStore[memberKey, Membership](),
+		bans:    kvstore.NewKVStore[memberKey, Ban](),
+		history: map[string][]Event{},
+	}
+}
+
+func (s *MemoryStore) AddRoom(room string) error {
+	s.rooms.Set(room, true)
+	return nil
+}
+
+func (s *MemoryStore) Rooms() ([]string, error) {
+	rooms := s.rooms.Keys()
+	sort.Strings(rooms)
+	return rooms, nil
+}
+
+func (s *MemoryStore) SetMember(m Membership) error {
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	s.members.Set(memberKey{m.Room, m.UserID}, m)
+	return nil
+}
+
+func (s *MemoryStore) Member(room string, userID string) (Membership, bool, error) {
+	m, ok := s.members.Get(memberKey{room, userID})
+	return m, ok, nil
+}
+
+func (s *MemoryStore) Members(room string) ([]Membership, error) {
+	members := []Membership{}
+	for _, m := range s.members.Values() {
+		if m.Room == room {
+			members = append(members, m)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+func (s *MemoryStore) SetRole(room string, userID string, role Role) error {
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	key := memberKey{room, userID}
+	m, ok := s.members.Get(key)
+	if !ok {
+		return &ErrNotMember{room: room, userID: userID}
+	}
+	m.Role = role
+	s.members.Set(key, m)
+	return nil
+}
+
+func (s *MemoryStore) AddBan(b Ban) error {
+	s.bans.Set(memberKey{b.Room, b.UserID}, b)
+	return nil
+}
+
+func (s *MemoryStore) RemoveBan(room string, userID string) error {
+	s.bans.Delete(memberKey{room, userID})
+	return nil
+}
+
+func (s *MemoryStore) Bans(room string) ([]Ban, error) {
+	bans := []Ban{}
+	for _, b := range s.bans.Values() {
+		if b.Room == room {
+			bans = append(bans, b)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].UserID < bans[j].UserID
+	})
+	return bans, nil
+}
+
+func (s *MemoryStore) AddHistory(room string, added []Event, limit int) error {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	events := append(s.history[room], added...)
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	s.history[room] = events
+	return nil
+}
+
+func (s *MemoryStore) History(room string) ([]Event, error) {
+	s.historyMu.RLock()
+	defer s.historyMu.RUnlock()
+	return append([]Event{}, s.history[room]...), nil
+}
+
+func (s *MemoryStore) RemoveHistory(room string, remove func(e Event) bool) (int, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	events := []Event{}
+	for _, e := range s.history[room] {
+		if !remove(e) {
+			events = append(events, e)
+		}
+	}
+	n := len(s.history[room]) - len(events)
+	s.history[room] = events
+	return n, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// loadStore adds the default room to the store and loads its history,
+// continuing the message IDs after the last stored message.
+func (h *Hub) loadStore() {
+	if err := h.store.AddRoom(DefaultRoom); err != nil {
+		h.logger.Errorw("could not store room", log.Error(err))
+	}
+	events, err := h.store.History(DefaultRoom)
+	if err != nil {
+		h.logger.Errorw("could not load history", log.Error(err))
+		return
+	}
+	for _, e := range events {
+		h.history.Add(e)
+		if m, ok := e.(*EventNewMessage); ok {
+			if seq, ok := messageSeq(m.ID); ok && seq > h.msgInc {
+				h.msgInc = seq
+			}
+		}
+	}
+}
+
+// storeQueueSize is the number of pending store writes before the hub
+// waits for the store.
+const storeQueueSize = 1024
+
+// storeWrite is a pending write to the store: a history event, a
+// membership or a role. Roles are written by writeStore as well, so a
+// queued membership write of the member does not undo them.
+type storeWrite struct {
+	event  Event
+	member *Membership
+	role   *roleWrite
+	// done gets the result of role writes, or nil for writes without
+	// value once the writes queued before are stored.
+	done chan error
+}
+
+// roleWrite sets the role of a member.
+type roleWrite struct {
+	userID string
+	role   Role
+}
+
+// addHistory adds the event to the history and queues it for the store.
+func (h *Hub) addHistory(e Event) {
+	h.history.Add(e)
+	h.queueStoreWrite(storeWrite{event: e})
+}
+
+// storeMember queues adding the authenticated user as member of the
+// room, or updating the name of the member.
+func (h *Hub) storeMember(p UserProfile) {
+	h.queueStoreWrite(storeWrite{member: &Membership{
+		Room:   DefaultRoom,
+		UserID: p.UserID,
+		Name:   p.Name,
+		Role:   RoleMember,
+		Joined: h.clock.Now(),
+	}})
+}
+
+// storeRole sets the role of the member in the room after the pending
+// store writes.
+func (h *Hub) storeRole(userID string, role Role) error {
+	return h.awaitStoreWrite(storeWrite{role: &roleWrite{userID: userID, role: role}})
+}
+
+// syncStore waits until the pending store writes are stored, for reads
+// that should see them.
+func (h *Hub) syncStore() error {
+	return h.awaitStoreWrite(storeWrite{})
+}
+
+// awaitStoreWrite queues the write and returns its result.
+func (h *Hub) awaitStoreWrite(w storeWrite) error {
+	done := make(chan error, 1)
+	w.done = done
+	h.queueStoreWrite(w)
+	select {
+	case err := <-done:
+		return err
+	case <-h.storeDone:
+		select {
+		case err := <-done:
+			return err
+		default:
+			return ErrHubClosed
+		}
+	}
+}
+
+// queueStoreWrite queues the write for writeStore, so callers holding
+// locks do not wait for disk I/O. Writes after the hub closed are lost.
+func (h *Hub) queueStoreWrite(w storeWrite) {
+	select {
+	case h.storeWrites <- w:
+	case <-h.storeDone:
+	}
+}
+
+// writeStore writes the queued writes to the store until the hub closes
+// and the queue is empty.
+func (h *Hub) writeStore() {
+	defer close(h.storeDone)
+	for {
+		select {
+		case w := <-h.storeWrites:
+			h.flushStore(w)
+		case <-h.closed:
+			for {
+				select {
+				case w := <-h.storeWrites:
+					h.flushStore(w)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// flushStore writes w and the writes queued after it, adding history
+// events in batches.
+func (h *Hub) flushStore(w storeWrite) {
+	events := []Event{}
+	for {
+		switch {
+		case w.member != nil:
+			h.writeHistory(events)
+			events = events[:0]
+			h.writeMember(*w.member)
+		case w.role != nil:
+			h.writeHistory(events)
+			events = events[:0]
+			w.done <- h.store.SetRole(DefaultRoom, w.role.userID, w.role.role)
+		case w.done != nil:
+			h.writeHistory(events)
+			events = events[:0]
+			w.done <- nil
+		default:
+			events = append(events, w.event)
+		}
+		if len(events) == storeQueueSize {
+			break
+		}
+		select {
+		case w = <-h.storeWrites:
+			continue
+		default:
+		}
+		break
+	}
+	h.writeHistory(events)
+}
+
+func (h *Hub) writeHistory(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	if err := h.store.AddHistory(DefaultRoom, events, DefaultHistoryLimit); err != nil {
+		h.logger.Errorw("could not store history", log.Error(err))
+	}
+}
+
+// writeMember stores the membership, keeping the role and join time of
+// members.
+func (h *Hub) writeMember(m Membership) {
+	existing, ok, err := h.store.Member(m.Room, m.UserID)
+	if err == nil && ok {
+		if existing.Name == m.Name {
+			return
+		}
+		m.Role = existing.Role
+		m.Joined = existing.Joined
+	}
+	if err == nil {
+		err = h.store.SetMember(m)
+	}
+	if err != nil {
+		h.logger.Errorw("could not store member", log.Error(err))
+	}
+}
+
+// activeBan returns the active ban of the user in the room. Users are
+// not kept out when the bans can not be read.
+func (h *Hub) activeBan(userID string) (Ban, bool) {
+	bans, err := h.store.Bans(DefaultRoom)
+	if err != nil {
+		h.logger.Errorw("could not read bans", log.Error(err))
+		return Ban{}, false
+	}
+	now := h.clock.Now()
+	for _, b := range bans {
+		if b.UserID == userID && b.Active(now) {
+			return b, true
+		}
+	}
+	return Ban{}, false
+}
+
+// ErrNotMember for when the user is not a member of the room.
+type ErrNotMember struct {
+	room   string
+	userID string
+}
+
+func (e *ErrNotMember) Error() string {
+	return fmt.Sprintf(`user "%s" is not a member of room "%s"`, e.userID, e.room)
+}
@@ -0,0 +1,313 @@
+package chat
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// stores open a new store of every kind.
+var stores = map[string]func(t *testing.T) Store{
+	StoreMemory: func(t *testing.T) Store {
+		return NewMemoryStore()
+	},
+	StoreBolt: func(t *testing.T) Store {
+		s, err := OpenBoltStore(filepath.Join(t.TempDir(), "gochat.db"))
+		require.NoError(t, err)
+		return s
+	},
+}
+
+func TestStore(t *testing.T) {
+	t0 := time.UnixMilli(0).UTC()
+	for kind, open := range stores {
+		t.Run(kind, func(t *testing.T) {
+			s := open(t)
+			t.Cleanup(func() { _ = s.Close() })
+
+			require.NoError(t, s.AddRoom("main"))
+			require.NoError(t, s.AddRoom("dev"))
+			require.NoError(t, s.AddRoom("main"))
+			rooms, err := s.Rooms()
+			require.NoError(t, err)
+			assert.Equal(t, []string{"dev", "main"}, rooms)
+
+			alice := Membership{Room: "main", UserID: "ualice", Name: "alice", Role: RoleMember, Joined: t0}
+			require.NoError(t, s.SetMember(alice))
+			require.NoError(t, s.SetMember(Membership{Room: "dev", UserID: "ualice", Name: "other"}))
+			m, ok, err := s.Member("main", "ualice")
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, alice, m)
+			alice.Name = "alicia"
+			require.NoError(t, s.SetMember(alice))
+			m, _, err = s.Member("main", "ualice")
+			require.NoError(t, err)
+			assert.Equal(t, "alicia", m.Name)
+			_, ok, err = s.Member("main", "ubob")
+			require.NoError(t, err)
+			assert.False(t, ok)
+			_, ok, err = s.Member("nope", "ualice")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			require.NoError(t, s.SetMember(Membership{Room: "main", UserID: "uaaron", Name: "aaron", Role: RoleMember}))
+			require.NoError(t, s.SetRole("main", "ualice", RoleAdmin))
+			var notMember *ErrNotMember
+			assert.ErrorAs(t, s.SetRole("main", "ubob", RoleAdmin), &notMember)
+			members, err := s.Members("main")
+			require.NoError(t, err)
+			require.Len(t, members, 2)
+			assert.Equal(t, "aaron", members[0].Name)
+			assert.Equal(t, RoleAdmin, members[1].Role)
+			members, err = s.Members("nope")
+			require.NoError(t, err)
+			assert.Empty(t, members)
+
+			ban := Ban{Room: "main", UserID: "ualice", Name: "alicia", Reason: "spam", Until: t0.Add(time.Hour)}
+			require.NoError(t, s.AddBan(ban))
+			require.NoError(t, s.AddBan(Ban{Room: "main", UserID: "uaaron", Name: "aaron"}))
+			require.NoError(t, s.AddBan(Ban{Room: "dev", UserID: "ualice"}))
+			require.NoError(t, s.RemoveBan("main", "uaaron"))
+			require.NoError(t, s.RemoveBan("nope", "uaaron"))
+			bans, err := s.Bans("main")
+			require.NoError(t, err)
+			assert.Equal(t, []Ban{ban}, bans)
+			assert.True(t, bans[0].Active(t0))
+			assert.False(t, bans[0].Active(t0.Add(time.Hour)))
+
+			message := func(i int, message string) Event {
+				return &EventNewMessage{
+					EventMeta: EventMeta{Time: t0.Add(time.Duration(i) * time.Minute)},
+					ID:        fmt.Sprintf("m%d", i+1),
+					Sender:    "alice",
+					Message:   message,
+				}
+			}
+			require.NoError(t, s.AddHistory("main", []Event{message(0, "one")}, 3))
+			require.NoError(t, s.AddHistory("main", []Event{
+				message(1, "two"),
+				message(2, "three"),
+				message(3, "four"),
+			}, 3))
+			require.NoError(t, s.AddHistory("dev", []Event{&EventUserEnter{Name: "alice"}}, 0))
+			n, err := s.RemoveHistory("main", func(e Event) bool {
+				return e.(*EventNewMessage).ID == "m3"
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+			events, err := s.History("main")
+			require.NoError(t, err)
+			require.Len(t, events, 2)
+			assert.Equal(t, &EventNewMessage{
+				EventMeta: EventMeta{Time: t0.Add(time.Minute)},
+				ID:        "m2",
+				Sender:    "alice",
+				Message:   "two",
+			}, events[0])
+			assert.Equal(t, "four", events[1].(*EventNewMessage).Message)
+			events, err = s.History("nope")
+			require.NoError(t, err)
+			assert.Empty(t, events)
+		})
+	}
+}
+
+func TestBoltStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gochat.db")
+	s, err := OpenBoltStore(path)
+	require.NoError(t, err)
+	version, err := s.Version()
+	require.NoError(t, err)
+	assert.Equal(t, boltSchemaVersion, version)
+	require.NoError(t, s.AddHistory("main", []Event{&EventNotice{Message: "hi"}}, 0))
+	require.NoError(t, s.Close())
+
+	s, err = OpenBoltStore(path)
+	require.NoError(t, err)
+	events, err := s.History("main")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "hi", events[0].(*EventNotice).Message)
+
+	// Stores of newer versions are not touched.
+	require.NoError(t, s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMeta).Put(boltVersion, []byte("99"))
+	}))
+	require.NoError(t, s.Close())
+	_, err = OpenBoltStore(path)
+	assert.ErrorContains(t, err, "schema version 99 is newer than supported version")
+}
+
+func TestBoltStoreMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gochat.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucket(boltMeta)
+		if err != nil {
+			return err
+		}
+		return meta.Put(boltVersion, []byte("0"))
+	}))
+	require.NoError(t, db.Close())
+
+	s, err := OpenBoltStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	version, err := s.Version()
+	require.NoError(t, err)
+	assert.Equal(t, boltSchemaVersion, version)
+	require.NoError(t, s.SetMember(Membership{Room: "main", UserID: "ualice"}))
+	require.NoError(t, s.AddBan(Ban{Room: "main", UserID: "ualice"}))
+}
+
+func TestBoltStoreMigrateRoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gochat.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucket(boltMeta)
+		if err != nil {
+			return err
+		}
+		if err := boltMigrations[0](tx); err != nil {
+			return err
+		}
+		err = putJSON(tx, boltMembers, "main", "ualice", &Membership{
+			Room:   "main",
+			UserID: "ualice",
+			Name:   "alice",
+		})
+		if err != nil {
+			return err
+		}
+		return meta.Put(boltVersion, []byte("1"))
+	}))
+	require.NoError(t, db.Close())
+
+	s, err := OpenBoltStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	m, ok, err := s.Member("main", "ualice")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", m.Name)
+	assert.Equal(t, RoleMember, m.Role)
+	require.NoError(t, s.AddRoom("main"))
+}
+
+func TestHubStore(t *testing.T) {
+	for kind, open := range stores {
+		t.Run(kind, func(t *testing.T) {
+			s := open(t)
+			t.Cleanup(func() { _ = s.Close() })
+			fake := clock.NewFake(time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC))
+			alice := IdentityFromAuthorization("Bearer alice")
+
+			hub := NewHub(test.NewTestLogger(true), HubOpts{Clock: fake, Store: s})
+			aliceIn := make(chan Event)
+			aliceOut := make(chan Event, 10)
+			_, err := hub.ConnectIdentity(alice, "alice", NewTestConnection(aliceIn, aliceOut))
+			require.NoError(t, err)
+			nextEvent[*EventConnected](t, aliceOut)
+			aliceIn <- &EventSendMessage{Message: "one"}
+			nextEvent[*EventNewMessage](t, aliceOut)
+			aliceIn <- &EventChangeNick{Name: "alicia"}
+			nextEvent[*EventNickChange](t, aliceOut)
+			require.NoError(t, hub.Close())
+
+			m, ok, err := s.Member(DefaultRoom, identityUserID(alice))
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, "alicia", m.Name)
+			assert.Equal(t, fake.Now(), m.Joined)
+
+			// A new hub, as after a restart, continues the history.
+			hub = NewHub(test.NewTestLogger(true), HubOpts{Clock: fake, Store: s})
+			t.Cleanup(func() { _ = hub.Close() })
+			history := hub.History(time.Time{}, time.Time{})
+			require.Len(t, history, 3)
+			assert.Equal(t, "one", history[1].(*EventNewMessage).Message)
+
+			bobIn := make(chan Event)
+			bobOut := make(chan Event, 10)
+			_, err = hub.Connect("bob", NewTestConnection(bobIn, bobOut))
+			require.NoError(t, err)
+			nextEvent[*EventConnected](t, bobOut)
+			bobIn <- &EventSendMessage{Message: "two"}
+			assert.Equal(t, "m2", nextEvent[*EventNewMessage](t, bobOut).ID)
+		})
+	}
+}
+
+func TestHubBans(t *testing.T) {
+	for kind, open := range stores {
+		t.Run(kind, func(t *testing.T) {
+			s := open(t)
+			t.Cleanup(func() { _ = s.Close() })
+			fake := clock.NewFake(time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC))
+			alice := IdentityFromAuthorization("Bearer alice")
+			hub := NewHub(test.NewTestLogger(true), HubOpts{Clock: fake, Store: s})
+			t.Cleanup(func() { _ = hub.Close() })
+			assert.Equal(t, []RoomInfo{{Name: DefaultRoom}}, hub.Rooms())
+
+			aliceIn := make(chan Event)
+			aliceOut := make(chan Event, 10)
+			aliceConn := NewTestConnection(aliceIn, aliceOut)
+			_, err := hub.ConnectIdentity(alice, "alice", aliceConn)
+			require.NoError(t, err)
+			nextEvent[*EventConnected](t, aliceOut)
+			_, err = hub.Connect("bob", NewTestConnection(make(chan Event), make(chan Event, 10)))
+			require.NoError(t, err)
+
+			// The role stays when the name changes.
+			require.NoError(t, hub.SetRole("alice", RoleAdmin))
+			aliceIn <- &EventChangeNick{Name: "alicia"}
+			nextEvent[*EventNickChange](t, aliceOut)
+			var invalidRole *ErrInvalidRole
+			assert.ErrorAs(t, hub.SetRole("alicia", "owner"), &invalidRole)
+
+			var notAuthenticated *ErrNotAuthenticated
+			assert.ErrorAs(t, hub.Ban("bob", "", time.Time{}), &notAuthenticated)
+			var notFound *ErrUserNotFound
+			assert.ErrorAs(t, hub.Ban("carol", "", time.Time{}), &notFound)
+
+			require.NoError(t, hub.Ban("ALICIA", "spam", fake.Now().Add(time.Hour)))
+			assert.Equal(t, "you were banned: spam", nextEvent[*EventNotice](t, aliceOut).Message)
+			assert.True(t, aliceConn.Closed())
+			_, err = hub.ConnectIdentity(alice, "alicia", NewTestConnection(make(chan Event), make(chan Event, 10)))
+			assert.EqualError(t, err, `user "alicia" is banned: spam`)
+			bans, err := hub.Bans()
+			require.NoError(t, err)
+			require.Len(t, bans, 1)
+			assert.Equal(t, "alicia", bans[0].Name)
+
+			// Offline members are found by name, bans end in time.
+			require.NoError(t, hub.Ban("alicia", "", time.Time{}))
+			require.NoError(t, hub.Unban("alicia"))
+			require.NoError(t, hub.Ban("alicia", "", fake.Now().Add(time.Hour)))
+			fake.Advance(time.Hour)
+			bans, err = hub.Bans()
+			require.NoError(t, err)
+			assert.Empty(t, bans)
+			var notBanned *ErrNotBanned
+			assert.ErrorAs(t, hub.Unban("alicia"), &notBanned)
+			_, err = hub.ConnectIdentity(alice, "alicia", NewTestConnection(make(chan Event), make(chan Event, 10)))
+			require.NoError(t, err)
+
+			members, err := hub.Members()
+			require.NoError(t, err)
+			require.Len(t, members, 1)
+			assert.Equal(t, "alicia", members[0].Name)
+			assert.Equal(t, RoleAdmin, members[0].Role)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package chat
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// metaEvent is implemented by all events, by embedding EventMeta.
+type metaEvent interface {
+	Meta() *EventMeta
+}
+
+// EventTrace returns the trace context of the event, nil when untraced.
+func EventTrace(e Event) map[string]string {
+	if m, ok := e.(metaEvent); ok {
+		return m.Meta().Trace
+	}
+	return nil
+}
+
+// SetEventTrace sets the trace context of a decoded event. Use
+// WithTrace for events that can be shared.
+func SetEventTrace(e Event, carrier map[string]string) {
+	if m, ok := e.(metaEvent); ok && len(carrier) > 0 {
+		m.Meta().Trace = carrier
+	}
+}
+
+// StartEventSpan starts a span that is a child of the trace context of
+// the event, or a new trace when the event is not traced.
+func StartEventSpan(
+	e Event,
+	name string,
+	opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	ctx := tracing.Extract(context.Background(), EventTrace(e))
+	return tracing.Tracer().Start(ctx, name, opts...)
+}
+
+// WithTrace returns the event with the trace context of ctx. Events can
+// be shared, so it returns a copy, or e itself when ctx has no span.
+func WithTrace(e Event, ctx context.Context) Event {
+	carrier := tracing.Inject(ctx)
+	v := reflect.ValueOf(e)
+	if _, ok := e.(metaEvent); !ok || carrier == nil || v.Kind() != reflect.Pointer {
+		return e
+	}
+	cp := reflect.New(v.Elem().Type())
+	cp.Elem().Set(v.Elem())
+	cp.Interface().(metaEvent).Meta().Trace = carrier
+	return cp.Interface().(Event)
+}
+
+// sendTraced sends the event in a new span, so the event can be followed
+// through the hub to the receivers.
+func sendTraced(conn Connection, e Event, name string) error {
+	ctx, span := tracing.Tracer().Start(context.Background(), name,
+		trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+	err := conn.SendEvent(WithTrace(e, ctx))
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
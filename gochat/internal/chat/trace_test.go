@@ -0,0 +1,108 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"github.com/marcelbeumer/go-playground/gochat/internal/tracing"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+)
+
+// recordSpans sets a global tracer provider recording spans for the
+// test. Afterwards the global provider is a noop provider: the default
+// one can not be restored, as it delegates to the first provider set.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		_ = provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func TestEventTraceProtoRoundTrip(t *testing.T) {
+	carrier := map[string]string{
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	}
+	e := &EventSendMessage{Message: "hi"}
+	SetEventTrace(e, carrier)
+
+	envelope, err := Events.ToProto(e)
+	require.NoError(t, err)
+	p, err := proto.Marshal(envelope)
+	require.NoError(t, err)
+	decodedEnvelope := &pb.EventEnvelope{}
+	require.NoError(t, proto.Unmarshal(p, decodedEnvelope))
+	decoded, err := Events.FromProto(decodedEnvelope)
+	require.NoError(t, err)
+	assert.Equal(t, carrier, EventTrace(decoded))
+}
+
+func TestWithTrace(t *testing.T) {
+	recordSpans(t)
+	e := &EventSendMessage{Message: "hi"}
+	assert.Same(t, e, WithTrace(e, context.Background()))
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+	traced := WithTrace(e, ctx)
+	assert.NotSame(t, e, traced)
+	assert.Nil(t, e.Trace, "original is not modified")
+	assert.Equal(t, "hi", traced.(*EventSendMessage).Message)
+	assert.NotEmpty(t, EventTrace(traced))
+}
+
+func TestHubTracing(t *testing.T) {
+	recorder := recordSpans(t)
+
+	hub := NewHub(test.NewTestLogger(true), HubOpts{})
+	t.Cleanup(func() { _ = hub.Close() })
+	user1In := make(chan Event)
+	user1Out := make(chan Event, 10)
+	user2In := make(chan Event)
+	user2Out := make(chan Event, 10)
+	_, err := hub.Connect("user1", NewTestConnection(user1In, user1Out))
+	require.NoError(t, err)
+	_, err = hub.Connect("user2", NewTestConnection(user2In, user2Out))
+	require.NoError(t, err)
+	user1In <- NewEventHello(clock.Real)
+	nextEvent[*EventHello](t, user1Out)
+	user2In <- NewEventHello(clock.Real)
+	nextEvent[*EventHello](t, user2Out)
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "client")
+	traceID := span.SpanContext().TraceID()
+	user1In <- WithTrace(&EventSendMessage{Message: "hi"}, ctx)
+	span.End()
+
+	m := nextEvent[*EventNewMessage](t, user2Out)
+	require.NotEmpty(t, EventTrace(m))
+	received := tracing.Extract(context.Background(), EventTrace(m))
+	assert.Equal(t, traceID, trace.SpanContextFromContext(received).TraceID())
+
+	// History is not traced, it is replayed in other traces.
+	for _, e := range hub.History(time.Time{}, time.Time{}) {
+		assert.Nil(t, EventTrace(e))
+	}
+
+	names := map[string]bool{}
+	for _, s := range recorder.Ended() {
+		if s.SpanContext().TraceID() == traceID {
+			names[s.Name()] = true
+		}
+	}
+	assert.True(t, names["hub.handleEvent"], "hub.handleEvent span")
+	assert.True(t, names["hub.pumpToSession"], "hub.pumpToSession span")
+}
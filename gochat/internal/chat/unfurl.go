@@ -0,0 +1,73 @@
+package chat
+
+import (
+	"context"
+	"sync"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/unfurl"
+)
+
+// unfurlSeenLimit is the number of message IDs the unfurl plugin
+// remembers to unfurl each message once.
+const unfurlSeenLimit = 1000
+
+// NewUnfurlPlugin sends EventMessageEnrichment for the URLs in new
+// messages. Previews are fetched in the background and broadcasted
+// when done, failures are only logged.
+func NewUnfurlPlugin(u *unfurl.Unfurler, logger log.Logger) *Plugin {
+	logger = logger.Named("unfurl")
+	var seenMu sync.Mutex
+	seen := map[string]bool{}
+	order := []string{}
+
+	// first returns true the first time it is called for the message ID.
+	first := func(id string) bool {
+		seenMu.Lock()
+		defer seenMu.Unlock()
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+		order = append(order, id)
+		if len(order) > unfurlSeenLimit {
+			delete(seen, order[0])
+			order = order[1:]
+		}
+		return true
+	}
+
+	return &Plugin{
+		// Outbound, as the message ID is set by the hub.
+		Outbound: func(next Handler) Handler {
+			return func(ctx *EventContext, e Event) error {
+				m, ok := e.(*EventNewMessage)
+				if !ok || m.ID == "" || ctx.Hub == nil {
+					return next(ctx, e)
+				}
+				urls := unfurl.FindURLs(m.Message)
+				if len(urls) == 0 || !first(m.ID) {
+					return next(ctx, e)
+				}
+				hub, id := ctx.Hub, m.ID
+				go func() {
+					for _, url := range urls {
+						preview, err := u.Unfurl(context.Background(), url)
+						if err != nil {
+							logger.Debugw("could not unfurl", "url", url, log.Error(err))
+							continue
+						}
+						hub.Broadcast(&EventMessageEnrichment{
+							EventMeta:   *NewEventMetaNow(hub.Clock()),
+							MessageID:   id,
+							URL:         preview.URL,
+							Title:       preview.Title,
+							Description: preview.Description,
+						})
+					}
+				}()
+				return next(ctx, e)
+			}
+		},
+	}
+}
@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/unfurl"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnfurlPlugin(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<title>Example</title><meta name="description" content="An example">`)
+	}))
+	t.Cleanup(server.Close)
+
+	hub := NewHub(test.NewTestLogger(true), HubOpts{})
+	t.Cleanup(func() { _ = hub.Close() })
+	u := unfurl.New(unfurl.Options{AllowPrivate: true})
+	hub.Use(NewUnfurlPlugin(u, test.NewTestLogger(true)))
+
+	user1In := make(chan Event)
+	user1Out := make(chan Event, 10)
+	user2In := make(chan Event)
+	user2Out := make(chan Event, 10)
+	_, err := hub.Connect("user1", NewTestConnection(user1In, user1Out))
+	require.NoError(t, err)
+	_, err = hub.Connect("user2", NewTestConnection(user2In, user2Out))
+	require.NoError(t, err)
+	user1In <- NewEventHello(clock.Real)
+	nextEvent[*EventHello](t, user1Out)
+	user2In <- NewEventHello(clock.Real)
+	nextEvent[*EventHello](t, user2Out)
+
+	user1In <- &EventSendMessage{Message: "see " + server.URL + "/page"}
+	m := nextEvent[*EventNewMessage](t, user1Out)
+	require.NotEmpty(t, m.ID)
+
+	for _, ch := range []chan Event{user1Out, user2Out} {
+		e := nextEvent[*EventMessageEnrichment](t, ch)
+		assert.Equal(t, m.ID, e.MessageID)
+		assert.Equal(t, server.URL+"/page", e.URL)
+		assert.Equal(t, "Example", e.Title)
+		assert.Equal(t, "An example", e.Description)
+	}
+	// Once per message, not per receiver.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,184 @@
+// Package config implements the client config file with named profiles
+// and the server config file.
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Transports supported by Profile.Transport.
+const (
+	TransportWebsocket = "websocket"
+	TransportGrpc      = "grpc"
+)
+
+// Profile is a named set of client options.
+// Empty values are not set by the profile.
+type Profile struct {
+	Host      string `yaml:"host,omitempty"`
+	Port      int    `yaml:"port,omitempty"`
+	Transport string `yaml:"transport,omitempty"`
+	Username  string `yaml:"username,omitempty"`
+	Token     string `yaml:"token,omitempty"`
+	TLS       bool   `yaml:"tls,omitempty"`
+	TLSCA     string `yaml:"tlsCa,omitempty"`
+	TLSCert   string `yaml:"tlsCert,omitempty"`
+	TLSKey    string `yaml:"tlsKey,omitempty"`
+}
+
+// Validate returns an error when the profile has invalid values.
+func (p *Profile) Validate() error {
+	switch p.Transport {
+	case "", TransportWebsocket, TransportGrpc:
+	default:
+		return fmt.Errorf(`invalid transport "%s"`, p.Transport)
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return fmt.Errorf("invalid port %d", p.Port)
+	}
+	if (p.TLSCert == "") != (p.TLSKey == "") {
+		return errors.New("tls cert and key must be set together")
+	}
+	return nil
+}
+
+// Config is the client config file.
+type Config struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile returns the profile by name.
+// Returns ErrProfileNotFound when there is no such profile.
+func (c *Config) Profile(name string) (Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return p, &ErrProfileNotFound{name: name}
+	}
+	return p, nil
+}
+
+// SetProfile adds or replaces the profile by name.
+func (c *Config) SetProfile(name string, p Profile) error {
+	if name == "" {
+		return errors.New("profile name can not be empty")
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]Profile{}
+	}
+	c.Profiles[name] = p
+	return nil
+}
+
+// RemoveProfile removes the profile by name.
+// Returns ErrProfileNotFound when there is no such profile.
+func (c *Config) RemoveProfile(name string) error {
+	if _, ok := c.Profiles[name]; !ok {
+		return &ErrProfileNotFound{name: name}
+	}
+	delete(c.Profiles, name)
+	return nil
+}
+
+// ProfileNames returns the sorted profile names.
+func (c *Config) ProfileNames() []string {
+	names := []string{}
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save writes the config to path, creating the directory when needed.
+// The file is only readable by the user as profiles can contain tokens.
+func (c *Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Load reads the config file at path.
+// Returns an empty config when the file does not exist.
+func Load(path string) (*Config, error) {
+	config := &Config{Profiles: map[string]Profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]Profile{}
+	}
+	for name, p := range config.Profiles {
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf(`invalid profile "%s" in %s: %w`, name, path, err)
+		}
+	}
+	return config, nil
+}
+
+// DefaultPath returns the path of the config file in the user config dir
+// ($XDG_CONFIG_HOME/gochat/config.yaml on Linux).
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gochat", "config.yaml"), nil
+}
+
+// ClientTLSConfig returns the TLS config for clients using the CA file
+// (system roots when empty) and the optional client certificate.
+func ClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// ErrProfileNotFound when the config has no profile by the name.
+type ErrProfileNotFound struct {
+	name string
+}
+
+func (e *ErrProfileNotFound) Error() string {
+	return fmt.Sprintf(`unknown profile "%s"`, e.name)
+}
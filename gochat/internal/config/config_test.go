@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	c, err := Load(filepath.Join(t.TempDir(), "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, c.ProfileNames())
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gochat", "config.yaml")
+	c := &Config{}
+	work := Profile{
+		Host:      "chat.example.com",
+		Port:      443,
+		Transport: TransportGrpc,
+		Username:  "Mario",
+		Token:     "secret",
+		TLS:       true,
+	}
+	require.NoError(t, c.SetProfile("work", work))
+	require.NoError(t, c.SetProfile("home", Profile{Username: "Luigi"}))
+	require.NoError(t, c.Save(path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"home", "work"}, loaded.ProfileNames())
+
+	p, err := loaded.Profile("work")
+	require.NoError(t, err)
+	assert.Equal(t, work, p)
+
+	require.NoError(t, loaded.RemoveProfile("work"))
+	_, err = loaded.Profile("work")
+	var notFound *ErrProfileNotFound
+	assert.ErrorAs(t, err, &notFound)
+	assert.ErrorAs(t, loaded.RemoveProfile("work"), &notFound)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "profiles: {work: {transport: carrier-pigeon}}"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	_, err := Load(path)
+	assert.Error(t, err)
+}
+
+func TestProfileValidate(t *testing.T) {
+	assert.NoError(t, (&Profile{}).Validate())
+	assert.Error(t, (&Profile{Transport: "udp"}).Validate())
+	assert.Error(t, (&Profile{Port: 70000}).Validate())
+	assert.Error(t, (&Profile{TLSCert: "cert.pem"}).Validate())
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"gopkg.in/yaml.v3"
+)
+
+// ServerConfig is the server config file.
+type ServerConfig struct {
+	// Retention purges history by policy, disabled when not set.
+	Retention *chat.RetentionConfig `yaml:"retention,omitempty"`
+}
+
+// LoadServer reads the server config file at path.
+// Returns an empty config when the file does not exist.
+func LoadServer(path string) (*ServerConfig, error) {
+	config := &ServerConfig{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	if config.Retention != nil {
+		if err := config.Retention.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid retention in %s: %w", path, err)
+		}
+	}
+	return config, nil
+}
+
+// DefaultServerPath returns the path of the server config file in the
+// user config dir ($XDG_CONFIG_HOME/gochat/server.yaml on Linux).
+func DefaultServerPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gochat", "server.yaml"), nil
+}
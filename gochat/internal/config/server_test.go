@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadServer(t *testing.T) {
+	c, err := LoadServer(filepath.Join(t.TempDir(), "server.yaml"))
+	require.NoError(t, err)
+	assert.Nil(t, c.Retention)
+
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+retention:
+  default:
+    days: 30
+  rooms:
+    main:
+      days: 90
+      messages: 5000
+  legalHolds:
+    - name: case-42
+      senders: [mallory]
+  interval: 15m
+`), 0o600))
+	c, err = LoadServer(path)
+	require.NoError(t, err)
+	assert.Equal(t, &chat.RetentionConfig{
+		Default: chat.RetentionPolicy{Days: 30},
+		Rooms: map[string]chat.RetentionPolicy{
+			"main": {Days: 90, Messages: 5000},
+		},
+		LegalHolds: []chat.LegalHold{{Name: "case-42", Senders: []string{"mallory"}}},
+		Interval:   15 * time.Minute,
+	}, c.Retention)
+
+	require.NoError(t, os.WriteFile(path, []byte("retention:\n  default:\n    days: -1\n"), 0o600))
+	_, err = LoadServer(path)
+	assert.ErrorContains(t, err, "negative retention")
+}
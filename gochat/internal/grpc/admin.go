@@ -0,0 +1,372 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type AdminService struct {
+	pb.UnimplementedAdminServer
+	logger log.Logger
+	hub    *chat.Hub
+	token  string
+}
+
+func (a *AdminService) Export(
+	ctx context.Context,
+	req *pb.HistoryRequest,
+) (*pb.History, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	r := chat.HistoryRequestFromProto(req)
+	a.logger.Infow("admin export", "from", r.From, "to", r.To)
+	return chat.HistoryToProto(&chat.EventHistory{
+		EventMeta: *chat.NewEventMetaNow(a.hub.Clock()),
+		RequestID: r.ID,
+		Events:    a.hub.History(r.From, r.To),
+	}), nil
+}
+
+func (a *AdminService) Rooms(
+	ctx context.Context,
+	req *pb.RoomsRequest,
+) (*pb.Rooms, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return chat.RoomsToProto(a.hub.Rooms()), nil
+}
+
+func (a *AdminService) Sessions(
+	ctx context.Context,
+	req *pb.SessionsRequest,
+) (*pb.Sessions, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return chat.SessionsToProto(a.hub.Sessions()), nil
+}
+
+func (a *AdminService) Kick(
+	ctx context.Context,
+	req *pb.KickRequest,
+) (*pb.KickResponse, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	a.logger.Infow("admin kick", "username", req.Username, "reason", req.Reason)
+	n, err := a.hub.Kick(req.Username, req.Reason)
+	if err != nil {
+		return nil, adminError(err)
+	}
+	return &pb.KickResponse{Sessions: int32(n)}, nil
+}
+
+func (a *AdminService) Notice(
+	ctx context.Context,
+	req *pb.NoticeRequest,
+) (*pb.NoticeResponse, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if req.Message == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty message")
+	}
+	a.logger.Infow("admin notice", "message", req.Message)
+	a.hub.Notice(req.Message)
+	return &pb.NoticeResponse{}, nil
+}
+
+func (a *AdminService) Members(
+	ctx context.Context,
+	req *pb.MembersRequest,
+) (*pb.Members, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	members, err := a.hub.Members()
+	if err != nil {
+		return nil, adminError(err)
+	}
+	return chat.MembersToProto(members), nil
+}
+
+func (a *AdminService) SetRole(
+	ctx context.Context,
+	req *pb.SetRoleRequest,
+) (*pb.SetRoleResponse, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	a.logger.Infow("admin set role", "username", req.Username, "role", req.Role)
+	if err := a.hub.SetRole(req.Username, chat.Role(req.Role)); err != nil {
+		return nil, adminError(err)
+	}
+	return &pb.SetRoleResponse{}, nil
+}
+
+func (a *AdminService) Ban(
+	ctx context.Context,
+	req *pb.BanRequest,
+) (*pb.BanResponse, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	var until time.Time
+	if req.Until != nil {
+		until = req.Until.AsTime()
+	}
+	a.logger.Infow("admin ban", "username", req.Username, "reason", req.Reason, "until", until)
+	if err := a.hub.Ban(req.Username, req.Reason, until); err != nil {
+		return nil, adminError(err)
+	}
+	return &pb.BanResponse{}, nil
+}
+
+func (a *AdminService) Unban(
+	ctx context.Context,
+	req *pb.UnbanRequest,
+) (*pb.UnbanResponse, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	a.logger.Infow("admin unban", "username", req.Username)
+	if err := a.hub.Unban(req.Username); err != nil {
+		return nil, adminError(err)
+	}
+	return &pb.UnbanResponse{}, nil
+}
+
+func (a *AdminService) Bans(
+	ctx context.Context,
+	req *pb.BansRequest,
+) (*pb.Bans, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	bans, err := a.hub.Bans()
+	if err != nil {
+		return nil, adminError(err)
+	}
+	return chat.BansToProto(bans), nil
+}
+
+func (a *AdminService) Stats(
+	ctx context.Context,
+	req *pb.StatsRequest,
+) (*pb.Stats, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return chat.StatsToProto(a.hub.Stats()), nil
+}
+
+func (a *AdminService) Retention(
+	ctx context.Context,
+	req *pb.RetentionRequest,
+) (*pb.RetentionReport, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return chat.RetentionReportToProto(a.hub.PurgeHistory(true)), nil
+}
+
+// adminError returns the status error for errors of the hub.
+func adminError(err error) error {
+	var notFound *chat.ErrUserNotFound
+	var notBanned *chat.ErrNotBanned
+	var notAuthenticated *chat.ErrNotAuthenticated
+	var invalidRole *chat.ErrInvalidRole
+	switch {
+	case errors.As(err, &notFound), errors.As(err, &notBanned):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.As(err, &notAuthenticated), errors.As(err, &invalidRole):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Unknown, err.Error())
+	}
+}
+
+func (a *AdminService) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	authorization := md.Get("authorization")
+	if len(authorization) == 0 || !chat.AdminAuthorized(authorization[0], a.token) {
+		return status.Error(codes.Unauthenticated, "invalid admin token")
+	}
+	return nil
+}
+
+// AdminOpts are the options for Export and NewAdminClient.
+type AdminOpts struct {
+	// Token is the admin token of the server.
+	Token string
+	// TLSConfig enables TLS when set.
+	TLSConfig *tls.Config
+}
+
+// Export returns the room history of the server from (inclusive) to
+// (exclusive). Zero times are unbounded.
+func Export(
+	serverAddr string,
+	from time.Time,
+	to time.Time,
+	opts AdminOpts,
+) ([]chat.Event, error) {
+	c, err := NewAdminClient(serverAddr, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	history, err := c.client.Export(
+		c.context(),
+		chat.HistoryRequestToProto(&chat.EventHistoryRequest{
+			EventMeta: *chat.NewEventMetaNow(clock.Real),
+			From:      from,
+			To:        to,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return chat.HistoryFromProto(history).Events, nil
+}
+
+// AdminClient is the client of the admin service.
+type AdminClient struct {
+	conn   *grpc.ClientConn
+	client pb.AdminClient
+	token  string
+}
+
+// NewAdminClient returns a client for the admin service of the server.
+func NewAdminClient(serverAddr string, opts AdminOpts) (*AdminClient, error) {
+	creds := insecure.NewCredentials()
+	if opts.TLSConfig != nil {
+		creds = credentials.NewTLS(opts.TLSConfig)
+	}
+	conn, err := grpc.Dial(
+		serverAddr,
+		grpc.WithTransportCredentials(creds),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminClient{
+		conn:   conn,
+		client: pb.NewAdminClient(conn),
+		token:  opts.Token,
+	}, nil
+}
+
+// context returns the context with the admin token.
+func (c *AdminClient) context() context.Context {
+	header := metadata.New(map[string]string{
+		"authorization": "Bearer " + c.token,
+	})
+	return metadata.NewOutgoingContext(context.Background(), header)
+}
+
+func (c *AdminClient) Rooms() ([]chat.RoomInfo, error) {
+	rooms, err := c.client.Rooms(c.context(), &pb.RoomsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return chat.RoomsFromProto(rooms), nil
+}
+
+func (c *AdminClient) Sessions() ([]chat.SessionInfo, error) {
+	sessions, err := c.client.Sessions(c.context(), &pb.SessionsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return chat.SessionsFromProto(sessions), nil
+}
+
+func (c *AdminClient) Kick(username string, reason string) (int, error) {
+	resp, err := c.client.Kick(c.context(), &pb.KickRequest{
+		Username: username,
+		Reason:   reason,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.Sessions), nil
+}
+
+func (c *AdminClient) Notice(message string) error {
+	_, err := c.client.Notice(c.context(), &pb.NoticeRequest{Message: message})
+	return err
+}
+
+func (c *AdminClient) Members() ([]chat.Membership, error) {
+	members, err := c.client.Members(c.context(), &pb.MembersRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return chat.MembersFromProto(members), nil
+}
+
+func (c *AdminClient) SetRole(username string, role chat.Role) error {
+	_, err := c.client.SetRole(c.context(), &pb.SetRoleRequest{
+		Username: username,
+		Role:     string(role),
+	})
+	return err
+}
+
+func (c *AdminClient) Ban(username string, reason string, until time.Time) error {
+	req := &pb.BanRequest{Username: username, Reason: reason}
+	if !until.IsZero() {
+		req.Until = timestamppb.New(until)
+	}
+	_, err := c.client.Ban(c.context(), req)
+	return err
+}
+
+func (c *AdminClient) Unban(username string) error {
+	_, err := c.client.Unban(c.context(), &pb.UnbanRequest{Username: username})
+	return err
+}
+
+func (c *AdminClient) Bans() ([]chat.Ban, error) {
+	bans, err := c.client.Bans(c.context(), &pb.BansRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return chat.BansFromProto(bans), nil
+}
+
+func (c *AdminClient) Stats() (chat.HubStats, error) {
+	stats, err := c.client.Stats(c.context(), &pb.StatsRequest{})
+	if err != nil {
+		return chat.HubStats{}, err
+	}
+	return chat.StatsFromProto(stats), nil
+}
+
+func (c *AdminClient) Retention() (chat.RetentionReport, error) {
+	report, err := c.client.Retention(c.context(), &pb.RetentionRequest{})
+	if err != nil {
+		return chat.RetentionReport{}, err
+	}
+	return chat.RetentionReportFromProto(report), nil
+}
+
+func (c *AdminClient) Close() error {
+	return c.conn.Close()
+}
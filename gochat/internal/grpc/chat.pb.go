@@ -1,733 +0,0 @@
-// Code generated by protoc-gen-go. DO NOT EDIT.
-// versions:
-// 	protoc-gen-go v1.28.0
-// 	protoc        v3.19.4
-// source: internal/grpc/chat.proto
-
-package grpc
-
-import (
-	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
-	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
-	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
-	reflect "reflect"
-	sync "sync"
-)
-
-const (
-	// Verify that this generated code is sufficiently up-to-date.
-	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
-	// Verify that runtime/protoimpl is sufficiently up-to-date.
-	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
-)
-
-type Connected struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Time  *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
-	Users []string               `protobuf:"bytes,2,rep,name=users,proto3" json:"users,omitempty"`
-}
-
-func (x *Connected) Reset() {
-	*x = Connected{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_internal_grpc_chat_proto_msgTypes[0]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *Connected) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*Connected) ProtoMessage() {}
-
-func (x *Connected) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_grpc_chat_proto_msgTypes[0]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use Connected.ProtoReflect.Descriptor instead.
-func (*Connected) Descriptor() ([]byte, []int) {
-	return file_internal_grpc_chat_proto_rawDescGZIP(), []int{0}
-}
-
-func (x *Connected) GetTime() *timestamppb.Timestamp {
-	if x != nil {
-		return x.Time
-	}
-	return nil
-}
-
-func (x *Connected) GetUsers() []string {
-	if x != nil {
-		return x.Users
-	}
-	return nil
-}
-
-type UserListUpdate struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Time  *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
-	Users []string               `protobuf:"bytes,2,rep,name=users,proto3" json:"users,omitempty"`
-}
-
-func (x *UserListUpdate) Reset() {
-	*x = UserListUpdate{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_internal_grpc_chat_proto_msgTypes[1]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *UserListUpdate) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*UserListUpdate) ProtoMessage() {}
-
-func (x *UserListUpdate) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_grpc_chat_proto_msgTypes[1]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use UserListUpdate.ProtoReflect.Descriptor instead.
-func (*UserListUpdate) Descriptor() ([]byte, []int) {
-	return file_internal_grpc_chat_proto_rawDescGZIP(), []int{1}
-}
-
-func (x *UserListUpdate) GetTime() *timestamppb.Timestamp {
-	if x != nil {
-		return x.Time
-	}
-	return nil
-}
-
-func (x *UserListUpdate) GetUsers() []string {
-	if x != nil {
-		return x.Users
-	}
-	return nil
-}
-
-type UserEnter struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Time *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
-	Name string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-}
-
-func (x *UserEnter) Reset() {
-	*x = UserEnter{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_internal_grpc_chat_proto_msgTypes[2]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *UserEnter) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*UserEnter) ProtoMessage() {}
-
-func (x *UserEnter) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_grpc_chat_proto_msgTypes[2]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use UserEnter.ProtoReflect.Descriptor instead.
-func (*UserEnter) Descriptor() ([]byte, []int) {
-	return file_internal_grpc_chat_proto_rawDescGZIP(), []int{2}
-}
-
-func (x *UserEnter) GetTime() *timestamppb.Timestamp {
-	if x != nil {
-		return x.Time
-	}
-	return nil
-}
-
-func (x *UserEnter) GetName() string {
-	if x != nil {
-		return x.Name
-	}
-	return ""
-}
-
-type UserLeave struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Time *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
-	Name string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-}
-
-func (x *UserLeave) Reset() {
-	*x = UserLeave{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_internal_grpc_chat_proto_msgTypes[3]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *UserLeave) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*UserLeave) ProtoMessage() {}
-
-func (x *UserLeave) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_grpc_chat_proto_msgTypes[3]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use UserLeave.ProtoReflect.Descriptor instead.
-func (*UserLeave) Descriptor() ([]byte, []int) {
-	return file_internal_grpc_chat_proto_rawDescGZIP(), []int{3}
-}
-
-func (x *UserLeave) GetTime() *timestamppb.Timestamp {
-	if x != nil {
-		return x.Time
-	}
-	return nil
-}
-
-func (x *UserLeave) GetName() string {
-	if x != nil {
-		return x.Name
-	}
-	return ""
-}
-
-type SendMessage struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Time    *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
-	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
-}
-
-func (x *SendMessage) Reset() {
-	*x = SendMessage{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_internal_grpc_chat_proto_msgTypes[4]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *SendMessage) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*SendMessage) ProtoMessage() {}
-
-func (x *SendMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_grpc_chat_proto_msgTypes[4]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use SendMessage.ProtoReflect.Descriptor instead.
-func (*SendMessage) Descriptor() ([]byte, []int) {
-	return file_internal_grpc_chat_proto_rawDescGZIP(), []int{4}
-}
-
-func (x *SendMessage) GetTime() *timestamppb.Timestamp {
-	if x != nil {
-		return x.Time
-	}
-	return nil
-}
-
-func (x *SendMessage) GetMessage() string {
-	if x != nil {
-		return x.Message
-	}
-	return ""
-}
-
-type NewMessage struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Time    *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
-	Sender  string                 `protobuf:"bytes,2,opt,name=sender,proto3" json:"sender,omitempty"`
-	Message string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
-}
-
-func (x *NewMessage) Reset() {
-	*x = NewMessage{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_internal_grpc_chat_proto_msgTypes[5]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *NewMessage) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*NewMessage) ProtoMessage() {}
-
-func (x *NewMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_grpc_chat_proto_msgTypes[5]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use NewMessage.ProtoReflect.Descriptor instead.
-func (*NewMessage) Descriptor() ([]byte, []int) {
-	return file_internal_grpc_chat_proto_rawDescGZIP(), []int{5}
-}
-
-func (x *NewMessage) GetTime() *timestamppb.Timestamp {
-	if x != nil {
-		return x.Time
-	}
-	return nil
-}
-
-func (x *NewMessage) GetSender() string {
-	if x != nil {
-		return x.Sender
-	}
-	return ""
-}
-
-func (x *NewMessage) GetMessage() string {
-	if x != nil {
-		return x.Message
-	}
-	return ""
-}
-
-type EventEnvelope struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	// Types that are assignable to Event:
-	//	*EventEnvelope_Connected
-	//	*EventEnvelope_UserListUpdate
-	//	*EventEnvelope_UserEnter
-	//	*EventEnvelope_UserLeave
-	//	*EventEnvelope_SendMessage
-	//	*EventEnvelope_NewMessage
-	Event isEventEnvelope_Event `protobuf_oneof:"event"`
-}
-
-func (x *EventEnvelope) Reset() {
-	*x = EventEnvelope{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_internal_grpc_chat_proto_msgTypes[6]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *EventEnvelope) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*EventEnvelope) ProtoMessage() {}
-
-func (x *EventEnvelope) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_grpc_chat_proto_msgTypes[6]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use EventEnvelope.ProtoReflect.Descriptor instead.
-func (*EventEnvelope) Descriptor() ([]byte, []int) {
-	return file_internal_grpc_chat_proto_rawDescGZIP(), []int{6}
-}
-
-func (m *EventEnvelope) GetEvent() isEventEnvelope_Event {
-	if m != nil {
-		return m.Event
-	}
-	return nil
-}
-
-func (x *EventEnvelope) GetConnected() *Connected {
-	if x, ok := x.GetEvent().(*EventEnvelope_Connected); ok {
-		return x.Connected
-	}
-	return nil
-}
-
-func (x *EventEnvelope) GetUserListUpdate() *UserListUpdate {
-	if x, ok := x.GetEvent().(*EventEnvelope_UserListUpdate); ok {
-		return x.UserListUpdate
-	}
-	return nil
-}
-
-func (x *EventEnvelope) GetUserEnter() *UserEnter {
-	if x, ok := x.GetEvent().(*EventEnvelope_UserEnter); ok {
-		return x.UserEnter
-	}
-	return nil
-}
-
-func (x *EventEnvelope) GetUserLeave() *UserLeave {
-	if x, ok := x.GetEvent().(*EventEnvelope_UserLeave); ok {
-		return x.UserLeave
-	}
-	return nil
-}
-
-func (x *EventEnvelope) GetSendMessage() *SendMessage {
-	if x, ok := x.GetEvent().(*EventEnvelope_SendMessage); ok {
-		return x.SendMessage
-	}
-	return nil
-}
-
-func (x *EventEnvelope) GetNewMessage() *NewMessage {
-	if x, ok := x.GetEvent().(*EventEnvelope_NewMessage); ok {
-		return x.NewMessage
-	}
-	return nil
-}
-
-type isEventEnvelope_Event interface {
-	isEventEnvelope_Event()
-}
-
-type EventEnvelope_Connected struct {
-	Connected *Connected `protobuf:"bytes,2,opt,name=connected,proto3,oneof"`
-}
-
-type EventEnvelope_UserListUpdate struct {
-	UserListUpdate *UserListUpdate `protobuf:"bytes,3,opt,name=userListUpdate,proto3,oneof"`
-}
-
-type EventEnvelope_UserEnter struct {
-	UserEnter *UserEnter `protobuf:"bytes,4,opt,name=userEnter,proto3,oneof"`
-}
-
-type EventEnvelope_UserLeave struct {
-	UserLeave *UserLeave `protobuf:"bytes,5,opt,name=userLeave,proto3,oneof"`
-}
-
-type EventEnvelope_SendMessage struct {
-	SendMessage *SendMessage `protobuf:"bytes,6,opt,name=sendMessage,proto3,oneof"`
-}
-
-type EventEnvelope_NewMessage struct {
-	NewMessage *NewMessage `protobuf:"bytes,7,opt,name=newMessage,proto3,oneof"`
-}
-
-func (*EventEnvelope_Connected) isEventEnvelope_Event() {}
-
-func (*EventEnvelope_UserListUpdate) isEventEnvelope_Event() {}
-
-func (*EventEnvelope_UserEnter) isEventEnvelope_Event() {}
-
-func (*EventEnvelope_UserLeave) isEventEnvelope_Event() {}
-
-func (*EventEnvelope_SendMessage) isEventEnvelope_Event() {}
-
-func (*EventEnvelope_NewMessage) isEventEnvelope_Event() {}
-
-var File_internal_grpc_chat_proto protoreflect.FileDescriptor
-
-var file_internal_grpc_chat_proto_rawDesc = []byte{
-	0x0a, 0x18, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x2f,
-	0x63, 0x68, 0x61, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x04, 0x63, 0x68, 0x61, 0x74,
-	0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
-	0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x22, 0x51, 0x0a, 0x09, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x65, 0x64, 0x12, 0x2e,
-	0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
-	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
-	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x14,
-	0x0a, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x75,
-	0x73, 0x65, 0x72, 0x73, 0x22, 0x56, 0x0a, 0x0e, 0x55, 0x73, 0x65, 0x72, 0x4c, 0x69, 0x73, 0x74,
-	0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
-	0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x18,
-	0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x22, 0x4f, 0x0a, 0x09,
-	0x55, 0x73, 0x65, 0x72, 0x45, 0x6e, 0x74, 0x65, 0x72, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d,
-	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
-	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74,
-	0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
-	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x4f, 0x0a,
-	0x09, 0x55, 0x73, 0x65, 0x72, 0x4c, 0x65, 0x61, 0x76, 0x65, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69,
-	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
-	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73,
-	0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
-	0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x57,
-	0x0a, 0x0b, 0x53, 0x65, 0x6e, 0x64, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2e, 0x0a,
-	0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
-	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
-	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x18, 0x0a,
-	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
-	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x6e, 0x0a, 0x0a, 0x4e, 0x65, 0x77, 0x4d, 0x65,
-	0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
-	0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x18, 0x0a,
-	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
-	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0xd6, 0x02, 0x0a, 0x0d, 0x45, 0x76, 0x65, 0x6e,
-	0x74, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x12, 0x2f, 0x0a, 0x09, 0x63, 0x6f, 0x6e,
-	0x6e, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x63,
-	0x68, 0x61, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x65, 0x64, 0x48, 0x00, 0x52,
-	0x09, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x65, 0x64, 0x12, 0x3e, 0x0a, 0x0e, 0x75, 0x73,
-	0x65, 0x72, 0x4c, 0x69, 0x73, 0x74, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x14, 0x2e, 0x63, 0x68, 0x61, 0x74, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x4c, 0x69,
-	0x73, 0x74, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x0e, 0x75, 0x73, 0x65, 0x72,
-	0x4c, 0x69, 0x73, 0x74, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x2f, 0x0a, 0x09, 0x75, 0x73,
-	0x65, 0x72, 0x45, 0x6e, 0x74, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e,
-	0x63, 0x68, 0x61, 0x74, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x45, 0x6e, 0x74, 0x65, 0x72, 0x48, 0x00,
-	0x52, 0x09, 0x75, 0x73, 0x65, 0x72, 0x45, 0x6e, 0x74, 0x65, 0x72, 0x12, 0x2f, 0x0a, 0x09, 0x75,
-	0x73, 0x65, 0x72, 0x4c, 0x65, 0x61, 0x76, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f,
-	0x2e, 0x63, 0x68, 0x61, 0x74, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x4c, 0x65, 0x61, 0x76, 0x65, 0x48,
-	0x00, 0x52, 0x09, 0x75, 0x73, 0x65, 0x72, 0x4c, 0x65, 0x61, 0x76, 0x65, 0x12, 0x35, 0x0a, 0x0b,
-	0x73, 0x65, 0x6e, 0x64, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x11, 0x2e, 0x63, 0x68, 0x61, 0x74, 0x2e, 0x53, 0x65, 0x6e, 0x64, 0x4d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x48, 0x00, 0x52, 0x0b, 0x73, 0x65, 0x6e, 0x64, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x12, 0x32, 0x0a, 0x0a, 0x6e, 0x65, 0x77, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
-	0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x63, 0x68, 0x61, 0x74, 0x2e, 0x4e,
-	0x65, 0x77, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x48, 0x00, 0x52, 0x0a, 0x6e, 0x65, 0x77,
-	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74,
-	0x32, 0x3b, 0x0a, 0x03, 0x48, 0x75, 0x62, 0x12, 0x34, 0x0a, 0x04, 0x43, 0x68, 0x61, 0x74, 0x12,
-	0x13, 0x2e, 0x63, 0x68, 0x61, 0x74, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x45, 0x6e, 0x76, 0x65,
-	0x6c, 0x6f, 0x70, 0x65, 0x1a, 0x13, 0x2e, 0x63, 0x68, 0x61, 0x74, 0x2e, 0x45, 0x76, 0x65, 0x6e,
-	0x74, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x28, 0x01, 0x30, 0x01, 0x42, 0x42, 0x5a,
-	0x40, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x61, 0x72, 0x63,
-	0x65, 0x6c, 0x62, 0x65, 0x75, 0x6d, 0x65, 0x72, 0x2f, 0x63, 0x72, 0x69, 0x73, 0x70, 0x79, 0x2d,
-	0x6f, 0x63, 0x74, 0x6f, 0x2d, 0x67, 0x6f, 0x67, 0x67, 0x6c, 0x65, 0x73, 0x2f, 0x67, 0x6f, 0x63,
-	0x68, 0x61, 0x74, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x67, 0x72, 0x70,
-	0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
-}
-
-var (
-	file_internal_grpc_chat_proto_rawDescOnce sync.Once
-	file_internal_grpc_chat_proto_rawDescData = file_internal_grpc_chat_proto_rawDesc
-)
-
-func file_internal_grpc_chat_proto_rawDescGZIP() []byte {
-	file_internal_grpc_chat_proto_rawDescOnce.Do(func() {
-		file_internal_grpc_chat_proto_rawDescData = protoimpl.X.CompressGZIP(file_internal_grpc_chat_proto_rawDescData)
-	})
-	return file_internal_grpc_chat_proto_rawDescData
-}
-
-var file_internal_grpc_chat_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
-var file_internal_grpc_chat_proto_goTypes = []interface{}{
-	(*Connected)(nil),             // 0: chat.Connected
-	(*UserListUpdate)(nil),        // 1: chat.UserListUpdate
-	(*UserEnter)(nil),             // 2: chat.UserEnter
-	(*UserLeave)(nil),             // 3: chat.UserLeave
-	(*SendMessage)(nil),           // 4: chat.SendMessage
-	(*NewMessage)(nil),            // 5: chat.NewMessage
-	(*EventEnvelope)(nil),         // 6: chat.EventEnvelope
-	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
-}
-var file_internal_grpc_chat_proto_depIdxs = []int32{
-	7,  // 0: chat.Connected.time:type_name -> google.protobuf.Timestamp
-	7,  // 1: chat.UserListUpdate.time:type_name -> google.protobuf.Timestamp
-	7,  // 2: chat.UserEnter.time:type_name -> google.protobuf.Timestamp
-	7,  // 3: chat.UserLeave.time:type_name -> google.protobuf.Timestamp
-	7,  // 4: chat.SendMessage.time:type_name -> google.protobuf.Timestamp
-	7,  // 5: chat.NewMessage.time:type_name -> google.protobuf.Timestamp
-	0,  // 6: chat.EventEnvelope.connected:type_name -> chat.Connected
-	1,  // 7: chat.EventEnvelope.userListUpdate:type_name -> chat.UserListUpdate
-	2,  // 8: chat.EventEnvelope.userEnter:type_name -> chat.UserEnter
-	3,  // 9: chat.EventEnvelope.userLeave:type_name -> chat.UserLeave
-	4,  // 10: chat.EventEnvelope.sendMessage:type_name -> chat.SendMessage
-	5,  // 11: chat.EventEnvelope.newMessage:type_name -> chat.NewMessage
-	6,  // 12: chat.Hub.Chat:input_type -> chat.EventEnvelope
-	6,  // 13: chat.Hub.Chat:output_type -> chat.EventEnvelope
-	13, // [13:14] is the sub-list for method output_type
-	12, // [12:13] is the sub-list for method input_type
-	12, // [12:12] is the sub-list for extension type_name
-	12, // [12:12] is the sub-list for extension extendee
-	0,  // [0:12] is the sub-list for field type_name
-}
-
-func init() { file_internal_grpc_chat_proto_init() }
-func file_internal_grpc_chat_proto_init() {
-	if File_internal_grpc_chat_proto != nil {
-		return
-	}
-	if !protoimpl.UnsafeEnabled {
-		file_internal_grpc_chat_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Connected); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_internal_grpc_chat_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*UserListUpdate); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_internal_grpc_chat_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*UserEnter); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_internal_grpc_chat_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*UserLeave); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_internal_grpc_chat_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SendMessage); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_internal_grpc_chat_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*NewMessage); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_internal_grpc_chat_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*EventEnvelope); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-	}
-	file_internal_grpc_chat_proto_msgTypes[6].OneofWrappers = []interface{}{
-		(*EventEnvelope_Connected)(nil),
-		(*EventEnvelope_UserListUpdate)(nil),
-		(*EventEnvelope_UserEnter)(nil),
-		(*EventEnvelope_UserLeave)(nil),
-		(*EventEnvelope_SendMessage)(nil),
-		(*EventEnvelope_NewMessage)(nil),
-	}
-	type x struct{}
-	out := protoimpl.TypeBuilder{
-		File: protoimpl.DescBuilder{
-			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
-			RawDescriptor: file_internal_grpc_chat_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   7,
-			NumExtensions: 0,
-			NumServices:   1,
-		},
-		GoTypes:           file_internal_grpc_chat_proto_goTypes,
-		DependencyIndexes: file_internal_grpc_chat_proto_depIdxs,
-		MessageInfos:      file_internal_grpc_chat_proto_msgTypes,
-	}.Build()
-	File_internal_grpc_chat_proto = out.File
-	file_internal_grpc_chat_proto_rawDesc = nil
-	file_internal_grpc_chat_proto_goTypes = nil
-	file_internal_grpc_chat_proto_depIdxs = nil
-}
@@ -2,32 +2,78 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
 
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"github.com/marcelbeumer/go-playground/gochat/internal/tracing"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 )
 
+// ClientOpts are the options for NewClientConnection.
+type ClientOpts struct {
+	// Token is sent as bearer token in the authorization metadata, optional.
+	Token string
+	// TLSConfig enables TLS when set.
+	TLSConfig *tls.Config
+	// Keepalive configures pings, DefaultKeepalive when zero.
+	Keepalive KeepaliveOpts
+}
+
 func NewClientConnection(
 	serverAddr string,
 	username string,
 	logger log.Logger,
+	opts ClientOpts,
 ) (*Connection, error) {
 	logger.Infow("connecting to server", "serverUrl", serverAddr)
+	creds := insecure.NewCredentials()
+	if opts.TLSConfig != nil {
+		creds = credentials.NewTLS(opts.TLSConfig)
+	}
 	conn, err := grpc.Dial(
 		serverAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
+		opts.Keepalive.dialOption(),
 	)
 	if err != nil {
 		return nil, err
 	}
-	client := NewHubClient(conn)
+	client := pb.NewHubClient(conn)
 	header := metadata.New(map[string]string{"username": username})
-	ctx := metadata.NewOutgoingContext(context.Background(), header)
+	if opts.Token != "" {
+		header.Set("authorization", "Bearer "+opts.Token)
+	}
+	connectCtx, span := tracing.Tracer().Start(context.Background(), "grpc.connect",
+		trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	for k, v := range tracing.Inject(connectCtx) {
+		header.Set(k, v)
+	}
+	ctx, cancel := context.WithCancel(
+		metadata.NewOutgoingContext(context.Background(), header))
 	cc, err := client.Chat(ctx)
 	if err != nil {
+		cancel()
+		_ = conn.Close()
+		return nil, err
+	}
+	c := NewConnection(cc, logger)
+	go func() {
+		// End the stream, so the server sees the client leave.
+		_ = c.Wait()
+		cancel()
+		_ = conn.Close()
+	}()
+	if err := c.SendEvent(chat.NewEventHello(clock.Real)); err != nil {
+		_ = c.Close(err)
 		return nil, err
 	}
-	return NewConnection(cc, logger), nil
+	return c, nil
 }
@@ -8,15 +8,20 @@ import (
 
 	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
-	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 type GrpcConnection interface {
-	Send(*EventEnvelope) error
-	Recv() (*EventEnvelope, error)
+	Send(*pb.EventEnvelope) error
+	Recv() (*pb.EventEnvelope, error)
 }
 
 type Connection struct {
+	logger     log.Logger
 	eventOutCh chan chat.Event
 	closed     chan struct{}
 	error      error
@@ -33,71 +38,24 @@ func (c *Connection) SendEvent(e chat.Event) error {
 	default:
 	}
 
-	typeStr := reflect.TypeOf(e).String()
-	envelope := EventEnvelope{}
-	time := timestamppb.New(e.When())
-
-	switch t := e.(type) {
-	case *chat.EventConnected:
-		envelope.Event = &EventEnvelope_Connected{
-			Connected: &Connected{
-				Time:  time,
-				Users: t.Users,
-			},
-		}
-
-	case *chat.EventUserListUpdate:
-		envelope.Event = &EventEnvelope_UserListUpdate{
-			UserListUpdate: &UserListUpdate{
-				Time:  time,
-				Users: t.Users,
-			},
-		}
-
-	case *chat.EventUserEnter:
-		envelope.Event = &EventEnvelope_UserEnter{
-			UserEnter: &UserEnter{
-				Time: time,
-				Name: t.Name,
-			},
-		}
-
-	case *chat.EventUserLeave:
-		envelope.Event = &EventEnvelope_UserLeave{
-			UserLeave: &UserLeave{
-				Time: time,
-				Name: t.Name,
-			},
-		}
-
-	case *chat.EventSendMessage:
-		envelope.Event = &EventEnvelope_SendMessage{
-			SendMessage: &SendMessage{
-				Time:    time,
-				Message: t.Message,
-			},
-		}
-
-	case *chat.EventNewMessage:
-		envelope.Event = &EventEnvelope_NewMessage{
-			NewMessage: &NewMessage{
-				Time:    time,
-				Message: t.Message,
-				Sender:  t.Sender,
-			},
-		}
-
-	default:
-		return fmt.Errorf("unknown event type <%s>", typeStr)
+	if chat.EventTrace(e) != nil {
+		ctx, span := chat.StartEventSpan(e, "grpc.send",
+			trace.WithSpanKind(trace.SpanKindProducer))
+		defer span.End()
+		e = chat.WithTrace(e, ctx)
 	}
 
-	err := c.grpcConn.Send(&envelope)
+	envelope, err := chat.Events.ToProto(e)
 	if err != nil {
+		return err
+	}
+
+	if err := c.grpcConn.Send(envelope); err != nil {
 		return fmt.Errorf(
 			"error writing event to grpc with type <%s>: %w",
-			typeStr, err)
+			reflect.TypeOf(e).String(), err)
 	}
-	return err
+	return nil
 }
 
 // ReadEvent wiats for next Event. Error when reading fails.
@@ -164,62 +122,41 @@ func (h *Connection) grpcReadPump() error {
 			return err
 		}
 
-		var e chat.Event
-
-		switch t := envelope.Event.(type) {
-		case *EventEnvelope_Connected:
-			meta := chat.EventMeta{Time: t.Connected.Time.AsTime()}
-			e = &chat.EventConnected{
-				EventMeta: meta,
-				Users:     t.Connected.Users,
-			}
-
-		case *EventEnvelope_UserListUpdate:
-			meta := chat.EventMeta{Time: t.UserListUpdate.Time.AsTime()}
-			e = &chat.EventUserListUpdate{
-				EventMeta: meta,
-				Users:     t.UserListUpdate.Users,
-			}
-
-		case *EventEnvelope_UserEnter:
-			meta := chat.EventMeta{Time: t.UserEnter.Time.AsTime()}
-			e = &chat.EventUserEnter{
-				EventMeta: meta,
-				Name:      t.UserEnter.Name,
-			}
-
-		case *EventEnvelope_UserLeave:
-			meta := chat.EventMeta{Time: t.UserLeave.Time.AsTime()}
-			e = &chat.EventUserEnter{
-				EventMeta: meta,
-				Name:      t.UserLeave.Name,
-			}
-
-		case *EventEnvelope_SendMessage:
-			meta := chat.EventMeta{Time: t.SendMessage.Time.AsTime()}
-			e = &chat.EventSendMessage{
-				EventMeta: meta,
-				Message:   t.SendMessage.Message,
-			}
-
-		case *EventEnvelope_NewMessage:
-			meta := chat.EventMeta{Time: t.NewMessage.Time.AsTime()}
-			e = &chat.EventNewMessage{
-				EventMeta: meta,
-				Sender:    t.NewMessage.Sender,
-				Message:   t.NewMessage.Message,
-			}
-
-		default:
-			return fmt.Errorf(
-				"unknown grpc payload type <%s>",
-				reflect.TypeOf(envelope.Event).String(),
-			)
+		e, err := chat.Events.FromProto(envelope)
+		var unknownErr *chat.ErrUnknownEvent
+		if errors.As(err, &unknownErr) {
+			// Forward compatible: skip events we don't know.
+			h.logger.Warnw("skipping unknown event", log.Error(err))
+			continue
+		}
+		if err != nil {
+			return err
+		}
 
+		select {
+		case h.eventOutCh <- e:
+		case <-h.closed:
+			return chat.ErrConnectionClosed
 		}
+	}
+}
 
-		h.eventOutCh <- e
+// RemoteAddr returns the address of the peer of server streams, empty
+// for client streams.
+func (c *Connection) RemoteAddr() string {
+	s, ok := c.grpcConn.(interface{ Context() context.Context })
+	if !ok {
+		return ""
+	}
+	if p, ok := peer.FromContext(s.Context()); ok {
+		return p.Addr.String()
 	}
+	return ""
+}
+
+// Transport returns "grpc".
+func (c *Connection) Transport() string {
+	return "grpc"
 }
 
 func NewConnection(
@@ -227,14 +164,20 @@ func NewConnection(
 	logger log.Logger,
 ) *Connection {
 	conn := Connection{
+		logger:     logger,
 		eventOutCh: make(chan chat.Event),
 		closed:     make(chan struct{}),
 		error:      nil,
 		grpcConn:   grpcConn,
 	}
 	go func() {
-		defer conn.Close(nil)
 		err := conn.grpcReadPump()
+		// Keep the status the other side ended with, like rejections.
+		if _, ok := status.FromError(err); ok && status.Code(err) != codes.Canceled {
+			_ = conn.Close(err)
+		} else {
+			_ = conn.Close(nil)
+		}
 		if errors.Is(err, chat.ErrConnectionClosed) {
 			logger.Infow("grpc pump closed")
 		} else {
@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat/chattest"
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type testGrpcConnection struct {
+	recv chan *pb.EventEnvelope
+	sent chan *pb.EventEnvelope
+}
+
+func (c *testGrpcConnection) Send(e *pb.EventEnvelope) error {
+	c.sent <- e
+	return nil
+}
+
+func (c *testGrpcConnection) Recv() (*pb.EventEnvelope, error) {
+	e, ok := <-c.recv
+	if !ok {
+		return nil, chat.ErrConnectionClosed
+	}
+	return e, nil
+}
+
+func TestConnectionSkipsUnknownEvents(t *testing.T) {
+	grpcConn := &testGrpcConnection{
+		recv: make(chan *pb.EventEnvelope, 2),
+		sent: make(chan *pb.EventEnvelope, 1),
+	}
+	conn := NewConnection(grpcConn, test.NewTestLogger(true))
+	defer close(grpcConn.recv)
+
+	// An envelope from a newer peer with an event in an unknown field.
+	b := protowire.AppendTag(nil, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, 3)
+	b = protowire.AppendTag(b, 99, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte{})
+	future := &pb.EventEnvelope{}
+	require.NoError(t, proto.Unmarshal(b, future))
+	require.Nil(t, future.Event)
+	grpcConn.recv <- future
+
+	// A version 1 peer does not set the version.
+	grpcConn.recv <- &pb.EventEnvelope{
+		Event: &pb.EventEnvelope_NewMessage{
+			NewMessage: &pb.NewMessage{
+				Time:    timestamppb.New(chat.NewEventMetaNow(clock.Real).Time),
+				Sender:  "user",
+				Message: "hi",
+			},
+		},
+	}
+
+	e, err := test.ChTimeout(t, conn.eventOutCh)
+	require.NoError(t, err)
+	m, ok := e.(*chat.EventNewMessage)
+	require.True(t, ok)
+	assert.Equal(t, "hi", m.Message)
+}
+
+func TestConnectionSendsVersion(t *testing.T) {
+	grpcConn := &testGrpcConnection{
+		recv: make(chan *pb.EventEnvelope),
+		sent: make(chan *pb.EventEnvelope, 1),
+	}
+	conn := NewConnection(grpcConn, test.NewTestLogger(true))
+	defer close(grpcConn.recv)
+
+	require.NoError(t, conn.SendEvent(chat.NewEventHello(clock.Real)))
+	envelope := <-grpcConn.sent
+	assert.Equal(t, int32(chat.ProtocolVersion), envelope.Version)
+	assert.Equal(t, chat.SupportedFeatures, envelope.GetHello().Features)
+}
+
+func TestConnectionRoundTrip(t *testing.T) {
+	grpcConn := &testGrpcConnection{
+		recv: make(chan *pb.EventEnvelope),
+		sent: make(chan *pb.EventEnvelope, 1),
+	}
+	conn := NewConnection(grpcConn, test.NewTestLogger(true))
+	defer close(grpcConn.recv)
+
+	r := rand.New(rand.NewSource(1))
+	for _, et := range chat.Events.Types() {
+		for i := 0; i < 100; i++ {
+			e := chattest.NewRandomEvent(r, et)
+			require.NoError(t, conn.SendEvent(e))
+			grpcConn.recv <- <-grpcConn.sent
+			decoded, err := test.ChTimeout(t, conn.eventOutCh)
+			require.NoError(t, err)
+			require.Equal(t, e, decoded)
+		}
+	}
+}
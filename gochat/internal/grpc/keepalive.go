@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// KeepaliveOpts configure the HTTP/2 keepalive pings of servers and
+// clients.
+type KeepaliveOpts struct {
+	// Interval is the idle time after which a ping is sent,
+	// DefaultKeepalive.Interval when zero. gRPC clients ping at most
+	// every 10 seconds.
+	Interval time.Duration
+	// Timeout is how long to wait for the ping ack before closing the
+	// connection, DefaultKeepalive.Timeout when zero.
+	Timeout time.Duration
+}
+
+// DefaultKeepalive are the default keepalive options.
+var DefaultKeepalive = KeepaliveOpts{
+	Interval: 30 * time.Second,
+	Timeout:  10 * time.Second,
+}
+
+// keepaliveMinTime is the minimum time between client pings the server
+// allows, the minimum of gRPC clients.
+const keepaliveMinTime = 10 * time.Second
+
+// orDefault returns the options with defaults for zero values.
+func (o KeepaliveOpts) orDefault() KeepaliveOpts {
+	if o.Interval == 0 {
+		o.Interval = DefaultKeepalive.Interval
+	}
+	if o.Timeout == 0 {
+		o.Timeout = DefaultKeepalive.Timeout
+	}
+	return o
+}
+
+func (o KeepaliveOpts) serverOptions() []grpc.ServerOption {
+	o = o.orDefault()
+	return []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    o.Interval,
+			Timeout: o.Timeout,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             keepaliveMinTime,
+			PermitWithoutStream: true,
+		}),
+	}
+}
+
+func (o KeepaliveOpts) dialOption() grpc.DialOption {
+	o = o.orDefault()
+	return grpc.WithKeepaliveParams(keepalive.ClientParameters{
+		Time:                o.Interval,
+		Timeout:             o.Timeout,
+		PermitWithoutStream: true,
+	})
+}
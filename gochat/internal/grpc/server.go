@@ -2,21 +2,28 @@ package grpc
 
 import (
 	"net"
+	"sync"
 
 	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"github.com/marcelbeumer/go-playground/gochat/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
 type HubService struct {
+	pb.UnimplementedHubServer
 	logger log.Logger
-	hub    chat.Hub
+	hub    *chat.Hub
 }
 
-func (h *HubService) Chat(s Hub_ChatServer) error {
+func (h *HubService) Chat(s pb.Hub_ChatServer) error {
 	md, ok := metadata.FromIncomingContext(s.Context())
 	if !ok {
 		return status.Error(codes.FailedPrecondition, "no metadata found")
@@ -26,11 +33,26 @@ func (h *HubService) Chat(s Hub_ChatServer) error {
 		return status.Error(codes.FailedPrecondition, "no username in metadata")
 	}
 	username := usernames[0]
+	identity := ""
+	if authorization := md.Get("authorization"); len(authorization) > 0 {
+		identity = chat.IdentityFromAuthorization(authorization[0])
+	}
+	ctx := tracing.Extract(s.Context(), tracing.Carrier(func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}))
+	_, span := tracing.Tracer().Start(ctx, "grpc.connect",
+		trace.WithSpanKind(trace.SpanKindServer))
 	conn := NewConnection(s, h.logger)
-	_, err := h.hub.Connect(username, conn)
+	_, err := h.hub.ConnectIdentity(identity, username, conn)
 	if err != nil {
+		span.RecordError(err)
+		span.End()
 		return status.Error(codes.Unknown, err.Error())
 	}
+	span.End()
 	err = conn.Wait()
 	if err != nil {
 		return status.Error(codes.Unknown, err.Error())
@@ -38,43 +60,83 @@ func (h *HubService) Chat(s Hub_ChatServer) error {
 	return nil
 }
 
-func (h *HubService) mustEmbedUnimplementedHubServer() {}
+// healthServiceName is the service name of the hub in health checks,
+// next to the empty name for the server as a whole.
+const healthServiceName = "chat.Hub"
 
 type Server struct {
+	// AdminToken enables the Admin service for this bearer token,
+	// HubOpts.AdminToken by default.
+	AdminToken string
+	// Keepalive configures pings, DefaultKeepalive when zero.
+	Keepalive  KeepaliveOpts
 	logger     log.Logger
 	grpcServer *grpc.Server
+	health     *health.Server
+	hub        *chat.Hub
+	mu         sync.Mutex
+}
+
+// Hub returns the hub of the server.
+func (s *Server) Hub() *chat.Hub {
+	return s.hub
 }
 
 func (s *Server) Start(addr string) error {
 	logger := s.logger
 	logger.Infow("starting grpc server", "addr", addr)
-
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
+	return s.Serve(lis)
+}
 
-	var opts []grpc.ServerOption
-	s.grpcServer = grpc.NewServer(opts...)
+// Serve serves on the listener until Stop, for example on an ephemeral
+// port in tests.
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	s.grpcServer = grpc.NewServer(s.Keepalive.serverOptions()...)
+	s.health = health.NewServer()
+	s.health.SetServingStatus(healthServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s.grpcServer, s.health)
 
-	RegisterHubServer(s.grpcServer, &HubService{
+	pb.RegisterHubServer(s.grpcServer, &HubService{
 		logger: s.logger,
-		hub:    *chat.NewHub(logger),
+		hub:    s.hub,
 	})
+	if s.AdminToken != "" {
+		pb.RegisterAdminServer(s.grpcServer, &AdminService{
+			logger: s.logger,
+			hub:    s.hub,
+			token:  s.AdminToken,
+		})
+	}
+
+	grpcServer := s.grpcServer
+	s.mu.Unlock()
 
-	_ = s.grpcServer.Serve(lis)
-	return err
+	return grpcServer.Serve(lis)
 }
 
 func (s *Server) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.health != nil {
+		s.health.Shutdown()
+	}
 	if s.grpcServer != nil {
 		s.grpcServer.Stop()
 	}
 	return nil
 }
 
-func NewServer(logger log.Logger) *Server {
+// NewServer returns a server with a new hub.
+func NewServer(logger log.Logger, hubOpts chat.HubOpts) *Server {
 	return &Server{
 		logger: logger,
+		hub:    chat.NewHub(logger, hubOpts),
+
+		AdminToken: hubOpts.AdminToken,
 	}
 }
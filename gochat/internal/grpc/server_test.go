@@ -0,0 +1,89 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerHealth(t *testing.T) {
+	s := NewServer(test.NewTestLogger(true), chat.HubOpts{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(func() { _ = s.Stop() })
+
+	conn, err := grpc.Dial(
+		lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		DefaultKeepalive.dialOption(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	client := healthpb.NewHealthClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, service := range []string{"", healthServiceName} {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{
+			Service: service,
+		}, grpc.WaitForReady(true))
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status, service)
+	}
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "chat.Unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	fake := clock.NewFake(time.UnixMilli(0))
+	s := NewServer(test.NewTestLogger(true), chat.HubOpts{
+		Clock:       fake,
+		IdleTimeout: 10 * time.Minute,
+	})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(func() { _ = s.Stop() })
+
+	conn, err := NewClientConnection(lis.Addr().String(), "idle", test.NewTestLogger(true), ClientOpts{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(nil) })
+	e, err := conn.ReadEvent()
+	require.NoError(t, err)
+	require.IsType(t, &chat.EventConnected{}, e)
+
+	// The open stream is no activity, only events are.
+	fake.BlockUntil(1)
+	fake.Advance(5 * time.Minute)
+	fake.BlockUntil(1)
+	fake.Advance(6 * time.Minute)
+	notices := []string{}
+	err = test.GoTimeout(t, func() error {
+		for {
+			e, err := conn.ReadEvent()
+			if err != nil {
+				return nil
+			}
+			if n, ok := e.(*chat.EventNotice); ok {
+				notices = append(notices, n.Message)
+			}
+		}
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"disconnected: " + chat.ErrIdleTimeout.Error()}, notices)
+	assert.Empty(t, s.Hub().Sessions())
+}
@@ -0,0 +1,351 @@
+// Package harness runs full client/server scenarios in-process, with a
+// real server on an ephemeral port and named clients, for tests that
+// should pass on every transport.
+package harness
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/grpc"
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/marcelbeumer/go-playground/gochat/internal/websocket"
+)
+
+// Transport is a client/server transport.
+type Transport string
+
+const (
+	Websocket Transport = "websocket"
+	Grpc      Transport = "grpc"
+)
+
+// Transports are all transports, for table tests.
+var Transports = []Transport{Websocket, Grpc}
+
+// Opts are the options of New.
+type Opts struct {
+	Hub        chat.HubOpts
+	Plugins    []*chat.Plugin
+	AdminToken string
+	// Timeout of EventuallyReceives, test.TimeoutDefault when zero.
+	Timeout time.Duration
+	// Verbose logs the server and clients.
+	Verbose bool
+}
+
+// server is implemented by the servers of all transports.
+type server interface {
+	Hub() *chat.Hub
+	Serve(lis net.Listener) error
+	Stop() error
+}
+
+// Harness is a running server with named clients.
+type Harness struct {
+	// Addr is the address the server listens on.
+	Addr      string
+	t         *testing.T
+	transport Transport
+	opts      Opts
+	logger    log.Logger
+	server    server
+	clients   map[string]*Client
+	clientsMu sync.Mutex
+}
+
+// Hub returns the hub of the server.
+func (h *Harness) Hub() *chat.Hub {
+	return h.server.Hub()
+}
+
+// Connect connects clients by username, failing the test on errors.
+func (h *Harness) Connect(usernames ...string) {
+	h.t.Helper()
+	for _, username := range usernames {
+		h.ConnectWithToken(username, "")
+	}
+}
+
+// ConnectWithToken connects an (authenticated) client. The client is
+// named after the username, or "username#n" for more sessions of the
+// same username.
+func (h *Harness) ConnectWithToken(username string, token string) *Client {
+	h.t.Helper()
+	conn, err := h.dial(username, token)
+	if err != nil {
+		h.t.Fatalf("could not connect %s: %s", username, err)
+	}
+
+	h.clientsMu.Lock()
+	name := username
+	for n := 2; h.clients[name] != nil; n++ {
+		name = fmt.Sprintf("%s#%d", username, n)
+	}
+	c := newClient(name, conn)
+	h.clients[name] = c
+	h.clientsMu.Unlock()
+	return c
+}
+
+// ConnectRejected connects a client the server should reject, returning
+// the error it was rejected with. Fails the test when connected.
+func (h *Harness) ConnectRejected(username string, token string) error {
+	h.t.Helper()
+	conn, err := h.dial(username, token)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(nil)
+	e, err := conn.ReadEvent()
+	if err == nil {
+		h.t.Fatalf("%s connected, got %s", username, reflect.TypeOf(e))
+	}
+	if connErr := conn.Err(); connErr != nil {
+		return connErr
+	}
+	return err
+}
+
+// dial connects a client with the transport.
+func (h *Harness) dial(username string, token string) (chat.Connection, error) {
+	switch h.transport {
+	case Websocket:
+		return websocket.NewClientConnection(
+			h.Addr, username, h.logger, websocket.ClientOpts{Token: token})
+	case Grpc:
+		return grpc.NewClientConnection(
+			h.Addr, username, h.logger, grpc.ClientOpts{Token: token})
+	}
+	return nil, fmt.Errorf("unknown transport %s", h.transport)
+}
+
+// Client returns the client by name, failing the test when unknown.
+func (h *Harness) Client(name string) *Client {
+	h.t.Helper()
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+	c, ok := h.clients[name]
+	if !ok {
+		h.t.Fatalf("unknown client %s", name)
+	}
+	return c
+}
+
+// Send sends the event from the client.
+func (h *Harness) Send(name string, e chat.Event) {
+	h.t.Helper()
+	if err := h.Client(name).conn.SendEvent(e); err != nil {
+		h.t.Fatalf("%s could not send %s: %s", name, reflect.TypeOf(e), err)
+	}
+}
+
+// SendMessage sends a message from the client.
+func (h *Harness) SendMessage(name string, message string) {
+	h.t.Helper()
+	h.Send(name, &chat.EventSendMessage{
+		EventMeta: *chat.NewEventMetaNow(h.Hub().Clock()),
+		Message:   message,
+	})
+}
+
+// Disconnect closes the client connection.
+func (h *Harness) Disconnect(name string) {
+	h.t.Helper()
+	c := h.Client(name)
+	_ = c.conn.Close(nil)
+	h.clientsMu.Lock()
+	delete(h.clients, name)
+	h.clientsMu.Unlock()
+}
+
+// Admin returns an admin client with the admin token, closed when the
+// test ends.
+func (h *Harness) Admin() chat.Admin {
+	h.t.Helper()
+	var admin chat.Admin
+	var err error
+	switch h.transport {
+	case Websocket:
+		admin, err = websocket.NewAdminClient(
+			h.Addr, websocket.AdminOpts{Token: h.opts.AdminToken})
+	case Grpc:
+		admin, err = grpc.NewAdminClient(
+			h.Addr, grpc.AdminOpts{Token: h.opts.AdminToken})
+	}
+	if err != nil {
+		h.t.Fatalf("could not create admin client: %s", err)
+	}
+	h.t.Cleanup(func() { _ = admin.Close() })
+	return admin
+}
+
+// EventuallyReceives waits for the client to receive an event matching,
+// skipping other events. Fails the test on timeout.
+func (h *Harness) EventuallyReceives(name string, match Matcher) chat.Event {
+	h.t.Helper()
+	c := h.Client(name)
+	timeout := time.After(h.opts.Timeout)
+	for {
+		select {
+		case e, ok := <-c.events:
+			if !ok {
+				h.t.Fatalf("%s disconnected waiting for %s: %v", name, match, c.err)
+				return nil
+			}
+			if match.Match(e) {
+				return e
+			}
+		case <-timeout:
+			h.t.Fatalf("%s did not receive %s", name, match)
+			return nil
+		}
+	}
+}
+
+// Client is a connected client.
+type Client struct {
+	Name   string
+	conn   chat.Connection
+	events chan chat.Event
+	// err is the read error, set before events is closed.
+	err error
+}
+
+func (c *Client) pump() {
+	defer close(c.events)
+	for {
+		e, err := c.conn.ReadEvent()
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.events <- e
+	}
+}
+
+func newClient(name string, conn chat.Connection) *Client {
+	c := &Client{
+		Name:   name,
+		conn:   conn,
+		events: make(chan chat.Event, 1000),
+	}
+	go c.pump()
+	return c
+}
+
+// Matcher matches received events.
+type Matcher struct {
+	// Description is used in test failures.
+	Description string
+	Match       func(e chat.Event) bool
+}
+
+func (m Matcher) String() string {
+	return m.Description
+}
+
+// Type matches events of type T.
+func Type[T chat.Event]() Matcher {
+	var e T
+	return Matcher{
+		Description: reflect.TypeOf(e).String(),
+		Match: func(e chat.Event) bool {
+			_, ok := e.(T)
+			return ok
+		},
+	}
+}
+
+// Where matches events of type T for which fn returns true.
+func Where[T chat.Event](description string, fn func(e T) bool) Matcher {
+	return Matcher{
+		Description: description,
+		Match: func(e chat.Event) bool {
+			t, ok := e.(T)
+			return ok && fn(t)
+		},
+	}
+}
+
+// Message matches EventNewMessage by sender and message.
+func Message(sender string, message string) Matcher {
+	return Where(
+		fmt.Sprintf(`message "%s" from %s`, message, sender),
+		func(e *chat.EventNewMessage) bool {
+			return e.Sender == sender && e.Message == message
+		},
+	)
+}
+
+// Users matches EventConnected and EventUserListUpdate with exactly
+// the users.
+func Users(users ...string) Matcher {
+	return Matcher{
+		Description: fmt.Sprintf("users %s", strings.Join(users, ", ")),
+		Match: func(e chat.Event) bool {
+			switch t := e.(type) {
+			case *chat.EventConnected:
+				return reflect.DeepEqual(t.Users, users)
+			case *chat.EventUserListUpdate:
+				return reflect.DeepEqual(t.Users, users)
+			}
+			return false
+		},
+	}
+}
+
+// New starts a server for the transport on an ephemeral port, stopped
+// when the test ends.
+func New(t *testing.T, transport Transport, opts Opts) *Harness {
+	t.Helper()
+	if opts.Timeout == 0 {
+		opts.Timeout = test.TimeoutDefault
+	}
+	logger := test.NewTestLogger(!opts.Verbose)
+
+	opts.Hub.AdminToken = opts.AdminToken
+	var s server
+	switch transport {
+	case Websocket:
+		s = websocket.NewServer(logger, opts.Hub)
+	case Grpc:
+		s = grpc.NewServer(logger, opts.Hub)
+	default:
+		t.Fatalf("unknown transport %s", transport)
+	}
+	s.Hub().Use(opts.Plugins...)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %s", err)
+	}
+	go func() { _ = s.Serve(lis) }()
+
+	h := &Harness{
+		Addr:      lis.Addr().String(),
+		t:         t,
+		transport: transport,
+		opts:      opts,
+		logger:    logger,
+		server:    s,
+		clients:   map[string]*Client{},
+	}
+	t.Cleanup(func() {
+		h.clientsMu.Lock()
+		for _, c := range h.clients {
+			_ = c.conn.Close(nil)
+		}
+		h.clientsMu.Unlock()
+		_ = s.Stop()
+		_ = s.Hub().Close()
+	})
+	return h
+}
@@ -0,0 +1,170 @@
+package harness
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenarios(t *testing.T) {
+	scenarios := []struct {
+		name string
+		run  func(t *testing.T, h *Harness)
+	}{
+		{"presence", func(t *testing.T, h *Harness) {
+			h.Connect("alice")
+			h.EventuallyReceives("alice", Type[*chat.EventConnected]())
+			h.Connect("bob")
+			h.EventuallyReceives("alice", Where("bob entered",
+				func(e *chat.EventUserEnter) bool { return e.Name == "bob" }))
+			h.EventuallyReceives("alice", Users("alice", "bob"))
+
+			h.Disconnect("bob")
+			h.EventuallyReceives("alice", Where("bob left",
+				func(e *chat.EventUserLeave) bool { return e.Name == "bob" }))
+			h.EventuallyReceives("alice", Users("alice"))
+		}},
+		{"messages and mentions", func(t *testing.T, h *Harness) {
+			h.Connect("alice", "bob", "carol")
+			h.EventuallyReceives("carol", Users("alice", "bob", "carol"))
+
+			h.SendMessage("alice", "hi @bob")
+			for _, name := range []string{"alice", "bob", "carol"} {
+				m := h.EventuallyReceives(name, Message("alice", "hi @bob"))
+				assert.Equal(t, name == "bob", m.(*chat.EventNewMessage).Mentioned, name)
+			}
+		}},
+		{"nick change", func(t *testing.T, h *Harness) {
+			h.Connect("alice", "bob")
+			h.EventuallyReceives("alice", Users("alice", "bob"))
+
+			h.Send("bob", &chat.EventChangeNick{Name: "robert"})
+			h.EventuallyReceives("alice", Where("bob renamed",
+				func(e *chat.EventNickChange) bool {
+					return e.OldName == "bob" && e.NewName == "robert"
+				}))
+			h.SendMessage("bob", "hello")
+			h.EventuallyReceives("alice", Message("robert", "hello"))
+		}},
+		{"rejected usernames", func(t *testing.T, h *Harness) {
+			h.ConnectWithToken("alice", "secret")
+			h.EventuallyReceives("alice", Type[*chat.EventConnected]())
+
+			assert.ErrorContains(t, h.ConnectRejected("Alice", ""), `user "Alice" already exists`)
+			assert.ErrorContains(t, h.ConnectRejected("not valid", ""), `invalid name "not valid"`)
+			assert.ErrorContains(t, h.ConnectRejected("alicia", "secret"), `already connected as "alice"`)
+			h.Connect("bob")
+			h.EventuallyReceives("bob", Users("alice", "bob"))
+		}},
+		{"multiple sessions", func(t *testing.T, h *Harness) {
+			h.ConnectWithToken("alice", "secret")
+			h.ConnectWithToken("alice", "secret")
+			h.Connect("bob")
+			h.EventuallyReceives("bob", Type[*chat.EventConnected]())
+
+			h.SendMessage("bob", "hi alice")
+			h.EventuallyReceives("alice", Message("bob", "hi alice"))
+			h.EventuallyReceives("alice#2", Message("bob", "hi alice"))
+		}},
+		{"admin roles and bans", func(t *testing.T, h *Harness) {
+			h.ConnectWithToken("alice", "secret")
+			h.Connect("bob")
+			h.EventuallyReceives("bob", Users("alice", "bob"))
+			admin := h.Admin()
+
+			require.NoError(t, admin.SetRole("alice", chat.RoleAdmin))
+			assert.ErrorContains(t, admin.SetRole("bob", chat.RoleAdmin), `user "bob" is not authenticated`)
+			members, err := admin.Members()
+			require.NoError(t, err)
+			require.Len(t, members, 1)
+			assert.Equal(t, "alice", members[0].Name)
+			assert.Equal(t, chat.RoleAdmin, members[0].Role)
+
+			require.NoError(t, admin.Ban("alice", "spam", time.Time{}))
+			h.EventuallyReceives("alice", Where("banned notice",
+				func(e *chat.EventNotice) bool { return e.Message == "you were banned: spam" }))
+			h.EventuallyReceives("bob", Users("bob"))
+			bans, err := admin.Bans()
+			require.NoError(t, err)
+			require.Len(t, bans, 1)
+			assert.Equal(t, "alice", bans[0].Name)
+			assert.True(t, bans[0].Until.IsZero())
+			assert.ErrorContains(t, h.ConnectRejected("alice", "secret"), `user "alice" is banned: spam`)
+
+			require.NoError(t, admin.Unban("alice"))
+			assert.ErrorContains(t, admin.Unban("alice"), `user "alice" is not banned`)
+			h.ConnectWithToken("alice", "secret")
+			h.EventuallyReceives("bob", Users("alice", "bob"))
+		}},
+		{"admin", func(t *testing.T, h *Harness) {
+			h.Connect("alice", "bob")
+			h.EventuallyReceives("alice", Users("alice", "bob"))
+			admin := h.Admin()
+
+			rooms, err := admin.Rooms()
+			require.NoError(t, err)
+			assert.Equal(t, []chat.RoomInfo{{Name: chat.DefaultRoom, Users: 2, Sessions: 2}}, rooms)
+
+			sessions, err := admin.Sessions()
+			require.NoError(t, err)
+			require.Len(t, sessions, 2)
+			assert.Equal(t, "alice", sessions[0].Username)
+			assert.Equal(t, string(h.transport), sessions[0].Transport)
+			assert.NotEmpty(t, sessions[0].RemoteAddr)
+
+			require.NoError(t, admin.Notice("maintenance at noon"))
+			h.EventuallyReceives("bob", Where("notice",
+				func(e *chat.EventNotice) bool { return e.Message == "maintenance at noon" }))
+
+			n, err := admin.Kick("bob", "spam")
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+			h.EventuallyReceives("bob", Where("kicked notice",
+				func(e *chat.EventNotice) bool { return e.Message == "you were kicked: spam" }))
+			h.EventuallyReceives("alice", Users("alice"))
+			_, err = admin.Kick("bob", "")
+			assert.Error(t, err)
+
+			stats, err := admin.Stats()
+			require.NoError(t, err)
+			assert.Equal(t, 1, stats.Users)
+			assert.False(t, stats.StartedAt.IsZero())
+			assert.NotZero(t, stats.EventsOut)
+
+			report, err := admin.Retention()
+			require.NoError(t, err)
+			assert.True(t, report.DryRun)
+			require.Len(t, report.Rooms, 1)
+			assert.Equal(t, chat.DefaultRoom, report.Rooms[0].Room)
+			assert.Empty(t, report.Rooms[0].Purged)
+		}},
+	}
+
+	for _, transport := range Transports {
+		transport := transport
+		t.Run(string(transport), func(t *testing.T) {
+			for _, s := range scenarios {
+				s := s
+				t.Run(s.name, func(t *testing.T) {
+					s.run(t, New(t, transport, Opts{AdminToken: "admin"}))
+				})
+			}
+		})
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	for _, transport := range Transports {
+		var hub *chat.Hub
+		t.Run(string(transport), func(t *testing.T) {
+			h := New(t, transport, Opts{})
+			h.Connect("alice")
+			h.EventuallyReceives("alice", Type[*chat.EventConnected]())
+			hub = h.Hub()
+		})
+		assert.ErrorIs(t, hub.Close(), chat.ErrHubClosed, "hub closed after %s", transport)
+	}
+}
@@ -1,5 +1,11 @@
 package log
 
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
 // Field can be used to pass strongly typed objects to
 // the logger, like this:
 // `logger.Infow("oops", log.Error(err))`
@@ -22,4 +28,20 @@ type Logger interface {
 	Fatalw(msg string, keysAndValues ...any)
 	Named(s string) Logger
 	With(args ...any) Logger
+	// WithContext returns the logger with the trace and span IDs of
+	// the context, if any.
+	WithContext(ctx context.Context) Logger
+}
+
+// traceFields returns the trace and span ID fields for With, nil when
+// ctx has no span.
+func traceFields(ctx context.Context) []any {
+	if ctx == nil {
+		return nil
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []any{"traceId", sc.TraceID().String(), "spanId", sc.SpanID().String()}
 }
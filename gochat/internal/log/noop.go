@@ -1,5 +1,7 @@
 package log
 
+import "context"
+
 // NoopLoggerAdapter implements Logger but does not do any logging
 type NoopLoggerAdapter struct {
 }
@@ -41,3 +43,7 @@ func (l *NoopLoggerAdapter) Named(s string) Logger {
 func (l *NoopLoggerAdapter) With(args ...any) Logger {
 	return l
 }
+
+func (l *NoopLoggerAdapter) WithContext(ctx context.Context) Logger {
+	return l
+}
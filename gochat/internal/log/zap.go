@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"io"
 
 	"go.uber.org/zap"
@@ -74,6 +75,14 @@ func (l *ZapLoggerAdapter) With(args ...any) Logger {
 	return &ZapLoggerAdapter{logger: with}
 }
 
+func (l *ZapLoggerAdapter) WithContext(ctx context.Context) Logger {
+	fields := traceFields(ctx)
+	if fields == nil {
+		return l
+	}
+	return l.With(fields...)
+}
+
 func NewZapLogger(
 	out io.Writer,
 	verbose bool,
@@ -0,0 +1,645 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.19.4
+// source: internal/pb/chat.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// HubClient is the client API for Hub service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HubClient interface {
+	Chat(ctx context.Context, opts ...grpc.CallOption) (Hub_ChatClient, error)
+}
+
+type hubClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHubClient(cc grpc.ClientConnInterface) HubClient {
+	return &hubClient{cc}
+}
+
+func (c *hubClient) Chat(ctx context.Context, opts ...grpc.CallOption) (Hub_ChatClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Hub_ServiceDesc.Streams[0], "/chat.Hub/Chat", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hubChatClient{stream}
+	return x, nil
+}
+
+type Hub_ChatClient interface {
+	Send(*EventEnvelope) error
+	Recv() (*EventEnvelope, error)
+	grpc.ClientStream
+}
+
+type hubChatClient struct {
+	grpc.ClientStream
+}
+
+func (x *hubChatClient) Send(m *EventEnvelope) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *hubChatClient) Recv() (*EventEnvelope, error) {
+	m := new(EventEnvelope)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// HubServer is the server API for Hub service.
+// All implementations must embed UnimplementedHubServer
+// for forward compatibility
+type HubServer interface {
+	Chat(Hub_ChatServer) error
+	mustEmbedUnimplementedHubServer()
+}
+
+// UnimplementedHubServer must be embedded to have forward compatible implementations.
+type UnimplementedHubServer struct {
+}
+
+func (UnimplementedHubServer) Chat(Hub_ChatServer) error {
+	return status.Errorf(codes.Unimplemented, "method Chat not implemented")
+}
+func (UnimplementedHubServer) mustEmbedUnimplementedHubServer() {}
+
+// UnsafeHubServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HubServer will
+// result in compilation errors.
+type UnsafeHubServer interface {
+	mustEmbedUnimplementedHubServer()
+}
+
+func RegisterHubServer(s grpc.ServiceRegistrar, srv HubServer) {
+	s.RegisterService(&Hub_ServiceDesc, srv)
+}
+
+func _Hub_Chat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HubServer).Chat(&hubChatServer{stream})
+}
+
+type Hub_ChatServer interface {
+	Send(*EventEnvelope) error
+	Recv() (*EventEnvelope, error)
+	grpc.ServerStream
+}
+
+type hubChatServer struct {
+	grpc.ServerStream
+}
+
+func (x *hubChatServer) Send(m *EventEnvelope) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *hubChatServer) Recv() (*EventEnvelope, error) {
+	m := new(EventEnvelope)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Hub_ServiceDesc is the grpc.ServiceDesc for Hub service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Hub_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.Hub",
+	HandlerType: (*HubServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Chat",
+			Handler:       _Hub_Chat_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "internal/pb/chat.proto",
+}
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminClient interface {
+	// Export returns the room history in the time range.
+	Export(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*History, error)
+	// Rooms lists the rooms.
+	Rooms(ctx context.Context, in *RoomsRequest, opts ...grpc.CallOption) (*Rooms, error)
+	// Sessions lists the connected sessions.
+	Sessions(ctx context.Context, in *SessionsRequest, opts ...grpc.CallOption) (*Sessions, error)
+	// Kick disconnects all sessions of a user.
+	Kick(ctx context.Context, in *KickRequest, opts ...grpc.CallOption) (*KickResponse, error)
+	// Notice sends a server notice to all sessions.
+	Notice(ctx context.Context, in *NoticeRequest, opts ...grpc.CallOption) (*NoticeResponse, error)
+	// Members lists the members of the room with their roles.
+	Members(ctx context.Context, in *MembersRequest, opts ...grpc.CallOption) (*Members, error)
+	// SetRole sets the role of an authenticated user.
+	SetRole(ctx context.Context, in *SetRoleRequest, opts ...grpc.CallOption) (*SetRoleResponse, error)
+	// Ban keeps an authenticated user out, disconnecting their sessions.
+	Ban(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*BanResponse, error)
+	// Unban lifts the ban of a user.
+	Unban(ctx context.Context, in *UnbanRequest, opts ...grpc.CallOption) (*UnbanResponse, error)
+	// Bans lists the active bans.
+	Bans(ctx context.Context, in *BansRequest, opts ...grpc.CallOption) (*Bans, error)
+	// Stats returns the hub counters.
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*Stats, error)
+	// Retention reports what the retention policies would purge now,
+	// without purging.
+	Retention(ctx context.Context, in *RetentionRequest, opts ...grpc.CallOption) (*RetentionReport, error)
+}
+
+type adminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminClient(cc grpc.ClientConnInterface) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) Export(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*History, error) {
+	out := new(History)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Export", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Rooms(ctx context.Context, in *RoomsRequest, opts ...grpc.CallOption) (*Rooms, error) {
+	out := new(Rooms)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Rooms", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Sessions(ctx context.Context, in *SessionsRequest, opts ...grpc.CallOption) (*Sessions, error) {
+	out := new(Sessions)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Sessions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Kick(ctx context.Context, in *KickRequest, opts ...grpc.CallOption) (*KickResponse, error) {
+	out := new(KickResponse)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Kick", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Notice(ctx context.Context, in *NoticeRequest, opts ...grpc.CallOption) (*NoticeResponse, error) {
+	out := new(NoticeResponse)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Notice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Members(ctx context.Context, in *MembersRequest, opts ...grpc.CallOption) (*Members, error) {
+	out := new(Members)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Members", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetRole(ctx context.Context, in *SetRoleRequest, opts ...grpc.CallOption) (*SetRoleResponse, error) {
+	out := new(SetRoleResponse)
+	err := c.cc.Invoke(ctx, "/chat.Admin/SetRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Ban(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*BanResponse, error) {
+	out := new(BanResponse)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Ban", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Unban(ctx context.Context, in *UnbanRequest, opts ...grpc.CallOption) (*UnbanResponse, error) {
+	out := new(UnbanResponse)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Unban", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Bans(ctx context.Context, in *BansRequest, opts ...grpc.CallOption) (*Bans, error) {
+	out := new(Bans)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Bans", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*Stats, error) {
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Retention(ctx context.Context, in *RetentionRequest, opts ...grpc.CallOption) (*RetentionReport, error) {
+	out := new(RetentionReport)
+	err := c.cc.Invoke(ctx, "/chat.Admin/Retention", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServer is the server API for Admin service.
+// All implementations must embed UnimplementedAdminServer
+// for forward compatibility
+type AdminServer interface {
+	// Export returns the room history in the time range.
+	Export(context.Context, *HistoryRequest) (*History, error)
+	// Rooms lists the rooms.
+	Rooms(context.Context, *RoomsRequest) (*Rooms, error)
+	// Sessions lists the connected sessions.
+	Sessions(context.Context, *SessionsRequest) (*Sessions, error)
+	// Kick disconnects all sessions of a user.
+	Kick(context.Context, *KickRequest) (*KickResponse, error)
+	// Notice sends a server notice to all sessions.
+	Notice(context.Context, *NoticeRequest) (*NoticeResponse, error)
+	// Members lists the members of the room with their roles.
+	Members(context.Context, *MembersRequest) (*Members, error)
+	// SetRole sets the role of an authenticated user.
+	SetRole(context.Context, *SetRoleRequest) (*SetRoleResponse, error)
+	// Ban keeps an authenticated user out, disconnecting their sessions.
+	Ban(context.Context, *BanRequest) (*BanResponse, error)
+	// Unban lifts the ban of a user.
+	Unban(context.Context, *UnbanRequest) (*UnbanResponse, error)
+	// Bans lists the active bans.
+	Bans(context.Context, *BansRequest) (*Bans, error)
+	// Stats returns the hub counters.
+	Stats(context.Context, *StatsRequest) (*Stats, error)
+	// Retention reports what the retention policies would purge now,
+	// without purging.
+	Retention(context.Context, *RetentionRequest) (*RetentionReport, error)
+	mustEmbedUnimplementedAdminServer()
+}
+
+// UnimplementedAdminServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServer struct {
+}
+
+func (UnimplementedAdminServer) Export(context.Context, *HistoryRequest) (*History, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedAdminServer) Rooms(context.Context, *RoomsRequest) (*Rooms, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rooms not implemented")
+}
+func (UnimplementedAdminServer) Sessions(context.Context, *SessionsRequest) (*Sessions, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sessions not implemented")
+}
+func (UnimplementedAdminServer) Kick(context.Context, *KickRequest) (*KickResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Kick not implemented")
+}
+func (UnimplementedAdminServer) Notice(context.Context, *NoticeRequest) (*NoticeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Notice not implemented")
+}
+func (UnimplementedAdminServer) Members(context.Context, *MembersRequest) (*Members, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Members not implemented")
+}
+func (UnimplementedAdminServer) SetRole(context.Context, *SetRoleRequest) (*SetRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRole not implemented")
+}
+func (UnimplementedAdminServer) Ban(context.Context, *BanRequest) (*BanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ban not implemented")
+}
+func (UnimplementedAdminServer) Unban(context.Context, *UnbanRequest) (*UnbanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unban not implemented")
+}
+func (UnimplementedAdminServer) Bans(context.Context, *BansRequest) (*Bans, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Bans not implemented")
+}
+func (UnimplementedAdminServer) Stats(context.Context, *StatsRequest) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedAdminServer) Retention(context.Context, *RetentionRequest) (*RetentionReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Retention not implemented")
+}
+func (UnimplementedAdminServer) mustEmbedUnimplementedAdminServer() {}
+
+// UnsafeAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServer will
+// result in compilation errors.
+type UnsafeAdminServer interface {
+	mustEmbedUnimplementedAdminServer()
+}
+
+func RegisterAdminServer(s grpc.ServiceRegistrar, srv AdminServer) {
+	s.RegisterService(&Admin_ServiceDesc, srv)
+}
+
+func _Admin_Export_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Export(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Export",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Export(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Rooms_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Rooms(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Rooms",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Rooms(ctx, req.(*RoomsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Sessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Sessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Sessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Sessions(ctx, req.(*SessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Kick_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KickRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Kick(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Kick",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Kick(ctx, req.(*KickRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Notice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NoticeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Notice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Notice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Notice(ctx, req.(*NoticeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Members_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MembersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Members(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Members",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Members(ctx, req.(*MembersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SetRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SetRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/SetRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SetRole(ctx, req.(*SetRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Ban_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Ban(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Ban",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Ban(ctx, req.(*BanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Unban_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnbanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Unban(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Unban",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Unban(ctx, req.(*UnbanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Bans_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BansRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Bans(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Bans",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Bans(ctx, req.(*BansRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Retention_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetentionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Retention(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.Admin/Retention",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Retention(ctx, req.(*RetentionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Admin_ServiceDesc is the grpc.ServiceDesc for Admin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Admin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Export",
+			Handler:    _Admin_Export_Handler,
+		},
+		{
+			MethodName: "Rooms",
+			Handler:    _Admin_Rooms_Handler,
+		},
+		{
+			MethodName: "Sessions",
+			Handler:    _Admin_Sessions_Handler,
+		},
+		{
+			MethodName: "Kick",
+			Handler:    _Admin_Kick_Handler,
+		},
+		{
+			MethodName: "Notice",
+			Handler:    _Admin_Notice_Handler,
+		},
+		{
+			MethodName: "Members",
+			Handler:    _Admin_Members_Handler,
+		},
+		{
+			MethodName: "SetRole",
+			Handler:    _Admin_SetRole_Handler,
+		},
+		{
+			MethodName: "Ban",
+			Handler:    _Admin_Ban_Handler,
+		},
+		{
+			MethodName: "Unban",
+			Handler:    _Admin_Unban_Handler,
+		},
+		{
+			MethodName: "Bans",
+			Handler:    _Admin_Bans_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _Admin_Stats_Handler,
+		},
+		{
+			MethodName: "Retention",
+			Handler:    _Admin_Retention_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "internal/pb/chat.proto",
+}
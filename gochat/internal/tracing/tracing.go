@@ -0,0 +1,127 @@
+// Package tracing sets up OpenTelemetry tracing and propagates trace
+// context through transport envelopes as W3C trace context.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const tracerName = "github.com/marcelbeumer/go-playground/gochat"
+
+// Opts are the options of Setup.
+type Opts struct {
+	// Exporter is one of the exporters, none when empty.
+	Exporter string
+	// Endpoint is the OTLP gRPC endpoint (host:port), the
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable or localhost:4317
+	// when empty.
+	Endpoint string
+	// Insecure disables TLS for the OTLP endpoint.
+	Insecure bool
+	// ServiceName is the name of the traced service.
+	ServiceName string
+	// Writer is where the stdout exporter writes, os.Stdout when nil.
+	Writer io.Writer
+}
+
+// propagator is used regardless of Setup, so servers without an
+// exporter still pass trace context on.
+var propagator = propagation.TraceContext{}
+
+// Tracer returns the gochat tracer, a no-op tracer until Setup.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Inject returns the trace context of ctx, nil when it has no span.
+func Inject(ctx context.Context) map[string]string {
+	if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier
+}
+
+// Carrier returns the trace context read with get, for example from
+// HTTP headers or gRPC metadata.
+func Carrier(get func(key string) string) map[string]string {
+	carrier := map[string]string{}
+	for _, key := range propagator.Fields() {
+		if v := get(key); v != "" {
+			carrier[key] = v
+		}
+	}
+	return carrier
+}
+
+// Extract returns ctx with the remote span context of the carrier.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// Setup sets the global tracer provider for the exporter. The returned
+// shutdown flushes pending spans.
+func Setup(ctx context.Context, opts Opts) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		w := opts.Writer
+		if w == nil {
+			w = os.Stdout
+		}
+		exporter, err = stdouttrace.New(
+			stdouttrace.WithWriter(w),
+			stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		clientOpts := []otlptracegrpc.Option{}
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracegrpc.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, clientOpts...)
+	default:
+		return nil, fmt.Errorf(`unknown trace exporter "%s"`, opts.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(opts.ServiceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return provider.Shutdown, nil
+}
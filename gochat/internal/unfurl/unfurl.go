@@ -0,0 +1,327 @@
+// Package unfurl fetches link previews (title and description) of URLs.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	ErrHostNotAllowed = errors.New("host not allowed")
+	ErrAddrNotAllowed = errors.New("address not allowed")
+	ErrNotHTML        = errors.New("not html")
+	ErrNoPreview      = errors.New("no title or description")
+)
+
+// Preview of a URL.
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+}
+
+// Options of the Unfurler. Zero values use the defaults.
+type Options struct {
+	// Timeout of a fetch including the body.
+	Timeout time.Duration
+	// MaxBytes is the maximum body size read.
+	MaxBytes int64
+	// Allow are the allowed hosts, all when empty. Patterns are a host
+	// name or "*.example.com" for subdomains.
+	Allow []string
+	// Deny are the denied hosts, winning from Allow.
+	Deny []string
+	// CacheSize is the number of cached results (including errors).
+	CacheSize int
+	// CacheTTL is how long results are cached.
+	CacheTTL time.Duration
+	// AllowPrivate allows loopback, private and link-local addresses,
+	// which are denied by default so users can't probe the server network.
+	AllowPrivate bool
+}
+
+const (
+	DefaultTimeout   = 5 * time.Second
+	DefaultMaxBytes  = 512 << 10
+	DefaultCacheSize = 1000
+	DefaultCacheTTL  = time.Hour
+)
+
+type cacheEntry struct {
+	preview *Preview
+	err     error
+	expires time.Time
+}
+
+// Unfurler fetches and caches previews.
+type Unfurler struct {
+	opts    Options
+	client  *http.Client
+	cacheMu sync.Mutex
+	cache   map[string]*cacheEntry
+	order   []string // cache keys, oldest first
+}
+
+// Unfurl returns the preview of the URL, from cache when possible.
+func (u *Unfurler) Unfurl(ctx context.Context, rawURL string) (*Preview, error) {
+	u.cacheMu.Lock()
+	entry, ok := u.cache[rawURL]
+	u.cacheMu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.preview, entry.err
+	}
+
+	preview, err := u.fetch(ctx, rawURL)
+	if ctx.Err() != nil {
+		return nil, err // canceled by the caller, try again next time
+	}
+
+	u.cacheMu.Lock()
+	defer u.cacheMu.Unlock()
+	if _, ok := u.cache[rawURL]; !ok {
+		u.order = append(u.order, rawURL)
+	}
+	u.cache[rawURL] = &cacheEntry{
+		preview: preview,
+		err:     err,
+		expires: time.Now().Add(u.opts.CacheTTL),
+	}
+	for len(u.order) > u.opts.CacheSize {
+		delete(u.cache, u.order[0])
+		u.order = u.order[1:]
+	}
+	return preview, err
+}
+
+func (u *Unfurler) fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	if err := u.checkURL(rawURL); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, u.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	preview, err := parse(io.LimitReader(res.Body, u.opts.MaxBytes))
+	if err != nil {
+		return nil, err
+	}
+	preview.URL = rawURL
+	return preview, nil
+}
+
+// checkURL checks the scheme and the host against the allow/deny lists.
+// Redirects are checked too, see New.
+func (u *Unfurler) checkURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf(`unsupported scheme "%s"`, parsed.Scheme)
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if matchHost(host, u.opts.Deny) {
+		return ErrHostNotAllowed
+	}
+	if len(u.opts.Allow) > 0 && !matchHost(host, u.opts.Allow) {
+		return ErrHostNotAllowed
+	}
+	return nil
+}
+
+// matchHost returns true when the host matches one of the patterns.
+func matchHost(host string, patterns []string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if suffix := strings.TrimPrefix(p, "*"); suffix != p {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == p {
+			return true
+		}
+	}
+	return false
+}
+
+// parse reads the title and description from the html head, preferring
+// Open Graph properties.
+func parse(r io.Reader) (*Preview, error) {
+	var title, ogTitle, description, ogDescription string
+	z := html.NewTokenizer(r)
+	inTitle := false
+loop:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			break loop // also when cut off by MaxBytes
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			switch t.Data {
+			case "title":
+				inTitle = true
+			case "meta":
+				attrs := map[string]string{}
+				for _, a := range t.Attr {
+					attrs[a.Key] = a.Val
+				}
+				key := attrs["property"]
+				if key == "" {
+					key = attrs["name"]
+				}
+				switch strings.ToLower(key) {
+				case "og:title":
+					ogTitle = attrs["content"]
+				case "og:description":
+					ogDescription = attrs["content"]
+				case "description":
+					description = attrs["content"]
+				}
+			case "body":
+				break loop
+			}
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = string(z.Text())
+			}
+		case html.EndTagToken:
+			if t := z.Token(); t.Data == "title" {
+				inTitle = false
+			} else if t.Data == "head" {
+				break loop
+			}
+		}
+	}
+
+	preview := &Preview{
+		Title:       clean(firstNonEmpty(ogTitle, title)),
+		Description: clean(firstNonEmpty(ogDescription, description)),
+	}
+	if preview.Title == "" && preview.Description == "" {
+		return nil, ErrNoPreview
+	}
+	return preview, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clean collapses white space and truncates to 300 runes.
+func clean(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > 300 {
+		s = string(r[:299]) + "…"
+	}
+	return s
+}
+
+// urlRe matches http(s) URLs in messages.
+var urlRe = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// FindURLs returns the unique URLs in the message, in order of appearance.
+func FindURLs(message string) []string {
+	urls := []string{}
+	seen := map[string]bool{}
+	for _, u := range urlRe.FindAllString(message, -1) {
+		u = strings.TrimRight(u, ".,;:!?)]}'") // trailing punctuation
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// isPrivate returns true for addresses not on the public internet.
+func isPrivate(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()
+}
+
+// New returns an Unfurler.
+func New(opts Options) *Unfurler {
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxBytes == 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.CacheSize == 0 {
+		opts.CacheSize = DefaultCacheSize
+	}
+	if opts.CacheTTL == 0 {
+		opts.CacheTTL = DefaultCacheTTL
+	}
+
+	u := &Unfurler{
+		opts:  opts,
+		cache: map[string]*cacheEntry{},
+	}
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		// Checked on the resolved address, so DNS can't point around it.
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivate(ip) {
+				return ErrAddrNotAllowed
+			}
+			return nil
+		}
+	}
+	u.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: dialer.DialContext,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return u.checkURL(req.URL.String())
+		},
+	}
+	return u
+}
@@ -0,0 +1,151 @@
+package unfurl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const page = `<!doctype html>
+<html><head>
+<title>Plain  title</title>
+<meta name="description" content="Plain description">
+<meta property="og:title" content="OG title">
+</head><body><title>not this</title></body></html>`
+
+// newTestServer serves page on "/", counting requests.
+func newTestServer(t *testing.T, requests *int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head>"+strings.Repeat(" ", 1024)+"<title>Too far</title>")
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUnfurl(t *testing.T) {
+	var requests int32
+	server := newTestServer(t, &requests)
+	u := New(Options{AllowPrivate: true})
+
+	preview, err := u.Unfurl(context.Background(), server.URL+"/page")
+	require.NoError(t, err)
+	assert.Equal(t, &Preview{
+		URL:         server.URL + "/page",
+		Title:       "OG title",
+		Description: "Plain description",
+	}, preview)
+
+	// Cached.
+	_, err = u.Unfurl(context.Background(), server.URL+"/page")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestUnfurlCache(t *testing.T) {
+	var requests int32
+	server := newTestServer(t, &requests)
+	u := New(Options{AllowPrivate: true, CacheSize: 1, CacheTTL: time.Hour})
+
+	for _, path := range []string{"/a", "/b", "/a"} {
+		_, err := u.Unfurl(context.Background(), server.URL+path)
+		require.NoError(t, err)
+	}
+	// "/a" was evicted by "/b".
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	u = New(Options{AllowPrivate: true, CacheTTL: time.Nanosecond})
+	for i := 0; i < 2; i++ {
+		_, err := u.Unfurl(context.Background(), server.URL+"/c")
+		require.NoError(t, err)
+	}
+	// Expired.
+	assert.Equal(t, int32(5), atomic.LoadInt32(&requests))
+}
+
+func TestUnfurlLimits(t *testing.T) {
+	server := newTestServer(t, new(int32))
+	u := New(Options{AllowPrivate: true, Timeout: 50 * time.Millisecond, MaxBytes: 512})
+
+	_, err := u.Unfurl(context.Background(), server.URL+"/slow")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = u.Unfurl(context.Background(), server.URL+"/large")
+	assert.ErrorIs(t, err, ErrNoPreview)
+
+	_, err = u.Unfurl(context.Background(), server.URL+"/image")
+	assert.ErrorIs(t, err, ErrNotHTML)
+}
+
+func TestUnfurlAllowDeny(t *testing.T) {
+	server := newTestServer(t, new(int32))
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host := serverURL.Hostname()
+
+	u := New(Options{AllowPrivate: true, Allow: []string{"example.com"}})
+	_, err = u.Unfurl(context.Background(), server.URL)
+	assert.ErrorIs(t, err, ErrHostNotAllowed)
+
+	u = New(Options{AllowPrivate: true, Allow: []string{host}, Deny: []string{host}})
+	_, err = u.Unfurl(context.Background(), server.URL)
+	assert.ErrorIs(t, err, ErrHostNotAllowed)
+
+	// Redirects are checked too.
+	u = New(Options{AllowPrivate: true, Deny: []string{"*.example.com"}})
+	_, err = u.Unfurl(context.Background(), server.URL+"/redirect?to=http://www.example.com/")
+	assert.ErrorIs(t, err, ErrHostNotAllowed)
+
+	// Private addresses are denied by default.
+	u = New(Options{})
+	_, err = u.Unfurl(context.Background(), server.URL)
+	assert.ErrorIs(t, err, ErrAddrNotAllowed)
+
+	_, err = u.Unfurl(context.Background(), "ftp://example.com/")
+	assert.Error(t, err)
+}
+
+func TestMatchHost(t *testing.T) {
+	patterns := []string{"example.com", "*.example.org"}
+	assert.True(t, matchHost("example.com", patterns))
+	assert.False(t, matchHost("www.example.com", patterns))
+	assert.True(t, matchHost("www.example.org", patterns))
+	assert.False(t, matchHost("example.org", patterns))
+	assert.False(t, matchHost("badexample.org", patterns))
+}
+
+func TestFindURLs(t *testing.T) {
+	assert.Equal(t,
+		[]string{"https://example.com/a?b=c", "http://example.org"},
+		FindURLs("see https://example.com/a?b=c, (http://example.org) and https://example.com/a?b=c."),
+	)
+	assert.Empty(t, FindURLs("no links at example.com"))
+}
@@ -0,0 +1,186 @@
+// Package clock abstracts time, so code waiting for timeouts can be
+// tested with a fake clock.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates timers.
+type Clock interface {
+	Now() time.Time
+	// After is like time.After.
+	After(d time.Duration) <-chan time.Time
+	// NewTimer is like time.NewTimer.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is like time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Real is the system clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t *realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+func (t *realTimer) Reset(d time.Duration) bool {
+	return t.t.Reset(d)
+}
+
+// OrReal returns c, or Real when c is nil.
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// Fake is a clock that only moves with Advance. Timers fire when the
+// clock is advanced past their deadline.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{} // closed and replaced when timers change
+}
+
+// Now returns the time of the fake clock.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{fake: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers that are due
+// in order of their deadline.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	sort.SliceStable(f.timers, func(i, j int) bool {
+		return f.timers[i].deadline.Before(f.timers[j].deadline)
+	})
+	pending := []*fakeTimer{}
+	for _, t := range f.timers {
+		if t.deadline.After(f.now) {
+			pending = append(pending, t)
+			continue
+		}
+		select {
+		case t.c <- t.deadline:
+		default:
+		}
+	}
+	f.setTimers(pending)
+}
+
+// BlockUntil blocks until n timers are waiting, so tests can advance
+// the clock after the code under test started waiting.
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		waiting, changed := len(f.timers), f.changed
+		f.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+// setTimers replaces the timers, f.mu must be held.
+func (f *Fake) setTimers(timers []*fakeTimer) {
+	f.timers = timers
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// remove removes the timer, returning true when it was waiting.
+func (f *Fake) remove(t *fakeTimer) bool {
+	for i, other := range f.timers {
+		if other == t {
+			timers := append([]*fakeTimer{}, f.timers[:i]...)
+			f.setTimers(append(timers, f.timers[i+1:]...))
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	fake     *Fake
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+	return t.fake.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	f := t.fake
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	active := f.remove(t)
+	t.deadline = f.now.Add(d)
+	if d <= 0 {
+		select {
+		case t.c <- t.deadline:
+		default:
+		}
+		return active
+	}
+	f.setTimers(append(f.timers, t))
+	return active
+}
+
+// NewFake returns a fake clock set to t.
+func NewFake(t time.Time) *Fake {
+	return &Fake{now: t, changed: make(chan struct{})}
+}
@@ -0,0 +1,58 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fired(c <-chan time.Time) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestFake(t *testing.T) {
+	start := time.Unix(0, 0)
+	f := NewFake(start)
+	assert.Equal(t, start, f.Now())
+
+	after := f.After(2 * time.Second)
+	timer := f.NewTimer(time.Second)
+	stopped := f.NewTimer(time.Second)
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	f.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), f.Now())
+	assert.True(t, fired(timer.C()))
+	assert.False(t, fired(after))
+	assert.False(t, fired(stopped.C()))
+
+	f.Advance(time.Second)
+	assert.True(t, fired(after))
+
+	assert.False(t, timer.Reset(time.Second))
+	f.Advance(time.Second)
+	assert.True(t, fired(timer.C()))
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+	done := make(chan struct{})
+	go func() {
+		<-f.After(time.Second)
+		close(done)
+	}()
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+}
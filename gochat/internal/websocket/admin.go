@@ -0,0 +1,408 @@
+package websocket
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+)
+
+// Admin HTTP API paths, mirroring the gRPC admin service.
+const (
+	adminPath          = "/admin/"
+	adminExportPath    = "/admin/export"
+	adminRoomsPath     = "/admin/rooms"
+	adminSessionsPath  = "/admin/sessions"
+	adminKickPath      = "/admin/kick"
+	adminNoticePath    = "/admin/notice"
+	adminMembersPath   = "/admin/members"
+	adminRolePath      = "/admin/role"
+	adminBanPath       = "/admin/ban"
+	adminUnbanPath     = "/admin/unban"
+	adminBansPath      = "/admin/bans"
+	adminStatsPath     = "/admin/stats"
+	adminRetentionPath = "/admin/retention"
+)
+
+// adminKickRequest is the body of adminKickPath.
+type adminKickRequest struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// adminKickResponse is the response of adminKickPath.
+type adminKickResponse struct {
+	Sessions int `json:"sessions"`
+}
+
+// adminNoticeRequest is the body of adminNoticePath.
+type adminNoticeRequest struct {
+	Message string `json:"message"`
+}
+
+// adminRoleRequest is the body of adminRolePath.
+type adminRoleRequest struct {
+	Username string    `json:"username"`
+	Role     chat.Role `json:"role"`
+}
+
+// adminBanRequest is the body of adminBanPath.
+type adminBanRequest struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason,omitempty"`
+	// Until is when the ban ends, never when zero.
+	Until time.Time `json:"until,omitempty"`
+}
+
+// adminUnbanRequest is the body of adminUnbanPath.
+type adminUnbanRequest struct {
+	Username string `json:"username"`
+}
+
+// handleAdmin handles the admin HTTP API for the admin token.
+func (s *Server) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With("remoteAddr", r.RemoteAddr)
+	if !chat.AdminAuthorized(r.Header.Get("Authorization"), s.AdminToken) {
+		logger.Infow("reject admin request", "reason", "invalid admin token")
+		http.Error(w, "Invalid admin token", http.StatusUnauthorized)
+		return
+	}
+
+	method := http.MethodGet
+	switch r.URL.Path {
+	case adminKickPath, adminNoticePath, adminRolePath, adminBanPath, adminUnbanPath:
+		method = http.MethodPost
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.URL.Path {
+	case adminExportPath:
+		s.handleAdminExport(w, r)
+	case adminRoomsPath:
+		writeJSON(w, s.hub.Rooms())
+	case adminSessionsPath:
+		writeJSON(w, s.hub.Sessions())
+	case adminKickPath:
+		req := adminKickRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid body", http.StatusBadRequest)
+			return
+		}
+		logger.Infow("admin kick", "username", req.Username, "reason", req.Reason)
+		n, err := s.hub.Kick(req.Username, req.Reason)
+		if err != nil {
+			http.Error(w, err.Error(), adminErrorStatus(err))
+			return
+		}
+		writeJSON(w, adminKickResponse{Sessions: n})
+	case adminNoticePath:
+		req := adminNoticeRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Message == "" {
+			http.Error(w, "Invalid body", http.StatusBadRequest)
+			return
+		}
+		logger.Infow("admin notice", "message", req.Message)
+		s.hub.Notice(req.Message)
+		w.WriteHeader(http.StatusNoContent)
+	case adminMembersPath:
+		members, err := s.hub.Members()
+		if err != nil {
+			http.Error(w, err.Error(), adminErrorStatus(err))
+			return
+		}
+		writeJSON(w, members)
+	case adminRolePath:
+		req := adminRoleRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid body", http.StatusBadRequest)
+			return
+		}
+		logger.Infow("admin set role", "username", req.Username, "role", req.Role)
+		if err := s.hub.SetRole(req.Username, req.Role); err != nil {
+			http.Error(w, err.Error(), adminErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case adminBanPath:
+		req := adminBanRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid body", http.StatusBadRequest)
+			return
+		}
+		logger.Infow("admin ban", "username", req.Username, "reason", req.Reason, "until", req.Until)
+		if err := s.hub.Ban(req.Username, req.Reason, req.Until); err != nil {
+			http.Error(w, err.Error(), adminErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case adminUnbanPath:
+		req := adminUnbanRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid body", http.StatusBadRequest)
+			return
+		}
+		logger.Infow("admin unban", "username", req.Username)
+		if err := s.hub.Unban(req.Username); err != nil {
+			http.Error(w, err.Error(), adminErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case adminBansPath:
+		bans, err := s.hub.Bans()
+		if err != nil {
+			http.Error(w, err.Error(), adminErrorStatus(err))
+			return
+		}
+		writeJSON(w, bans)
+	case adminStatsPath:
+		writeJSON(w, s.hub.Stats())
+	case adminRetentionPath:
+		writeJSON(w, s.hub.PurgeHistory(true))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// adminErrorStatus returns the HTTP status for errors of the hub.
+func adminErrorStatus(err error) int {
+	var notFound *chat.ErrUserNotFound
+	var notBanned *chat.ErrNotBanned
+	var notAuthenticated *chat.ErrNotAuthenticated
+	var invalidRole *chat.ErrInvalidRole
+	switch {
+	case errors.As(err, &notFound), errors.As(err, &notBanned):
+		return http.StatusNotFound
+	case errors.As(err, &notAuthenticated), errors.As(err, &invalidRole):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// handleAdminExport writes the room history as EventHistory JSON for
+// the from and to (RFC 3339) query parameters.
+func (s *Server) handleAdminExport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var from, to time.Time
+	var err error
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			http.Error(w, "Invalid from", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			http.Error(w, "Invalid to", http.StatusBadRequest)
+			return
+		}
+	}
+	s.logger.Infow("admin export", "remoteAddr", r.RemoteAddr, "from", from, "to", to)
+	writeJSON(w, &chat.EventHistory{
+		EventMeta: *chat.NewEventMetaNow(s.hub.Clock()),
+		Events:    s.hub.History(from, to),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// AdminOpts are the options for Export and NewAdminClient.
+type AdminOpts struct {
+	// Token is the admin token of the server.
+	Token string
+	// TLSConfig enables TLS (https) when set.
+	TLSConfig *tls.Config
+}
+
+// Export returns the room history of the server from (inclusive) to
+// (exclusive). Zero times are unbounded.
+func Export(
+	serverAddr string,
+	from time.Time,
+	to time.Time,
+	opts AdminOpts,
+) ([]chat.Event, error) {
+	c, err := NewAdminClient(serverAddr, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	q := url.Values{}
+	if !from.IsZero() {
+		q.Set("from", from.Format(time.RFC3339Nano))
+	}
+	if !to.IsZero() {
+		q.Set("to", to.Format(time.RFC3339Nano))
+	}
+	history := chat.EventHistory{}
+	if err := c.do(http.MethodGet, adminExportPath, q, nil, &history); err != nil {
+		return nil, err
+	}
+	return history.Events, nil
+}
+
+// AdminClient is the client of the admin HTTP API.
+type AdminClient struct {
+	serverAddr string
+	scheme     string
+	token      string
+	client     *http.Client
+}
+
+// NewAdminClient returns a client for the admin HTTP API of the server.
+func NewAdminClient(serverAddr string, opts AdminOpts) (*AdminClient, error) {
+	scheme := "http"
+	if opts.TLSConfig != nil {
+		scheme = "https"
+	}
+	return &AdminClient{
+		serverAddr: serverAddr,
+		scheme:     scheme,
+		token:      opts.Token,
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: opts.TLSConfig},
+		},
+	}, nil
+}
+
+// do sends the request with body (when not nil) as JSON and decodes
+// the JSON response into out (when not nil).
+func (c *AdminClient) do(
+	method string,
+	path string,
+	query url.Values,
+	body any,
+	out any,
+) error {
+	u := url.URL{
+		Scheme:   c.scheme,
+		Host:     c.serverAddr,
+		Path:     path,
+		RawQuery: query.Encode(),
+	}
+	var reqBody io.Reader
+	if body != nil {
+		p, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(p)
+	}
+	req, err := http.NewRequest(method, u.String(), reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		p, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf(
+			"admin request failed: %s: %s",
+			resp.Status,
+			strings.TrimSpace(string(p)),
+		)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *AdminClient) Rooms() ([]chat.RoomInfo, error) {
+	rooms := []chat.RoomInfo{}
+	err := c.do(http.MethodGet, adminRoomsPath, nil, nil, &rooms)
+	return rooms, err
+}
+
+func (c *AdminClient) Sessions() ([]chat.SessionInfo, error) {
+	sessions := []chat.SessionInfo{}
+	err := c.do(http.MethodGet, adminSessionsPath, nil, nil, &sessions)
+	return sessions, err
+}
+
+func (c *AdminClient) Kick(username string, reason string) (int, error) {
+	resp := adminKickResponse{}
+	err := c.do(http.MethodPost, adminKickPath, nil, adminKickRequest{
+		Username: username,
+		Reason:   reason,
+	}, &resp)
+	return resp.Sessions, err
+}
+
+func (c *AdminClient) Notice(message string) error {
+	return c.do(http.MethodPost, adminNoticePath, nil, adminNoticeRequest{
+		Message: message,
+	}, nil)
+}
+
+func (c *AdminClient) Members() ([]chat.Membership, error) {
+	members := []chat.Membership{}
+	err := c.do(http.MethodGet, adminMembersPath, nil, nil, &members)
+	return members, err
+}
+
+func (c *AdminClient) SetRole(username string, role chat.Role) error {
+	return c.do(http.MethodPost, adminRolePath, nil, adminRoleRequest{
+		Username: username,
+		Role:     role,
+	}, nil)
+}
+
+func (c *AdminClient) Ban(username string, reason string, until time.Time) error {
+	return c.do(http.MethodPost, adminBanPath, nil, adminBanRequest{
+		Username: username,
+		Reason:   reason,
+		Until:    until,
+	}, nil)
+}
+
+func (c *AdminClient) Unban(username string) error {
+	return c.do(http.MethodPost, adminUnbanPath, nil, adminUnbanRequest{
+		Username: username,
+	}, nil)
+}
+
+func (c *AdminClient) Bans() ([]chat.Ban, error) {
+	bans := []chat.Ban{}
+	err := c.do(http.MethodGet, adminBansPath, nil, nil, &bans)
+	return bans, err
+}
+
+func (c *AdminClient) Stats() (chat.HubStats, error) {
+	stats := chat.HubStats{}
+	err := c.do(http.MethodGet, adminStatsPath, nil, nil, &stats)
+	return stats, err
+}
+
+func (c *AdminClient) Retention() (chat.RetentionReport, error) {
+	report := chat.RetentionReport{}
+	err := c.do(http.MethodGet, adminRetentionPath, nil, nil, &report)
+	return report, err
+}
+
+func (c *AdminClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
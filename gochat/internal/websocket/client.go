@@ -1,20 +1,45 @@
 package websocket
 
 import (
+	"context"
+	"crypto/tls"
+	"net/http"
 	"net/url"
 
 	ws "github.com/gorilla/websocket"
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/tracing"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ClientOpts are the options for NewClientConnection.
+type ClientOpts struct {
+	// Token is sent as bearer token in the Authorization header, optional.
+	Token string
+	// TLSConfig enables TLS (wss) when set.
+	TLSConfig *tls.Config
+	// Codec is the codec name to ask the server for, JSON when empty.
+	// Falls back to JSON when the server does not support it.
+	Codec string
+	// Keepalive configures pings, DefaultKeepalive when zero.
+	Keepalive KeepaliveOpts
+}
+
 func NewClientConnection(
 	serverAddr string,
 	username string,
 	logger log.Logger,
+	opts ClientOpts,
 ) (*Connection, error) {
+	scheme := "ws"
+	if opts.TLSConfig != nil {
+		scheme = "wss"
+	}
 	q := url.Values{"username": []string{username}}
 	u := url.URL{
-		Scheme:   "ws",
+		Scheme:   scheme,
 		Host:     serverAddr,
 		Path:     "/",
 		RawQuery: q.Encode(),
@@ -23,9 +48,34 @@ func NewClientConnection(
 	logger.Infow(
 		"connecting to server",
 		"serverUrl", serverUrl)
-	wsConn, _, err := ws.DefaultDialer.Dial(serverUrl, nil)
+	dialer := *ws.DefaultDialer
+	dialer.TLSClientConfig = opts.TLSConfig
+	if opts.Codec != "" {
+		codec, err := CodecByName(opts.Codec)
+		if err != nil {
+			return nil, err
+		}
+		dialer.Subprotocols = []string{subprotocolPrefix + codec.Name()}
+	}
+	header := http.Header{}
+	if opts.Token != "" {
+		header.Set("Authorization", "Bearer "+opts.Token)
+	}
+	ctx, span := tracing.Tracer().Start(context.Background(), "websocket.connect",
+		trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	for k, v := range tracing.Inject(ctx) {
+		header.Set(k, v)
+	}
+	wsConn, _, err := dialer.DialContext(ctx, serverUrl, header)
 	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	conn := NewConnection(wsConn, logger, opts.Keepalive)
+	if err := conn.SendEvent(chat.NewEventHello(clock.Real)); err != nil {
+		_ = conn.Close(err)
 		return nil, err
 	}
-	return NewConnection(wsConn, logger), nil
+	return conn, nil
 }
@@ -0,0 +1,141 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes events to websocket messages and back. Codecs are
+// negotiated with the websocket subprotocol, JSON is used when the peer
+// does not ask for one (version 1 peers).
+type Codec interface {
+	// Name returns the codec name, the websocket subprotocol is
+	// the name prefixed with "gochat.".
+	Name() string
+	// MessageType returns the websocket message type used for sending.
+	MessageType() int
+	// Encode encodes the event.
+	Encode(e chat.Event) ([]byte, error)
+	// Decode decodes the event.
+	// Returns chat.ErrUnknownEvent for unknown events.
+	Decode(p []byte) (chat.Event, error)
+}
+
+// Codec names.
+const (
+	CodecJSON     = "json"
+	CodecProtobuf = "protobuf"
+)
+
+const subprotocolPrefix = "gochat."
+
+// Codecs are the supported codecs by name, in order of server preference.
+var Codecs = []Codec{
+	&protobufCodec{},
+	&jsonCodec{},
+}
+
+// CodecByName returns the codec by name.
+// Empty name returns the JSON codec.
+func CodecByName(name string) (Codec, error) {
+	if name == "" {
+		name = CodecJSON
+	}
+	for _, c := range Codecs {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf(`unknown codec "%s"`, name)
+}
+
+// codecBySubprotocol returns the codec for the negotiated subprotocol.
+// Empty subprotocol returns the JSON codec.
+func codecBySubprotocol(subprotocol string) (Codec, error) {
+	if subprotocol == "" {
+		return CodecByName(CodecJSON)
+	}
+	if !strings.HasPrefix(subprotocol, subprotocolPrefix) {
+		return nil, fmt.Errorf(`unknown subprotocol "%s"`, subprotocol)
+	}
+	return CodecByName(strings.TrimPrefix(subprotocol, subprotocolPrefix))
+}
+
+func subprotocols() []string {
+	names := []string{}
+	for _, c := range Codecs {
+		names = append(names, subprotocolPrefix+c.Name())
+	}
+	return names
+}
+
+// jsonCodec encodes events as Message JSON text.
+type jsonCodec struct{}
+
+func (c *jsonCodec) Name() string {
+	return CodecJSON
+}
+
+func (c *jsonCodec) MessageType() int {
+	return ws.TextMessage
+}
+
+func (c *jsonCodec) Encode(e chat.Event) ([]byte, error) {
+	t, ok := chat.Events.ByEvent(e)
+	if !ok {
+		return nil, fmt.Errorf("unknown event type <%s>", reflect.TypeOf(e))
+	}
+	m := Message{
+		Name:    t.Name,
+		Version: chat.ProtocolVersion,
+		Data:    e,
+		Trace:   chat.EventTrace(e),
+	}
+	return json.Marshal(&m)
+}
+
+func (c *jsonCodec) Decode(p []byte) (chat.Event, error) {
+	var m Message
+	if err := json.Unmarshal(p, &m); err != nil {
+		return nil, err
+	}
+	if m.Data == nil {
+		return nil, fmt.Errorf("data was nil after parsing message")
+	}
+	chat.SetEventTrace(m.Data, m.Trace)
+	return m.Data, nil
+}
+
+// protobufCodec encodes events as binary pb.EventEnvelope.
+type protobufCodec struct{}
+
+func (c *protobufCodec) Name() string {
+	return CodecProtobuf
+}
+
+func (c *protobufCodec) MessageType() int {
+	return ws.BinaryMessage
+}
+
+func (c *protobufCodec) Encode(e chat.Event) ([]byte, error) {
+	envelope, err := chat.Events.ToProto(e)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(envelope)
+}
+
+func (c *protobufCodec) Decode(p []byte) (chat.Event, error) {
+	envelope := &pb.EventEnvelope{}
+	if err := proto.Unmarshal(p, envelope); err != nil {
+		return nil, err
+	}
+	return chat.Events.FromProto(envelope)
+}
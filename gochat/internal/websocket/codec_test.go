@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat/chattest"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecsRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, codec := range Codecs {
+		codec := codec
+		t.Run(codec.Name(), func(t *testing.T) {
+			for _, et := range chat.Events.Types() {
+				for i := 0; i < 100; i++ {
+					e := chattest.NewRandomEvent(r, et)
+					p, err := codec.Encode(e)
+					require.NoError(t, err)
+					decoded, err := codec.Decode(p)
+					require.NoError(t, err)
+					require.Equal(t, e, decoded)
+				}
+			}
+		})
+	}
+}
+
+func TestCodecsTrace(t *testing.T) {
+	carrier := map[string]string{
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	}
+	for _, codec := range Codecs {
+		e := &chat.EventSendMessage{Message: "hi"}
+		chat.SetEventTrace(e, carrier)
+		p, err := codec.Encode(e)
+		require.NoError(t, err)
+		decoded, err := codec.Decode(p)
+		require.NoError(t, err)
+		assert.Equal(t, carrier, chat.EventTrace(decoded), codec.Name())
+	}
+}
+
+func TestCodecBySubprotocol(t *testing.T) {
+	c, err := codecBySubprotocol("")
+	require.NoError(t, err)
+	assert.Equal(t, CodecJSON, c.Name())
+
+	c, err = codecBySubprotocol("gochat.protobuf")
+	require.NoError(t, err)
+	assert.Equal(t, CodecProtobuf, c.Name())
+
+	_, err = codecBySubprotocol("gochat.carrierPigeon")
+	assert.Error(t, err)
+	_, err = codecBySubprotocol("protobuf")
+	assert.Error(t, err)
+}
+
+func BenchmarkCodecs(b *testing.B) {
+	e := &chat.EventNewMessage{
+		EventMeta: *chat.NewEventMetaNow(clock.Real),
+		Sender:    "user1",
+		Message:   "Lorem ipsum dolor sit amet, consectetur adipiscing elit, @user2",
+	}
+	for _, codec := range Codecs {
+		codec := codec
+		p, err := codec.Encode(e)
+		require.NoError(b, err)
+
+		b.Run(codec.Name()+"/encode", func(b *testing.B) {
+			b.SetBytes(int64(len(p)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := codec.Encode(e); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(codec.Name()+"/decode", func(b *testing.B) {
+			b.SetBytes(int64(len(p)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := codec.Decode(p); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkConnection(b *testing.B) {
+	for _, codec := range Codecs {
+		codec := codec
+		b.Run(codec.Name(), func(b *testing.B) {
+			serverConns := make(chan *Connection, 1)
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					wsConn, err := upgrader.Upgrade(w, r, nil)
+					if err != nil {
+						return
+					}
+					conn := NewConnection(wsConn, test.NewTestLogger(true), KeepaliveOpts{})
+					serverConns <- conn
+					_ = conn.Wait()
+				},
+			))
+			defer server.Close()
+
+			addr := strings.TrimPrefix(server.URL, "http://")
+			conn, err := NewClientConnection(
+				addr,
+				"user",
+				test.NewTestLogger(true),
+				ClientOpts{Codec: codec.Name()},
+			)
+			require.NoError(b, err)
+			defer conn.Close(nil)
+			serverConn := <-serverConns
+			defer serverConn.Close(nil)
+			_, err = serverConn.ReadEvent() // hello
+			require.NoError(b, err)
+
+			e := &chat.EventSendMessage{
+				EventMeta: *chat.NewEventMetaNow(clock.Real),
+				Message:   "Lorem ipsum dolor sit amet, consectetur adipiscing elit",
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			go func() {
+				for i := 0; i < b.N; i++ {
+					if err := conn.SendEvent(e); err != nil {
+						return
+					}
+				}
+			}()
+			for i := 0; i < b.N; i++ {
+				if _, err := serverConn.ReadEvent(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
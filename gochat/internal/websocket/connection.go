@@ -2,24 +2,27 @@ package websocket
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
 	ws "github.com/gorilla/websocket"
 	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Connection struct {
 	logger     log.Logger
 	wsConn     *ws.Conn
+	codec      Codec
 	eventOutCh chan chat.Event
 	l          sync.RWMutex
 	closed     chan struct{}
 	error      error
+	keepalive  KeepaliveOpts
 }
 
 func (c *Connection) SendEvent(e chat.Event) error {
@@ -33,29 +36,23 @@ func (c *Connection) SendEvent(e chat.Event) error {
 	default:
 	}
 
-	m := Message{Data: e}
-	eType := reflect.TypeOf(e)
-	eTypeStr := eType.String()
+	eTypeStr := reflect.TypeOf(e).String()
 
-	for name, handler := range handlers {
-		if reflect.TypeOf(handler()) == eType {
-			m.Name = name
-			break
-		}
-	}
-
-	if m.Name == "" {
-		return fmt.Errorf("unknown event type <%s>", eTypeStr)
+	if chat.EventTrace(e) != nil {
+		ctx, span := chat.StartEventSpan(e, "websocket.send",
+			trace.WithSpanKind(trace.SpanKindProducer))
+		defer span.End()
+		e = chat.WithTrace(e, ctx)
 	}
 
-	jsonText, err := json.Marshal(&m)
+	p, err := c.codec.Encode(e)
 	if err != nil {
 		return fmt.Errorf(
-			"could not marshal event with type <%s>: %w",
+			"could not encode event with type <%s>: %w",
 			eTypeStr, err)
 	}
 
-	err = c.wsConn.WriteMessage(ws.TextMessage, jsonText)
+	err = c.wsConn.WriteMessage(c.codec.MessageType(), p)
 
 	if err != nil {
 		return fmt.Errorf(
@@ -100,6 +97,35 @@ func (c *Connection) Close(err error) error {
 	}
 }
 
+// maxCloseReason is the maximum length of close frame reasons, the
+// control frame payload limit minus the close code.
+const maxCloseReason = 123
+
+// Reject closes the connection with a close frame with the reason, for
+// connections the hub did not accept. Waits for the close frame of the
+// other side, at most the keepalive timeout, skipping its events.
+func (c *Connection) Reject(reason error) error {
+	text := reason.Error()
+	if len(text) > maxCloseReason {
+		text = text[:maxCloseReason]
+	}
+	timeout := time.After(c.keepalive.Timeout)
+	_ = c.wsConn.WriteControl(
+		ws.CloseMessage,
+		ws.FormatCloseMessage(ws.ClosePolicyViolation, text),
+		time.Now().Add(c.keepalive.Timeout),
+	)
+	for {
+		select {
+		case <-c.eventOutCh:
+		case <-c.closed:
+			return nil
+		case <-timeout:
+			return c.Close(reason)
+		}
+	}
+}
+
 func (c *Connection) Closed() bool {
 	select {
 	case <-c.closed:
@@ -120,43 +146,75 @@ func (c *Connection) wsReadPump() error {
 			return err
 		}
 
+		c.alive()
+
 		switch messageType {
-		case ws.TextMessage:
-			var m Message
-			if err := json.Unmarshal(p, &m); err != nil {
-				return fmt.Errorf(`could not unmarshal message: %w`, err)
+		case ws.TextMessage, ws.BinaryMessage:
+			var unknownErr *chat.ErrUnknownEvent
+			e, err := c.codec.Decode(p)
+			if errors.As(err, &unknownErr) {
+				// Forward compatible: skip events we don't know.
+				c.logger.Warnw("skipping unknown event", log.Error(err))
+				continue
 			}
-			if m.Data == nil {
-				return fmt.Errorf("data was nil after parsing message")
+			if err != nil {
+				return fmt.Errorf(`could not decode message: %w`, err)
 			}
 			select {
 			case <-c.closed:
 				return chat.ErrConnectionClosed
-			case c.eventOutCh <- m.Data:
+			case c.eventOutCh <- e:
 				//
 			}
 		}
 	}
 }
 
+// RemoteAddr returns the address of the other side.
+func (c *Connection) RemoteAddr() string {
+	return c.wsConn.RemoteAddr().String()
+}
+
+// Transport returns "websocket".
+func (c *Connection) Transport() string {
+	return "websocket"
+}
+
+// NewConnection returns a connection for the websocket, pinging the
+// other side with the keepalive options.
 func NewConnection(
 	wsConn *ws.Conn,
 	logger log.Logger,
+	keepalive KeepaliveOpts,
 ) *Connection {
-	conn := Connection{
+	codec, err := codecBySubprotocol(wsConn.Subprotocol())
+	if err != nil {
+		logger.Warnw("falling back to json codec", log.Error(err))
+		codec = &jsonCodec{}
+	}
+	conn := &Connection{
 		logger:     logger,
 		wsConn:     wsConn,
+		codec:      codec,
 		eventOutCh: make(chan chat.Event),
 		closed:     make(chan struct{}),
+		keepalive:  keepalive.orDefault(),
 	}
+	conn.startKeepalive()
 	go func() {
-		defer conn.Close(nil)
 		err := conn.wsReadPump()
+		// Keep the reason the other side closed with, like rejections.
+		var closeErr *ws.CloseError
+		if errors.As(err, &closeErr) && closeErr.Code != ws.CloseNormalClosure {
+			_ = conn.Close(err)
+		} else {
+			_ = conn.Close(nil)
+		}
 		if errors.Is(err, chat.ErrConnectionClosed) {
 			logger.Infow("websocket pump closed")
 		} else {
 			logger.Errorw("websocket pump error", log.Error(err))
 		}
 	}()
-	return &conn
+	return conn
 }
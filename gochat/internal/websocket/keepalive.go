@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+)
+
+// KeepaliveOpts configure the pings of connections.
+type KeepaliveOpts struct {
+	// Interval is the time between pings, DefaultKeepalive.Interval
+	// when zero.
+	Interval time.Duration
+	// Timeout is how long to wait for the other side after a ping
+	// before closing, DefaultKeepalive.Timeout when zero.
+	Timeout time.Duration
+}
+
+// DefaultKeepalive are the default keepalive options.
+var DefaultKeepalive = KeepaliveOpts{
+	Interval: 30 * time.Second,
+	Timeout:  10 * time.Second,
+}
+
+// orDefault returns the options with defaults for zero values.
+func (o KeepaliveOpts) orDefault() KeepaliveOpts {
+	if o.Interval == 0 {
+		o.Interval = DefaultKeepalive.Interval
+	}
+	if o.Timeout == 0 {
+		o.Timeout = DefaultKeepalive.Timeout
+	}
+	return o
+}
+
+// readWait is how long to wait for the next message, ping or pong.
+func (o KeepaliveOpts) readWait() time.Duration {
+	return o.Interval + o.Timeout
+}
+
+// alive extends the read deadline, called for every message, ping
+// and pong received.
+func (c *Connection) alive() {
+	_ = c.wsConn.SetReadDeadline(time.Now().Add(c.keepalive.readWait()))
+}
+
+// startKeepalive sets the ping and pong handlers and sends pings until
+// the connection closes. Both sides ping, so both detect dead peers.
+func (c *Connection) startKeepalive() {
+	c.alive()
+	c.wsConn.SetPongHandler(func(string) error {
+		c.alive()
+		return nil
+	})
+	c.wsConn.SetPingHandler(func(data string) error {
+		c.alive()
+		err := c.wsConn.WriteControl(
+			ws.PongMessage,
+			[]byte(data),
+			time.Now().Add(c.keepalive.Timeout),
+		)
+		if err == ws.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	go func() {
+		ticker := time.NewTicker(c.keepalive.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.closed:
+				return
+			case <-ticker.C:
+				err := c.wsConn.WriteControl(
+					ws.PingMessage,
+					nil,
+					time.Now().Add(c.keepalive.Timeout),
+				)
+				if err != nil {
+					c.logger.Debugw("could not send ping", log.Error(err))
+					return
+				}
+			}
+		}
+	}()
+}
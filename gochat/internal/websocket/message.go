@@ -2,19 +2,25 @@ package websocket
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
 )
 
 type Message struct {
-	Name string     `json:"name"`
-	Data chat.Event `json:"data"`
+	Name string `json:"name"`
+	// Version is the protocol version of the sender,
+	// omitted by version 1 peers.
+	Version int        `json:"v,omitempty"`
+	Data    chat.Event `json:"data"`
+	// Trace is the trace context of the event, see chat.EventMeta.
+	Trace map[string]string `json:"trace,omitempty"`
 }
 
 type MessageRaw struct {
-	Name string           `json:"name"`
-	Data *json.RawMessage `json:"data"`
+	Name    string            `json:"name"`
+	Version int               `json:"v,omitempty"`
+	Data    *json.RawMessage  `json:"data"`
+	Trace   map[string]string `json:"trace,omitempty"`
 }
 
 func (m *Message) UnmarshalJSON(data []byte) error {
@@ -23,13 +29,16 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	handler := handlers[raw.Name]
-	if handler == nil {
-		return fmt.Errorf(`unknown event name "%s"`, raw.Name)
+	m.Name = raw.Name
+	m.Version = raw.Version
+	m.Trace = raw.Trace
+
+	t, ok := chat.Events.ByName(raw.Name)
+	if !ok {
+		return &chat.ErrUnknownEvent{Name: raw.Name, Version: raw.Version}
 	}
 
-	m.Name = raw.Name
-	m.Data = handler()
+	m.Data = t.New()
 
 	if err := json.Unmarshal(*raw.Data, &m.Data); err != nil {
 		return err
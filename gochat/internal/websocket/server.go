@@ -1,43 +1,99 @@
 package websocket
 
 import (
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 
 	ws "github.com/gorilla/websocket"
 	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var upgrader = ws.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    subprotocols(),
 }
 
 type Server struct {
-	logger   log.Logger
-	upgrader ws.Upgrader
-	hub      *chat.Hub
+	// AdminToken enables the admin HTTP API for this bearer token,
+	// HubOpts.AdminToken by default.
+	AdminToken string
+	// Keepalive configures pings, DefaultKeepalive when zero.
+	Keepalive  KeepaliveOpts
+	logger     log.Logger
+	upgrader   ws.Upgrader
+	hub        *chat.Hub
+	httpServer *http.Server
+	mu         sync.Mutex
 }
 
-func NewServer(logger log.Logger) *Server {
+// NewServer returns a server with a new hub.
+func NewServer(logger log.Logger, hubOpts chat.HubOpts) *Server {
 	return &Server{
 		logger:   logger,
-		hub:      chat.NewHub(logger),
+		hub:      chat.NewHub(logger, hubOpts),
 		upgrader: upgrader,
+
+		AdminToken: hubOpts.AdminToken,
 	}
 }
 
+// Hub returns the hub of the server.
+func (s *Server) Hub() *chat.Hub {
+	return s.hub
+}
+
 func (s *Server) Start(addr string) error {
 	logger := s.logger
 	logger.Infow("starting server", "addr", addr)
-	err := http.ListenAndServe(addr, http.HandlerFunc(s.handleHttp))
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// Serve serves on the listener until Stop, for example on an ephemeral
+// port in tests.
+func (s *Server) Serve(lis net.Listener) error {
+	err := s.newHttpServer().Serve(lis)
+	if err == http.ErrServerClosed {
+		return nil
+	}
 	return err
 }
 
+// Stop stops serving. Connected websockets are not closed.
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.httpServer != nil {
+		return s.httpServer.Close()
+	}
+	return nil
+}
+
+func (s *Server) newHttpServer() *http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.httpServer = &http.Server{Handler: http.HandlerFunc(s.handleHttp)}
+	return s.httpServer
+}
+
 func (s *Server) handleHttp(w http.ResponseWriter, r *http.Request) {
 	logger := s.logger.With("remoteAddr", r.RemoteAddr)
 	logger.Info("http request")
 
+	if strings.HasPrefix(r.URL.Path, adminPath) {
+		s.handleAdmin(w, r)
+		return
+	}
+
 	username := r.URL.Query().Get("username")
 	if username == "" {
 		logger.Infow(
@@ -57,19 +113,29 @@ func (s *Server) handleHttp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx := tracing.Extract(r.Context(), tracing.Carrier(r.Header.Get))
+	ctx, span := tracing.Tracer().Start(ctx, "websocket.connect",
+		trace.WithSpanKind(trace.SpanKindServer))
+	logger = logger.WithContext(ctx)
 	logger.Infow("new websocket connection")
-	conn := NewConnection(wsConn, logger)
-	userId, err := s.hub.Connect(username, conn)
+	conn := NewConnection(wsConn, logger, s.Keepalive)
+	identity := chat.IdentityFromAuthorization(r.Header.Get("Authorization"))
+	sessionId, err := s.hub.ConnectIdentity(identity, username, conn)
 	if err != nil {
 		logger.Errorw(
 			"could not connect to hub",
 			log.Error(err),
 		)
+		span.RecordError(err)
+		span.End()
+		_ = conn.Reject(err)
+		return
 	}
+	span.End()
 
 	defer conn.Close(nil)
 	defer func() {
-		_ = s.hub.Disconnect(userId)
+		_ = s.hub.Disconnect(sessionId)
 	}()
 
 	if err := conn.Wait(); err != nil {
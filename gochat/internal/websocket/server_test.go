@@ -1,7 +1,9 @@
 package websocket
 
 import (
+	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -9,8 +11,9 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
-	"github.com/marcelbeumer/go-playground/gochat/internal/util/now"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
 	"github.com/marcelbeumer/go-playground/gochat/internal/util/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,7 +22,7 @@ import (
 func TestServer(t *testing.T) {
 
 	t.Run("GET / should 400 not having an username", func(t *testing.T) {
-		wsServer := NewServer(&log.NoopLoggerAdapter{})
+		wsServer := NewServer(&log.NoopLoggerAdapter{}, chat.HubOpts{})
 		req := httptest.NewRequest("get", "/", nil)
 		w := httptest.NewRecorder()
 		wsServer.handleHttp(w, req)
@@ -32,7 +35,7 @@ func TestServer(t *testing.T) {
 	})
 
 	t.Run("websocket on / should 400 not having an username", func(t *testing.T) {
-		wsServer := NewServer(test.NewTestLogger(true))
+		wsServer := NewServer(test.NewTestLogger(true), chat.HubOpts{})
 		server := httptest.NewServer(http.HandlerFunc(wsServer.handleHttp))
 
 		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/"
@@ -54,7 +57,7 @@ func TestServer(t *testing.T) {
 	})
 
 	t.Run("GET /?username=User should connect a websocket", func(t *testing.T) {
-		wsServer := NewServer(test.NewTestLogger(true))
+		wsServer := NewServer(test.NewTestLogger(true), chat.HubOpts{})
 		server := httptest.NewServer(http.HandlerFunc(wsServer.handleHttp))
 
 		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/?username=User"
@@ -63,19 +66,40 @@ func TestServer(t *testing.T) {
 		defer wsConn.Close()
 	})
 
-	t.Run("sets up hub communication", func(t *testing.T) {
-		nowStub := now.SetupStub()
-		nowStub.Frozen = true
-		t.Cleanup(func() {
-			now.ClearStub()
-		})
+	t.Run("rejects usernames the hub does not accept", func(t *testing.T) {
+		wsServer := NewServer(test.NewTestLogger(true), chat.HubOpts{})
+		server := httptest.NewServer(http.HandlerFunc(wsServer.handleHttp))
+		defer server.Close()
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/?username="
+
+		wsConn, _, err := websocket.DefaultDialer.Dial(wsURL+"User", nil)
+		require.NoError(t, err)
+		defer wsConn.Close()
+		for username, reason := range map[string]string{
+			"user":      `user "user" already exists`,
+			"not+valid": `invalid name "not valid"`,
+		} {
+			rejected, _, err := websocket.DefaultDialer.Dial(wsURL+username, nil)
+			require.NoError(t, err)
+			_ = rejected.SetReadDeadline(time.Now().Add(time.Second))
+			_, _, err = rejected.ReadMessage()
+			var closeErr *websocket.CloseError
+			require.ErrorAs(t, err, &closeErr)
+			assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+			assert.Equal(t, reason, closeErr.Text)
+			rejected.Close()
+		}
+		assert.Len(t, wsServer.Hub().Sessions(), 1)
+	})
 
-		wsServer := NewServer(test.NewTestLogger(true))
+	t.Run("sets up hub communication", func(t *testing.T) {
+		fake := clock.NewFake(time.UnixMilli(0).UTC())
+		wsServer := NewServer(test.NewTestLogger(true), chat.HubOpts{Clock: fake})
 
 		server := httptest.NewServer(http.HandlerFunc(wsServer.handleHttp))
 		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/?username=User"
 
-		nowStub.Inc()
+		fake.Advance(time.Second)
 		wsConn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
 		require.NoError(t, err)
 		defer wsConn.Close()
@@ -84,21 +108,199 @@ func TestServer(t *testing.T) {
 		messageType, p, err := wsConn.ReadMessage()
 		require.NoError(t, err)
 		require.Equal(t, websocket.TextMessage, messageType)
-		msg := `{"name":"connected","data":{"time":"1970-01-01T01:00:01+01:00","users":["User"]}}`
+		msg := `{"name":"connected","v":2,"data":{"time":"1970-01-01T00:00:01Z","users":["User"]}}`
 		require.Equal(t, msg, string(p))
 
-		nowStub.Inc()
-		msg = `{"name":"sendMessage","data":{"time":"1970-01-01T01:00:02+01:00","message":"Hello"}}`
+		fake.Advance(time.Second)
+		msg = `{"name":"sendMessage","data":{"time":"1970-01-01T00:00:02Z","message":"Hello"}}`
 		_ = wsConn.SetWriteDeadline(time.Now().Add(time.Second))
 		err = wsConn.WriteMessage(websocket.TextMessage, []byte(msg))
 		require.NoError(t, err)
 
-		nowStub.Inc()
+		fake.Advance(time.Second)
 		_ = wsConn.SetReadDeadline(time.Now().Add(time.Second))
 		messageType, p, err = wsConn.ReadMessage()
 		require.NoError(t, err)
 		require.Equal(t, websocket.TextMessage, messageType)
-		msg = `{"name":"newMessage","data":{"time":"1970-01-01T01:00:03+01:00","sender":"User","message":"Hello"}}`
+		msg = `{"name":"newMessage","v":2,"data":{"time":"1970-01-01T00:00:03Z","id":"m1","sender":"User","message":"Hello"}}`
 		require.Equal(t, msg, string(p))
 	})
+	t.Run("negotiates protocol and skips unknown events", func(t *testing.T) {
+		fake := clock.NewFake(time.UnixMilli(0).UTC())
+		wsServer := NewServer(test.NewTestLogger(true), chat.HubOpts{Clock: fake})
+
+		server := httptest.NewServer(http.HandlerFunc(wsServer.handleHttp))
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/?username=User"
+
+		fake.Advance(time.Second)
+		wsConn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer wsConn.Close()
+
+		write := func(msg string) {
+			_ = wsConn.SetWriteDeadline(time.Now().Add(time.Second))
+			err := wsConn.WriteMessage(websocket.TextMessage, []byte(msg))
+			require.NoError(t, err)
+		}
+		read := func() string {
+			_ = wsConn.SetReadDeadline(time.Now().Add(time.Second))
+			_, p, err := wsConn.ReadMessage()
+			require.NoError(t, err)
+			return string(p)
+		}
+
+		read() // connected
+
+		write(`{"name":"hello","v":3,"data":{"time":"1970-01-01T00:00:01Z","version":3,"features":["mentions","fromTheFuture"]}}`)
+		msg := `{"name":"hello","v":2,"data":{"time":"1970-01-01T00:00:01Z","version":2,"features":["mentions"]}}`
+		require.Equal(t, msg, read())
+
+		write(`{"name":"fromTheFuture","v":3,"data":{"time":"1970-01-01T00:00:01Z"}}`)
+		write(`{"name":"sendMessage","v":3,"data":{"time":"1970-01-01T00:00:01Z","message":"Hello"}}`)
+		msg = `{"name":"newMessage","v":2,"data":{"time":"1970-01-01T00:00:01Z","id":"m1","sender":"User","message":"Hello"}}`
+		require.Equal(t, msg, read())
+	})
+	t.Run("negotiates codec with subprotocol", func(t *testing.T) {
+		wsServer := NewServer(test.NewTestLogger(true), chat.HubOpts{})
+		server := httptest.NewServer(http.HandlerFunc(wsServer.handleHttp))
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/?username=User"
+
+		dialer := *websocket.DefaultDialer
+		dialer.Subprotocols = []string{"gochat.msgpack", "gochat.protobuf"}
+		wsConn, _, err := dialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer wsConn.Close()
+		require.Equal(t, "gochat.protobuf", wsConn.Subprotocol())
+
+		_ = wsConn.SetReadDeadline(time.Now().Add(time.Second))
+		messageType, p, err := wsConn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, websocket.BinaryMessage, messageType)
+		e, err := (&protobufCodec{}).Decode(p)
+		require.NoError(t, err)
+		assert.IsType(t, &chat.EventConnected{}, e)
+	})
+
+	t.Run("closes connections of peers not answering pings", func(t *testing.T) {
+		wsServer := NewServer(test.NewTestLogger(true), chat.HubOpts{})
+		wsServer.Keepalive = KeepaliveOpts{
+			Interval: 20 * time.Millisecond,
+			Timeout:  20 * time.Millisecond,
+		}
+		server := httptest.NewServer(http.HandlerFunc(wsServer.handleHttp))
+		defer server.Close()
+
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/?username="
+		live, _, err := websocket.DefaultDialer.Dial(wsURL+"live", nil)
+		require.NoError(t, err)
+		defer live.Close()
+		go func() {
+			for {
+				if _, _, err := live.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+		dead, _, err := websocket.DefaultDialer.Dial(wsURL+"dead", nil)
+		require.NoError(t, err)
+		defer dead.Close()
+		dead.SetPingHandler(func(string) error { return nil })
+		_ = dead.SetReadDeadline(time.Now().Add(time.Second))
+		for err == nil {
+			_, _, err = dead.ReadMessage()
+		}
+		var netErr net.Error
+		require.False(t, errors.As(err, &netErr) && netErr.Timeout(), err)
+
+		assert.Eventually(t, func() bool {
+			sessions := wsServer.Hub().Sessions()
+			return len(sessions) == 1 && sessions[0].Username == "live"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("evicts idle sessions that answer pings", func(t *testing.T) {
+		fake := clock.NewFake(time.UnixMilli(0))
+		wsServer := NewServer(test.NewTestLogger(true), chat.HubOpts{
+			Clock:       fake,
+			IdleTimeout: 10 * time.Minute,
+		})
+		wsServer.Keepalive = KeepaliveOpts{
+			Interval: 10 * time.Millisecond,
+			Timeout:  time.Second,
+		}
+		server := httptest.NewServer(http.HandlerFunc(wsServer.handleHttp))
+		defer server.Close()
+
+		addr := strings.TrimPrefix(server.URL, "http://")
+		conn, err := NewClientConnection(addr, "idle", test.NewTestLogger(true), ClientOpts{
+			Keepalive: KeepaliveOpts{Interval: 10 * time.Millisecond, Timeout: time.Second},
+		})
+		require.NoError(t, err)
+		defer conn.Close(nil)
+		e, err := conn.ReadEvent()
+		require.NoError(t, err)
+		require.IsType(t, &chat.EventConnected{}, e)
+		time.Sleep(50 * time.Millisecond) // pings going back and forth
+
+		fake.BlockUntil(1)
+		fake.Advance(5 * time.Minute)
+		fake.BlockUntil(1)
+		fake.Advance(6 * time.Minute)
+		notices := []string{}
+		err = test.GoTimeout(t, func() error {
+			for {
+				e, err := conn.ReadEvent()
+				if err != nil {
+					return nil
+				}
+				if n, ok := e.(*chat.EventNotice); ok {
+					notices = append(notices, n.Message)
+				}
+			}
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"disconnected: " + chat.ErrIdleTimeout.Error()}, notices)
+		assert.Empty(t, wsServer.Hub().Sessions())
+	})
+
+	t.Run("connects with TLS", func(t *testing.T) {
+		wsServer := NewServer(test.NewTestLogger(true), chat.HubOpts{})
+		server := httptest.NewTLSServer(http.HandlerFunc(wsServer.handleHttp))
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "https://")
+
+		conn, err := NewClientConnection(addr, "User", test.NewTestLogger(true), ClientOpts{
+			TLSConfig: server.Client().Transport.(*http.Transport).TLSClientConfig,
+		})
+		require.NoError(t, err)
+		defer conn.Close(nil)
+		e, err := conn.ReadEvent()
+		require.NoError(t, err)
+		assert.IsType(t, &chat.EventConnected{}, e)
+	})
+
+	t.Run("exports history with the admin token", func(t *testing.T) {
+		wsServer := NewServer(test.NewTestLogger(true), chat.HubOpts{})
+		wsServer.AdminToken = "secret"
+		server := httptest.NewServer(http.HandlerFunc(wsServer.handleHttp))
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+
+		wsURL := "ws://" + addr + "/?username=User"
+		wsConn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer wsConn.Close()
+		_ = wsConn.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err = wsConn.ReadMessage() // connected
+		require.NoError(t, err)
+
+		_, err = Export(addr, time.Time{}, time.Time{}, AdminOpts{Token: "wrong"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "401")
+
+		events, err := Export(addr, time.Time{}, time.Time{}, AdminOpts{Token: "secret"})
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "User", events[0].(*chat.EventUserEnter).Name)
+	})
 }
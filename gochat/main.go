@@ -2,13 +2,21 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/config"
 	"github.com/marcelbeumer/go-playground/gochat/internal/grpc"
 	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/tracing"
+	"github.com/marcelbeumer/go-playground/gochat/internal/unfurl"
 	"github.com/marcelbeumer/go-playground/gochat/internal/websocket"
 )
 
@@ -19,20 +27,95 @@ type ClientServerOpts struct {
 }
 
 type ClientOpts struct {
-	Username       string `help:"Username."                   required:"" short:"u"`
-	StdoutFrontend bool   `help:"Use simple stdout frontend."             short:"s"`
+	Profile        string `help:"Config profile to use. Flags and env override its values."`
+	Username       string `help:"Username (required here or in the profile)."                  short:"u"`
+	Token          string `help:"Authentication token."                                                  env:"GOCHAT_TOKEN"`
+	Tls            bool   `help:"Use TLS."`
+	TlsCa          string `help:"TLS CA file to verify the server with (implies --tls)."                                     type:"path"`
+	TlsCert        string `help:"TLS client certificate file."                                                               type:"path"`
+	TlsKey         string `help:"TLS client key file."                                                                       type:"path"`
+	Codec          string `help:"Websocket codec (json, protobuf)."                            enum:"json,protobuf" default:"json"`
+	StdoutFrontend bool   `help:"Use simple stdout frontend."                                  short:"s"`
+	Plain          bool   `help:"Strip message formatting in the stdout frontend."`
+	Format         string `help:"Stdout frontend format (text, json), json implies --stdout-frontend." enum:"text,json" default:"text"`
+	Bell           bool   `help:"Ring the terminal bell when mentioned."`
+	NotifyCmd      string `help:"Shell command to run when mentioned (gets GOCHAT_SENDER, GOCHAT_MESSAGE env)."`
+	GuiConfig      string `help:"GUI theme and layout config file, reloaded on SIGHUP (default: <user config dir>/gochat/gui.yaml)." type:"path"`
+	Record         string `help:"Record events to file as JSON lines, see the replay command."                                type:"path"`
+}
+
+type ServerOpts struct {
+	AdminToken  string   `help:"Enable the admin API for this token." env:"GOCHAT_ADMIN_TOKEN"`
+	Middleware  []string `help:"Hub middleware by name (audit, profanity, unfurl), outermost first."`
+	UnfurlAllow []string `help:"Hosts to unfurl links of with the unfurl middleware (default all), like example.com or *.example.com."`
+	UnfurlDeny  []string `help:"Hosts not to unfurl links of, wins from --unfurl-allow."`
+
+	OfflineDir       string        `help:"Directory for mentions of offline authenticated users (default: <user config dir>/gochat/offline)." type:"path"`
+	OfflineRetention time.Duration `help:"How long to keep mentions of offline users."                                                        default:"168h"`
+	NoOffline        bool          `help:"Disable offline delivery."`
+
+	IdleTimeout time.Duration `help:"Disconnect sessions that sent no events for this long (0 disables)."`
+
+	ServerConfig string `help:"Server config file with retention policies (default: <user config dir>/gochat/server.yaml)." type:"path"`
+
+	Store     string `help:"Storage of memberships and history (memory, bolt)."            enum:"memory,bolt" default:"memory"`
+	StorePath string `help:"File of the bolt store (default: <user config dir>/gochat/gochat.db)." type:"path"`
+}
+
+type KeepaliveOpts struct {
+	KeepaliveTime    time.Duration `help:"Idle time after which to ping the other side." default:"30s"`
+	KeepaliveTimeout time.Duration `help:"How long to wait for a ping response."         default:"10s"`
+}
+
+type TraceOpts struct {
+	Trace         string `help:"Trace exporter (none, stdout, otlp)."                   enum:"none,stdout,otlp" default:"none"`
+	TraceEndpoint string `help:"OTLP gRPC endpoint (default: OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317)."`
+	TraceInsecure bool   `help:"Connect to the OTLP endpoint without TLS."`
 }
 
 type Commands struct {
-	Verbose     bool `help:"Verbose (logging info)"       short:"v"`
-	VeryVerbose bool `help:"Very verbose (logging debug)" short:"V"`
+	Verbose     bool   `help:"Verbose (logging info)"       short:"v"`
+	VeryVerbose bool   `help:"Very verbose (logging debug)" short:"V"`
+	ConfigFile  string `help:"Client config file (default: <user config dir>/gochat/config.yaml)." type:"path"`
 	Client      struct {
 		ClientServerOpts
 		ClientOpts
+		KeepaliveOpts
+		TraceOpts
 	} `help:"Start client"                           cmd:"client"`
 	Server struct {
 		ClientServerOpts
+		ServerOpts
+		KeepaliveOpts
+		TraceOpts
 	} `help:"Start server"                           cmd:"client"`
+	Config struct {
+		List struct {
+		} `help:"List profiles"         cmd:""`
+		Add struct {
+			Name string `help:"Profile name." arg:""`
+			ProfileOpts
+		} `help:"Add or replace profile" cmd:""`
+		Remove struct {
+			Name string `help:"Profile name." arg:""`
+		} `help:"Remove profile"        cmd:""`
+	} `help:"Manage client config profiles"         cmd:""`
+	Replay struct {
+		ReplayOpts
+	} `help:"Replay a recording made with client --record" cmd:""`
+	Bench struct {
+		ClientServerOpts
+		BenchOpts
+	} `help:"Load test a server with simulated clients" cmd:""`
+	Export struct {
+		ClientServerOpts
+		ExportOpts
+	} `help:"Export the room history (needs the server admin token)" cmd:""`
+	Admin struct {
+		ClientServerOpts
+		AdminOpts
+		AdminCommands
+	} `help:"Inspect and control a running server (needs the server admin token)" cmd:""`
 }
 
 func main() {
@@ -45,6 +128,12 @@ func main() {
 
 	switch ctx.Command() {
 
+	case "config list", "config add <name>", "config remove <name>":
+		if err := runConfigCommand(ctx.Command(), &cli); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
 	case "client":
 		stdErrBuf := bufio.NewWriter(os.Stderr)
 		zl := log.NewZapLogger(stdErrBuf, cli.Verbose, cli.VeryVerbose)
@@ -59,15 +148,73 @@ func main() {
 
 		defer exit(0)
 
-		addr := fmt.Sprintf("%s:%d", cli.Server.Host, cli.Server.Port)
+		shutdownTracing, err := tracing.Setup(context.Background(), tracing.Opts{
+			Exporter:    cli.Client.Trace,
+			Endpoint:    cli.Client.TraceEndpoint,
+			Insecure:    cli.Client.TraceInsecure,
+			ServiceName: "gochat-client",
+			// Stdout belongs to the frontend.
+			Writer: stdErrBuf,
+		})
+		if err != nil {
+			logger.Errorw("could not set up tracing", log.Error(err))
+			exit(1)
+		}
+		defer func() { _ = shutdownTracing(context.Background()) }()
+
+		if err := applyProfile(ctx, &cli); err != nil {
+			logger.Errorw("could not apply profile", log.Error(err))
+			exit(1)
+		}
+		if cli.Client.Username == "" {
+			logger.Error("no username, set --username or use a profile")
+			exit(1)
+		}
+
+		addr := fmt.Sprintf("%s:%d", cli.Client.Host, cli.Client.Port)
 
 		var conn chat.Connection
-		var err error
+
+		tlsConfig, err := clientTLSConfig(
+			cli.Client.Tls,
+			cli.Client.TlsCa,
+			cli.Client.TlsCert,
+			cli.Client.TlsKey,
+		)
+		if err != nil {
+			logger.Errorw("could not load tls config", log.Error(err))
+			exit(1)
+		}
 
 		if cli.Client.Grpc {
-			conn, err = grpc.NewClientConnection(addr, cli.Client.Username, logger)
+			conn, err = grpc.NewClientConnection(
+				addr,
+				cli.Client.Username,
+				logger,
+				grpc.ClientOpts{
+					Token:     cli.Client.Token,
+					TLSConfig: tlsConfig,
+					Keepalive: grpc.KeepaliveOpts{
+						Interval: cli.Client.KeepaliveTime,
+						Timeout:  cli.Client.KeepaliveTimeout,
+					},
+				},
+			)
 		} else {
-			conn, err = websocket.NewClientConnection(addr, cli.Client.Username, logger)
+			conn, err = websocket.NewClientConnection(
+				addr,
+				cli.Client.Username,
+				logger,
+				websocket.ClientOpts{
+					Token:     cli.Client.Token,
+					TLSConfig: tlsConfig,
+					Codec:     cli.Client.Codec,
+					Keepalive: websocket.KeepaliveOpts{
+						Interval: cli.Client.KeepaliveTime,
+						Timeout:  cli.Client.KeepaliveTimeout,
+					},
+				},
+			)
 		}
 
 		if err != nil {
@@ -81,50 +228,221 @@ func main() {
 
 		defer conn.Close(nil)
 
-		frontendErr := func(err error) {
-			logger.Error("frontend error", log.Error(err))
+		if cli.Client.Record != "" {
+			f, err := os.Create(cli.Client.Record)
+			if err != nil {
+				logger.Errorw("could not create recording", log.Error(err))
+				exit(1)
+			}
+			defer f.Close()
+			conn = chat.NewRecordingConnection(conn, f)
+		}
+
+		err = startFrontend(conn, frontendOpts{
+			stdout:    cli.Client.StdoutFrontend,
+			plain:     cli.Client.Plain,
+			format:    cli.Client.Format,
+			username:  cli.Client.Username,
+			guiConfig: cli.Client.GuiConfig,
+			notifier: chat.NewNotifier(
+				cli.Client.Bell,
+				cli.Client.NotifyCmd,
+				logger,
+			),
+		}, logger)
+		if errors.Is(err, chat.ErrConnectionClosed) && conn.Err() != nil {
+			err = conn.Err() // closed by the server, for example rejected
+		}
+		if err != nil {
+			logger.Errorw("frontend error", log.Error(err))
 			exit(1)
 		}
 
-		if cli.Client.StdoutFrontend {
-			fe := chat.NewStdoutFrontend(conn, logger)
-			if err := fe.Start(); err != nil {
-				frontendErr(err)
-			}
-		} else {
-			fe, err := chat.NewGUIFrontend(conn, logger)
-			if err != nil {
-				frontendErr(err)
-			}
-			if err := fe.Start(); err != nil {
-				frontendErr(err)
-			}
+	case "bench":
+		zl := log.NewZapLogger(os.Stderr, cli.Verbose, cli.VeryVerbose)
+		log.RedirectStdLog(zl)
+		logger := log.NewZapLoggerAdapter(zl)
+		if err := runBench(&cli, logger); err != nil {
+			logger.Errorw("bench error", log.Error(err))
+			_ = zl.Sync()
+			os.Exit(1)
+		}
+		_ = zl.Sync()
+
+	case "export":
+		zl := log.NewZapLogger(os.Stderr, cli.Verbose, cli.VeryVerbose)
+		log.RedirectStdLog(zl)
+		logger := log.NewZapLoggerAdapter(zl)
+		if err := runExport(&cli, logger); err != nil {
+			logger.Errorw("export error", log.Error(err))
+			_ = zl.Sync()
+			os.Exit(1)
+		}
+		_ = zl.Sync()
+
+	case "admin rooms", "admin users", "admin kick <username>",
+		"admin notice <message>", "admin members", "admin role <username> <role>",
+		"admin ban <username>", "admin unban <username>", "admin bans",
+		"admin stats", "admin retention":
+		zl := log.NewZapLogger(os.Stderr, cli.Verbose, cli.VeryVerbose)
+		log.RedirectStdLog(zl)
+		logger := log.NewZapLoggerAdapter(zl)
+		if err := runAdmin(ctx.Command(), &cli, logger); err != nil {
+			logger.Errorw("admin error", log.Error(err))
+			_ = zl.Sync()
+			os.Exit(1)
+		}
+		_ = zl.Sync()
+
+	case "replay <file>":
+		stdErrBuf := bufio.NewWriter(os.Stderr)
+		zl := log.NewZapLogger(stdErrBuf, cli.Verbose, cli.VeryVerbose)
+		log.RedirectStdLog(zl)
+		logger := log.NewZapLoggerAdapter(zl)
+		err := runReplay(&cli, logger)
+		_ = zl.Sync()
+		stdErrBuf.Flush()
+		if err != nil {
+			os.Exit(1)
 		}
 
 	case "server":
 		zl := log.NewZapLogger(os.Stderr, cli.Verbose, cli.VeryVerbose)
 		log.RedirectStdLog(zl)
 		logger := log.NewZapLoggerAdapter(zl)
+		shutdownTracing, err := tracing.Setup(context.Background(), tracing.Opts{
+			Exporter:    cli.Server.Trace,
+			Endpoint:    cli.Server.TraceEndpoint,
+			Insecure:    cli.Server.TraceInsecure,
+			ServiceName: "gochat-server",
+		})
+		if err != nil {
+			logger.Errorw("could not set up tracing", log.Error(err))
+			_ = zl.Sync()
+			os.Exit(1)
+		}
 		exit := func(code int) {
+			_ = shutdownTracing(context.Background())
 			_ = zl.Sync()
 			os.Exit(code)
 		}
 
 		addr := fmt.Sprintf("%s:%d", cli.Server.Host, cli.Server.Port)
 
-		if cli.Server.Grpc {
-			s := grpc.NewServer(logger)
-			if err := s.Start(addr); err != nil {
-				logger.Error("server error", log.Error(err))
+		plugins := []*chat.Plugin{}
+		for _, name := range cli.Server.Middleware {
+			p, err := chat.NewPlugin(name, chat.PluginOpts{
+				Logger: logger,
+				Unfurl: unfurl.Options{
+					Allow: cli.Server.UnfurlAllow,
+					Deny:  cli.Server.UnfurlDeny,
+				},
+			})
+			if err != nil {
+				logger.Errorw("could not create middleware", log.Error(err))
 				exit(1)
 			}
+			plugins = append(plugins, p)
+		}
 
-		} else {
-			s := websocket.NewServer(logger)
-			if err := s.Start(addr); err != nil {
-				logger.Error("server error", log.Error(err))
+		serverConfigPath := cli.Server.ServerConfig
+		if serverConfigPath == "" {
+			var err error
+			serverConfigPath, err = config.DefaultServerPath()
+			if err != nil {
+				logger.Errorw("could not find server config", log.Error(err))
 				exit(1)
 			}
 		}
+		serverConfig, err := config.LoadServer(serverConfigPath)
+		if err != nil {
+			logger.Errorw("could not load server config", log.Error(err))
+			exit(1)
+		}
+
+		storePath := cli.Server.StorePath
+		if storePath == "" && cli.Server.Store == chat.StoreBolt {
+			storePath, err = chat.DefaultStorePath()
+			if err != nil {
+				logger.Errorw("could not find store", log.Error(err))
+				exit(1)
+			}
+		}
+		store, err := chat.OpenStore(cli.Server.Store, storePath)
+		if err != nil {
+			logger.Errorw("could not open store", log.Error(err))
+			exit(1)
+		}
+		closeStore := func() {
+			if err := store.Close(); err != nil {
+				logger.Errorw("could not close store", log.Error(err))
+			}
+		}
+
+		hubOpts := chat.HubOpts{
+			OfflineRetention: cli.Server.OfflineRetention,
+			IdleTimeout:      cli.Server.IdleTimeout,
+			Retention:        serverConfig.Retention,
+			Store:            store,
+			AdminToken:       cli.Server.AdminToken,
+		}
+		if !cli.Server.NoOffline {
+			dir := cli.Server.OfflineDir
+			var err error
+			if dir == "" {
+				dir, err = chat.DefaultOfflineDir()
+			}
+			if err == nil {
+				hubOpts.Offline, err = chat.NewFileOfflineStore(dir)
+			}
+			if err != nil {
+				logger.Errorw("could not open offline store", log.Error(err))
+				closeStore()
+				exit(1)
+			}
+		}
+
+		var (
+			hub   *chat.Hub
+			start func() error
+			stop  func() error
+		)
+		if cli.Server.Grpc {
+			s := grpc.NewServer(logger, hubOpts)
+			s.Keepalive = grpc.KeepaliveOpts{
+				Interval: cli.Server.KeepaliveTime,
+				Timeout:  cli.Server.KeepaliveTimeout,
+			}
+			hub, stop = s.Hub(), s.Stop
+			start = func() error { return s.Start(addr) }
+		} else {
+			s := websocket.NewServer(logger, hubOpts)
+			s.Keepalive = websocket.KeepaliveOpts{
+				Interval: cli.Server.KeepaliveTime,
+				Timeout:  cli.Server.KeepaliveTimeout,
+			}
+			hub, stop = s.Hub(), s.Stop
+			start = func() error { return s.Start(addr) }
+		}
+		hub.Use(plugins...)
+
+		// Stop serving on SIGINT and SIGTERM, to close the hub and the
+		// store cleanly.
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			sig := <-signals
+			logger.Infow("stopping server", "signal", sig.String())
+			_ = stop()
+		}()
+
+		err = start()
+		_ = hub.Close() // stores pending writes
+		closeStore()
+		if err != nil {
+			logger.Error("server error", log.Error(err))
+			exit(1)
+		}
+		exit(0)
 	}
 }
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/alecthomas/kong"
+	"github.com/marcelbeumer/go-playground/gochat/internal/config"
+)
+
+type ProfileOpts struct {
+	Host      string `help:"Server host."`
+	Port      int    `help:"Server port."                                     short:"p"`
+	Transport string `help:"Transport (websocket, grpc), websocket when not set." enum:",websocket,grpc" default:""`
+	Username  string `help:"Username."                                        short:"u"`
+	Token     string `help:"Authentication token."`
+	Tls       bool   `help:"Use TLS."`
+	TlsCa     string `help:"TLS CA file to verify the server with." type:"path"`
+	TlsCert   string `help:"TLS client certificate file."           type:"path"`
+	TlsKey    string `help:"TLS client key file."                   type:"path"`
+}
+
+func configPath(cli *Commands) (string, error) {
+	if cli.ConfigFile != "" {
+		return cli.ConfigFile, nil
+	}
+	return config.DefaultPath()
+}
+
+func loadConfig(cli *Commands) (*config.Config, string, error) {
+	path, err := configPath(cli)
+	if err != nil {
+		return nil, "", err
+	}
+	c, err := config.Load(path)
+	return c, path, err
+}
+
+// explicitFlags returns the names of the flags that were set on the
+// command line or with an env var.
+func explicitFlags(ctx *kong.Context) map[string]bool {
+	set := map[string]bool{}
+	for _, path := range ctx.Path {
+		if path.Flag != nil {
+			set[path.Flag.Name] = true
+		}
+	}
+	for _, flag := range ctx.Flags() {
+		if flag.Env != "" && os.Getenv(flag.Env) != "" {
+			set[flag.Name] = true
+		}
+	}
+	return set
+}
+
+// applyProfile sets client options from the selected profile,
+// unless they were set with flags or env vars.
+func applyProfile(ctx *kong.Context, cli *Commands) error {
+	if cli.Client.Profile == "" {
+		return nil
+	}
+	c, _, err := loadConfig(cli)
+	if err != nil {
+		return err
+	}
+	p, err := c.Profile(cli.Client.Profile)
+	if err != nil {
+		return err
+	}
+
+	set := explicitFlags(ctx)
+	opts := &cli.Client
+	if !set["host"] && p.Host != "" {
+		opts.Host = p.Host
+	}
+	if !set["port"] && p.Port != 0 {
+		opts.Port = p.Port
+	}
+	if !set["grpc"] && p.Transport != "" {
+		opts.Grpc = p.Transport == config.TransportGrpc
+	}
+	if !set["username"] && p.Username != "" {
+		opts.Username = p.Username
+	}
+	if !set["token"] && p.Token != "" {
+		opts.Token = p.Token
+	}
+	if !set["tls"] && p.TLS {
+		opts.Tls = true
+	}
+	if !set["tls-ca"] && p.TLSCA != "" {
+		opts.TlsCa = p.TLSCA
+	}
+	if !set["tls-cert"] && p.TLSCert != "" {
+		opts.TlsCert = p.TLSCert
+	}
+	if !set["tls-key"] && p.TLSKey != "" {
+		opts.TlsKey = p.TLSKey
+	}
+	return nil
+}
+
+// clientTLSConfig returns the TLS config for clients,
+// or nil when not using TLS.
+func clientTLSConfig(useTLS bool, ca, cert, key string) (*tls.Config, error) {
+	if !useTLS && ca == "" && cert == "" {
+		return nil, nil
+	}
+	return config.ClientTLSConfig(ca, cert, key)
+}
+
+func runConfigCommand(command string, cli *Commands) error {
+	c, path, err := loadConfig(cli)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "config list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSERVER\tTRANSPORT\tUSERNAME\tTOKEN\tTLS")
+		for _, name := range c.ProfileNames() {
+			p, _ := c.Profile(name)
+			token := ""
+			if p.Token != "" {
+				token = "***"
+			}
+			server := "-"
+			if p.Host != "" || p.Port != 0 {
+				server = fmt.Sprintf("%s:%d", p.Host, p.Port)
+			}
+			transport := p.Transport
+			if transport == "" {
+				transport = config.TransportWebsocket
+			}
+			fmt.Fprintf(
+				w,
+				"%s\t%s\t%s\t%s\t%s\t%t\n",
+				name,
+				server,
+				transport,
+				p.Username,
+				token,
+				p.TLS || p.TLSCA != "" || p.TLSCert != "",
+			)
+		}
+		return w.Flush()
+
+	case "config add <name>":
+		opts := cli.Config.Add.ProfileOpts
+		err := c.SetProfile(cli.Config.Add.Name, config.Profile{
+			Host:      opts.Host,
+			Port:      opts.Port,
+			Transport: opts.Transport,
+			Username:  opts.Username,
+			Token:     opts.Token,
+			TLS:       opts.Tls,
+			TLSCA:     opts.TlsCa,
+			TLSCert:   opts.TlsCert,
+			TLSKey:    opts.TlsKey,
+		})
+		if err != nil {
+			return err
+		}
+		return c.Save(path)
+
+	case "config remove <name>":
+		if err := c.RemoveProfile(cli.Config.Remove.Name); err != nil {
+			return err
+		}
+		return c.Save(path)
+	}
+
+	return fmt.Errorf(`unknown command "%s"`, command)
+}
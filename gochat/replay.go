@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/marcelbeumer/go-playground/gochat/internal/chat"
+	"github.com/marcelbeumer/go-playground/gochat/internal/log"
+	"github.com/marcelbeumer/go-playground/gochat/internal/util/clock"
+)
+
+type ReplayOpts struct {
+	File           string  `help:"Recording file."                                      arg:"" type:"existingfile"`
+	Realtime       bool    `help:"Pace events as recorded instead of replaying at once." short:"r"`
+	Speed          float64 `help:"Speed factor for --realtime."                                     default:"1"`
+	Username       string  `help:"Username of the recording user, for highlighting."     short:"u"`
+	StdoutFrontend bool    `help:"Use simple stdout frontend."                           short:"s"`
+	Plain          bool    `help:"Strip message formatting in the stdout frontend."`
+	Format         string  `help:"Stdout frontend format (text, json), json implies --stdout-frontend." enum:"text,json" default:"text"`
+	GuiConfig      string  `help:"GUI theme and layout config file."                                type:"path"`
+}
+
+// runReplay feeds the recording to a frontend. The stdout frontend stops
+// at the end of the recording, the GUI stays open until quit.
+func runReplay(cli *Commands, logger log.Logger) error {
+	opts := cli.Replay
+	f, err := os.Open(opts.File)
+	if err != nil {
+		logger.Errorw("could not open recording", log.Error(err))
+		return err
+	}
+	recording, err := chat.ReadRecording(f)
+	f.Close()
+	if err != nil {
+		logger.Errorw("could not read recording", log.Error(err))
+		return err
+	}
+
+	speed := 0.0
+	if opts.Realtime {
+		speed = opts.Speed
+	}
+
+	// Events sent by the frontend go nowhere.
+	events := make(chan chat.Event)
+	sent := make(chan chat.Event)
+	conn := chat.NewTestConnection(events, sent)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-sent:
+			}
+		}
+	}()
+	go func() {
+		chat.Replay(clock.Real, recording, events, speed, stop)
+		logger.Infow("end of recording", "events", len(recording))
+		if opts.StdoutFrontend || opts.Format == chat.StdoutFormatJSON {
+			_ = conn.Close(nil)
+		}
+	}()
+
+	err = startFrontend(conn, frontendOpts{
+		stdout:    opts.StdoutFrontend,
+		plain:     opts.Plain,
+		format:    opts.Format,
+		username:  opts.Username,
+		guiConfig: opts.GuiConfig,
+		noInput:   true,
+	}, logger)
+	if err != nil && !errors.Is(err, chat.ErrConnectionClosed) {
+		logger.Errorw("frontend error", log.Error(err))
+		return err
+	}
+	return nil
+}